@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// ListPendingApprovals query the transfers pending approval the customer with the given ID
+// may approve, as holder or signatory with full permission of the payer account
+func (cc *Chaincode) ListPendingApprovals(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ListPendingApprovals with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required customer ID")
+	}
+	transfers, err := cc.getPendingApprovals(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	list := model.TransferList{Transfers: []*model.Transfer{}}
+	for _, t := range transfers {
+		from, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+		if err != nil {
+			return nil, err
+		}
+		if from.IsApprover(args[0]) {
+			list.Transfers = append(list.Transfers, t)
+		}
+	}
+	return json.Marshal(&list)
+}
+
+// ExpirePendingApprovals fails all transfers whose approval deadline has passed, releasing
+// their funds, and returns them. Only bank operators can expire approvals.
+func (cc *Chaincode) ExpirePendingApprovals(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ExpirePendingApprovals with args %v", args)
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := cc.getPendingApprovals(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	list := model.TransferList{Transfers: []*model.Transfer{}}
+	for _, t := range transfers {
+		if !t.Approval.Expired(src.Now()) {
+			continue
+		}
+		if err := cc.expireApproval(state, src, t); err != nil {
+			return nil, err
+		}
+		list.Transfers = append(list.Transfers, t)
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&list)
+}
+
+// holdForApproval stores a transfer requiring approval by the approvers of the payer account
+// pending approval until the deadline set by the account's approval policy, with its amount
+// plus fee reserved by a hold. The approval of the customer initiating the transfer counts
+// if the customer is one of the approvers. Returns true if the transfer has been held for approval.
+func (cc *Chaincode) holdForApproval(state *txState, src model.Source, t *model.Transfer, from *model.Account) (bool, error) {
+	if !from.NeedsApproval(t.Amount) || (t.Approval != nil && t.Approval.Complete()) {
+		return false, nil
+	}
+	id, err := cc.caller(state.stub)
+	if err != nil {
+		return false, err
+	}
+	hold, err := cc.reserveFunds(state, src, t, from, "approval")
+	if err != nil {
+		return false, err
+	}
+	t.HoldForApproval(from.Approval.Required, from.Approval.Deadline(src.Now()), hold.ID)
+	if id.Role == CustomerRole && from.IsApprover(id.CustomerID) {
+		if err := t.AddApproval(id.CustomerID, src.Now()); err != nil {
+			return false, err
+		}
+	}
+	key, err := cc.createCompositeKey(model.PendingApprovalObjectType, []string{t.ID})
+	if err != nil {
+		return false, err
+	}
+	if _, err := state.putObject(key, &model.PendingApproval{Entity: model.Entity{ObjectType: model.PendingApprovalObjectType}, TransferID: t.ID}); err != nil {
+		return false, err
+	}
+	return true, cc.putTransfer(state, t)
+}
+
+// addApproval records the approval of a transfer pending approval by the caller, who must
+// be one of the approvers of the payer account
+func (cc *Chaincode) addApproval(state *txState, src model.Source, t *model.Transfer) error {
+	id, err := cc.caller(state.stub)
+	if err != nil {
+		return err
+	}
+	from, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return err
+	}
+	if id.Role != CustomerRole || !from.IsApprover(id.CustomerID) {
+		return fmt.Errorf("Caller is not authorised to approve transfer %s", t.ID)
+	}
+	return t.AddApproval(id.CustomerID, src.Now())
+}
+
+// expireApproval stages the failure of a transfer whose approval deadline has passed with
+// the approval_expired failure code, releasing its hold
+func (cc *Chaincode) expireApproval(state *txState, src model.Source, t *model.Transfer) error {
+	if err := cc.endPending(state, t); err != nil {
+		return err
+	}
+	payer, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return err
+	}
+	expiry := newTransferError(model.ApprovalExpired, payer, nil, "Approval of transfer %s has expired", t.ID)
+	return cc.stageFailure(state, src, t, expiry)
+}
+
+// getPendingApprovals returns the transfers pending approval
+func (cc *Chaincode) getPendingApprovals(stub shim.ChaincodeStubInterface) ([]*model.Transfer, error) {
+	keysIter, err := cc.partialCompositeKeyQuery(stub, model.PendingApprovalObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer keysIter.Close()
+
+	state := newTxState(stub)
+	var transfers []*model.Transfer
+	for keysIter.HasNext() {
+		_, pendingBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		pending := new(model.PendingApproval)
+		if err := bytesToStruct(pendingBytes, pending); err != nil {
+			return nil, err
+		}
+		t, err := cc.getTransfer(state, pending.TransferID)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, nil
+}
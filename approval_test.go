@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// setupApproval opens a joint account of customers 1 and 2 requiring both to approve
+// transfers above 1000 within 24 hours and returns a transfer pending approval
+func (suite *ChaincodeSuite) setupApproval() *model.Transfer {
+	suite.openAccount("1", "1234", "AU", "AUD", 10000)
+	suite.openAccount("10", "5678", "AU", "AUD", 0)
+	suite.checkInvoke("AddJointHolder", []string{"1", "1234", "2"})
+	suite.checkInvoke("SetApprovalPolicy", []string{"1", "1234", `{"threshold":1000,"required":2,"expiry_hours":24}`})
+	suite.asCustomer("1")
+	defer suite.asRole(BankOperatorRole)
+	return suite.transferMoney("1", "1234", "10", "5678", "AUD", 5000)
+}
+
+// listPendingApprovals returns the transfers a customer may approve
+func (suite *ChaincodeSuite) listPendingApprovals(customerID string) []*model.Transfer {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "ListPendingApprovals", []string{customerID})
+	suite.Nil(err)
+	list := new(model.TransferList)
+	json.Unmarshal(res, list)
+	return list.Transfers
+}
+
+func (suite *ChaincodeSuite) TestListPendingApprovals() {
+	t := suite.setupApproval()
+	suite.Equal(suite.now.Add(24*time.Hour).Unix(), t.Approval.Expires)
+
+	suite.asCustomer("2")
+	pending := suite.listPendingApprovals("2")
+	suite.Len(pending, 1)
+	suite.Equal(t.ID, pending[0].ID)
+	_, err := suite.stub.MockInvoke("t1", "ListPendingApprovals", []string{"1"})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	suite.asCustomer("10")
+	suite.Empty(suite.listPendingApprovals("10"), "Payees cannot approve")
+
+	suite.asCustomer("2")
+	_, err = suite.stub.MockInvoke("t2", "ApproveTransfer", []string{t.ID})
+	suite.Nil(err)
+	suite.Empty(suite.listPendingApprovals("2"))
+}
+
+func (suite *ChaincodeSuite) TestApprovalExpires() {
+	t := suite.setupApproval()
+
+	suite.now = suite.now.Add(24 * time.Hour)
+	suite.asCustomer("2")
+	res, err := suite.stub.MockInvoke("t1", "ApproveTransfer", []string{t.ID})
+	suite.Nil(err, "The expiry is recorded, so the invocation must succeed")
+	expired := new(model.Transfer)
+	json.Unmarshal(res, expired)
+	suite.Equal(model.TransferFailed, expired.Status)
+	suite.Equal(model.ApprovalExpired, expired.FailureCode)
+	suite.Len(expired.Approval.Approvals, 1, "Late approvals are not recorded")
+
+	suite.asRole(BankOperatorRole)
+	t = suite.getTransfer(t.ID)
+	suite.Equal(model.TransferFailed, t.Status)
+	suite.Equal(model.ApprovalExpired, t.FailureCode)
+	suite.Equal(model.ApprovalExpired, suite.getFailedTransaction("1", "1234").FailureCode)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 10000, Available: 10000}, suite.getBalance("1", "1234"))
+	suite.Empty(suite.listPendingApprovals("1"))
+}
+
+func (suite *ChaincodeSuite) TestExpirePendingApprovals() {
+	t := suite.setupApproval()
+
+	suite.now = suite.now.Add(23 * time.Hour)
+	res, err := suite.stub.MockInvoke("t1", "ExpirePendingApprovals", []string{})
+	suite.Nil(err)
+	suite.Equal(`{"transfers":[]}`, string(res))
+
+	suite.now = suite.now.Add(time.Hour)
+	res, err = suite.stub.MockInvoke("t2", "ExpirePendingApprovals", []string{})
+	suite.Nil(err)
+	expired := new(model.TransferList)
+	json.Unmarshal(res, expired)
+	suite.Len(expired.Transfers, 1)
+	suite.Equal(t.ID, expired.Transfers[0].ID)
+	suite.Equal(model.ApprovalExpired, expired.Transfers[0].FailureCode)
+	suite.Equal(int64(0), suite.getBalance("1", "1234").Held)
+
+	suite.asCustomer("1")
+	_, err = suite.stub.MockInvoke("t3", "ExpirePendingApprovals", []string{})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const (
+	// RoleAttribute name of the enrollment certificate attribute holding the caller's role
+	RoleAttribute = "role"
+	// CustomerAttribute name of the enrollment certificate attribute holding the customer ID of a customer
+	CustomerAttribute = "customer_id"
+	// VerifierAttribute name of the enrollment certificate attribute holding the name of a KYC verifier
+	VerifierAttribute = "verifier_id"
+)
+
+const (
+	// CustomerRole role of customers acting on their own accounts
+	CustomerRole = "customer"
+	// BankOperatorRole role of bank staff allowed to change account facilities
+	BankOperatorRole = "bank_operator"
+	// ComplianceRole role of compliance officers maintaining the watch-list
+	ComplianceRole = "compliance_officer"
+	// KYCVerifierRole role of identity verifiers recording the KYC results of customers
+	KYCVerifierRole = "kyc_verifier"
+	// AuditorRole role of auditors with read access to all accounts
+	AuditorRole = "auditor"
+	// AdminRole role of chaincode administrators, who have the permissions of all other roles
+	AdminRole = "admin"
+)
+
+// staffRoles roles allowed to query the accounts of any customer
+var staffRoles = []string{BankOperatorRole, ComplianceRole, AuditorRole}
+
+// Identity the role and, for customers, the customer ID or, for KYC verifiers, the verifier ID of the caller
+type Identity struct {
+	Role       string
+	CustomerID string
+	VerifierID string
+}
+
+// hasRole returns true if the identity has one of the given roles or is an administrator
+func (id *Identity) hasRole(roles ...string) bool {
+	if id.Role == AdminRole {
+		return true
+	}
+	for _, role := range roles {
+		if id.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// actsFor returns true if the identity is the customer with the given ID
+func (id *Identity) actsFor(customerID string) bool {
+	return id.Role == CustomerRole && id.CustomerID != "" && id.CustomerID == customerID
+}
+
+// caller returns the identity of the caller, read from its enrollment certificate
+func (cc *Chaincode) caller(stub shim.ChaincodeStubInterface) (*Identity, error) {
+	read := cc.attribute
+	if read == nil {
+		read = shim.ChaincodeStubInterface.ReadCertAttribute
+	}
+	role, err := read(stub, RoleAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading caller role. Error: %s", err)
+	}
+	id := &Identity{Role: string(role)}
+	if id.Role == CustomerRole {
+		customerID, err := read(stub, CustomerAttribute)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading caller customer ID. Error: %s", err)
+		}
+		id.CustomerID = string(customerID)
+	}
+	if id.Role == KYCVerifierRole {
+		verifierID, err := read(stub, VerifierAttribute)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading caller verifier ID. Error: %s", err)
+		}
+		id.VerifierID = string(verifierID)
+	}
+	return id, nil
+}
+
+// requireRole returns an error unless the caller has one of the given roles
+func (cc *Chaincode) requireRole(stub shim.ChaincodeStubInterface, roles ...string) error {
+	id, err := cc.caller(stub)
+	if err != nil {
+		return err
+	}
+	if !id.hasRole(roles...) {
+		return roleError(roles)
+	}
+	return nil
+}
+
+// initiate records the calling customer as the initiator of a transfer, so the checks of the
+// payer cover the customer as well as the account owner. Transfers of bank staff have no initiator.
+func (cc *Chaincode) initiate(stub shim.ChaincodeStubInterface, t *model.Transfer) error {
+	id, err := cc.caller(stub)
+	if err != nil {
+		return err
+	}
+	if id.Role == CustomerRole {
+		t.InitiatedBy = id.CustomerID
+	}
+	return nil
+}
+
+func roleError(roles []string) error {
+	if len(roles) == 1 {
+		return fmt.Errorf("Caller is not authorised, role %s is required", roles[0])
+	}
+	return fmt.Errorf("Caller is not authorised, one of roles %s is required", strings.Join(roles, ", "))
+}
+
+//---------
+// Policies
+//---------
+
+// allowRoles returns a policy allowing callers with one of the given roles
+func (cc *Chaincode) allowRoles(roles ...string) Policy {
+	return func(stub shim.ChaincodeStubInterface, args []string) error {
+		return cc.requireRole(stub, roles...)
+	}
+}
+
+// allowOwner returns a policy allowing customers to act on their own accounts, identified
+// by the customer ID customerOf finds in the args, and callers with one of the given
+// roles to act on the accounts of any customer
+func (cc *Chaincode) allowOwner(customerOf func(args []string) string, roles ...string) Policy {
+	return func(stub shim.ChaincodeStubInterface, args []string) error {
+		id, err := cc.caller(stub)
+		if err != nil {
+			return err
+		}
+		if id.hasRole(roles...) {
+			return nil
+		}
+		if id.Role != CustomerRole {
+			return roleError(append([]string{CustomerRole}, roles...))
+		}
+		if customerID := customerOf(args); !id.actsFor(customerID) {
+			return fmt.Errorf("Caller is not authorised to act for customer %s", customerID)
+		}
+		return nil
+	}
+}
+
+// allowTransferParty returns a policy allowing customers paying or being paid by the
+// transfer with the ID given as first argument, and callers with one of the given roles
+func (cc *Chaincode) allowTransferParty(roles ...string) Policy {
+	return func(stub shim.ChaincodeStubInterface, args []string) error {
+		id, err := cc.caller(stub)
+		if err != nil {
+			return err
+		}
+		if id.hasRole(roles...) {
+			return nil
+		}
+		if id.Role != CustomerRole {
+			return roleError(append([]string{CustomerRole}, roles...))
+		}
+		transferID := ""
+		if len(args) > 0 {
+			transferID = args[0]
+			if t, err := cc.getTransfer(newTxState(stub), transferID); err == nil {
+				if id.actsFor(t.FromCustomerID) || id.actsFor(t.ToCustomerID) {
+					return nil
+				}
+				for _, payee := range t.Payees {
+					if id.actsFor(payee.CustomerID) {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("Caller is not authorised to access transfer %s", transferID)
+	}
+}
+
+// allowAccountParty returns a policy allowing the holders and signatories of the account
+// identified by the customer and account IDs customerOf and accountOf find in the args,
+// provided allows accepts the call, and callers with one of the given roles
+func (cc *Chaincode) allowAccountParty(customerOf, accountOf func(args []string) string, allows accountCheck, roles ...string) Policy {
+	return func(stub shim.ChaincodeStubInterface, args []string) error {
+		id, err := cc.caller(stub)
+		if err != nil {
+			return err
+		}
+		if id.hasRole(roles...) {
+			return nil
+		}
+		if id.Role != CustomerRole {
+			return roleError(append([]string{CustomerRole}, roles...))
+		}
+		customerID, accountID := customerOf(args), accountOf(args)
+		if id.actsFor(customerID) {
+			return nil
+		}
+		if account, err := cc.getAccount(newTxState(stub), customerID, accountID); err == nil && allows(account, id.CustomerID, args) {
+			return nil
+		}
+		return fmt.Errorf("Caller is not authorised to act for customer %s", customerID)
+	}
+}
+
+// allowScheduleParty returns a policy allowing the holders and signatories of the paying account
+// of the scheduled transfer identified by the customer and schedule IDs passed as first and
+// second argument, provided allows accepts the call, and callers with one of the given roles
+func (cc *Chaincode) allowScheduleParty(allows accountCheck, roles ...string) Policy {
+	return func(stub shim.ChaincodeStubInterface, args []string) error {
+		accountOf := func(args []string) string {
+			if len(args) < 2 {
+				return ""
+			}
+			schedule, err := cc.getScheduledTransfer(newTxState(stub), args[0], args[1])
+			if err != nil {
+				return ""
+			}
+			return schedule.Transfer.FromAccountID
+		}
+		return cc.allowAccountParty(argString(0), accountOf, allows, roles...)(stub, args)
+	}
+}
+
+// accountCheck decides whether a party to an account may make a call with the given args
+type accountCheck func(account *model.Account, customerID string, args []string) bool
+
+// withPermission returns a check accepting parties with at least the given permission
+func withPermission(p model.Permission) accountCheck {
+	return func(account *model.Account, customerID string, args []string) bool {
+		return account.Allows(customerID, p)
+	}
+}
+
+// isHolder accepts the joint holders of an account
+func isHolder(account *model.Account, customerID string, args []string) bool {
+	return account.IsHolder(customerID)
+}
+
+// canPay accepts parties allowed to pay the amount of the transfer JSON passed as first argument
+func canPay(account *model.Account, customerID string, args []string) bool {
+	t := new(struct {
+		Amount int64 `json:"amount"`
+	})
+	if len(args) == 0 || json.Unmarshal([]byte(args[0]), t) != nil {
+		return false
+	}
+	return account.CanPay(customerID, t.Amount)
+}
+
+// argString returns a function reading a customer or account ID from the argument at index i
+func argString(i int) func(args []string) string {
+	return func(args []string) string {
+		if i >= len(args) {
+			return ""
+		}
+		return args[i]
+	}
+}
+
+// jsonString returns a function reading a customer or account ID from the JSON object passed
+// as first argument, following the path of field names. Fields are decoded exactly like the
+// handlers decode them into their structs, so keys match case-insensitively and the last of
+// several matching keys wins.
+func jsonString(path ...string) func(args []string) string {
+	return func(args []string) string {
+		if len(args) == 0 {
+			return ""
+		}
+		value := json.RawMessage(args[0])
+		for _, field := range path {
+			holder := reflect.New(reflect.StructOf([]reflect.StructField{{
+				Name: "Value",
+				Type: reflect.TypeOf(json.RawMessage{}),
+				Tag:  reflect.StructTag(fmt.Sprintf("json:%q", field)),
+			}}))
+			if err := json.Unmarshal(value, holder.Interface()); err != nil {
+				return ""
+			}
+			value = holder.Elem().Field(0).Interface().(json.RawMessage)
+		}
+		str := ""
+		json.Unmarshal(value, &str)
+		return str
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// asRole makes the following invocations as a caller with the given role
+func (suite *ChaincodeSuite) asRole(role string) {
+	suite.cc.attribute = func(stub shim.ChaincodeStubInterface, name string) ([]byte, error) {
+		if name != RoleAttribute {
+			return nil, errors.New("Unknown attribute " + name)
+		}
+		return []byte(role), nil
+	}
+}
+
+// asCustomer makes the following invocations as the customer with the given ID
+func (suite *ChaincodeSuite) asCustomer(customerID string) {
+	suite.cc.attribute = func(stub shim.ChaincodeStubInterface, name string) ([]byte, error) {
+		switch name {
+		case RoleAttribute:
+			return []byte(CustomerRole), nil
+		case CustomerAttribute:
+			return []byte(customerID), nil
+		}
+		return nil, errors.New("Unknown attribute " + name)
+	}
+}
+
+// asVerifier makes the following invocations as the KYC verifier with the given ID
+func (suite *ChaincodeSuite) asVerifier(verifierID string) {
+	suite.cc.attribute = func(stub shim.ChaincodeStubInterface, name string) ([]byte, error) {
+		switch name {
+		case RoleAttribute:
+			return []byte(KYCVerifierRole), nil
+		case VerifierAttribute:
+			return []byte(verifierID), nil
+		}
+		return nil, errors.New("Unknown attribute " + name)
+	}
+}
+
+func (suite *ChaincodeSuite) TestCallerWithoutRole() {
+	suite.asRole("")
+	_, err := suite.stub.MockInvoke("t1", "GetAccountList", []string{"1"})
+	suite.Equal("Caller is not authorised, one of roles customer, bank_operator, compliance_officer, auditor is required", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "GetFeeSchedule", []string{})
+	suite.Equal("Caller is not authorised, one of roles customer, bank_operator, compliance_officer, auditor is required", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestCallerAttributeError() {
+	suite.cc.attribute = func(stub shim.ChaincodeStubInterface, name string) ([]byte, error) {
+		return nil, errors.New("No certificate")
+	}
+	_, err := suite.stub.MockInvoke("t1", "GetAccountList", []string{"1"})
+	suite.Equal("Error reading caller role. Error: No certificate", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestCustomerActsOnOwnAccounts() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	suite.asCustomer("1")
+	_, err := suite.stub.MockInvoke("t1", "GetAccount", []string{"1", "1234"})
+	suite.Nil(err)
+	t := suite.transferMoney("1", "1234", "2", "5678", "AUD", 100)
+	suite.Equal(model.TransferCompleted, t.Status)
+	_, err = suite.stub.MockInvoke("t2", "GetTransfer", []string{t.ID})
+	suite.Nil(err)
+
+	suite.asCustomer("2")
+	_, err = suite.stub.MockInvoke("t3", "GetTransfer", []string{t.ID})
+	suite.Nil(err, "Payee can read the transfer")
+	_, err = suite.stub.MockInvoke("t4", "GetAccount", []string{"1", "1234"})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestCustomerCannotDebitOtherAccounts() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	suite.asCustomer("2")
+	transfer := `{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","amount":100,"currency":"AUD"}`
+	_, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	_, err = suite.stub.MockInvoke("t2", "CloseAccount", []string{"1", "1234"})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	_, err = suite.stub.MockInvoke("t3", "TopupAccount", []string{"2", "5678", "100"})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	suite.False(suite.getAccount("1", "1234").Closed)
+
+	suite.asCustomer("3")
+	_, err = suite.stub.MockInvoke("t4", "GetTransfer", []string{"unknown"})
+	suite.Equal("Caller is not authorised to access transfer unknown", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestCaseVariantKeysCannotBypassPolicy() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 1000)
+
+	suite.asCustomer("2")
+	transfer := `{"from_customer":"2","from_account":"5678","From_Customer":"1","From_Account":"1234","to_customer":"2","to_account":"5678","amount":100,"currency":"AUD"}`
+	_, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	schedule := `{"transfer":{"from_customer":"2","from_account":"5678","FROM_CUSTOMER":"1","FROM_ACCOUNT":"1234","to_customer":"2","to_account":"5678","amount":100,"currency":"AUD"},"frequency":"daily"}`
+	_, err = suite.stub.MockInvoke("t2", "ScheduleTransfer", []string{schedule})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	_, err = suite.stub.MockInvoke("t3", "PlaceHold", []string{`{"customer_id":"2","account_id":"5678","Customer_ID":"1","Account_ID":"1234","amount":100}`})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+
+	suite.asRole(BankOperatorRole)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 1000, Available: 1000}, suite.getBalance("1", "1234"))
+}
+
+func (suite *ChaincodeSuite) TestCaseVariantKeysReadLikeHandler() {
+	args := []string{`{"from_customer":"2","From_Customer":"1","nested":{"Id":"3"}}`}
+	suite.Equal("1", jsonString("from_customer")(args))
+	suite.Equal("3", jsonString("nested", "id")(args))
+	suite.Equal("", jsonString("to_customer")(args))
+	suite.Equal("", jsonString("from_customer")([]string{`not json`}))
+}
+
+func (suite *ChaincodeSuite) TestReadOnlyRoles() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+
+	suite.asRole(AuditorRole)
+	_, err := suite.stub.MockInvoke("t1", "GetAccountBalance", []string{"1", "1234"})
+	suite.Nil(err)
+	_, err = suite.stub.MockInvoke("t2", "TopupAccount", []string{"1", "1234", "100"})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+	_, err = suite.stub.MockInvoke("t3", "CloseAccount", []string{"1", "1234"})
+	suite.Equal("Caller is not authorised, one of roles customer, bank_operator is required", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestAdminHasAllRoles() {
+	suite.asRole(AdminRole)
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	_, err := suite.stub.MockInvoke("t1", "SetWatchList", []string{`{"entries":[]}`})
+	suite.Nil(err)
+	_, err = suite.stub.MockInvoke("t2", "MigrateKeys", []string{})
+	suite.Nil(err)
+
+	suite.asRole(BankOperatorRole)
+	_, err = suite.stub.MockInvoke("t3", "MigrateKeys", []string{})
+	suite.Equal("Caller is not authorised, role admin is required", err.Error())
+}
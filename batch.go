@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// BatchTransfer makes a list of transfers in a single invocation. The transfers are made
+// in order, so later transfers see the balances left by earlier ones. In all_or_nothing
+// mode (the default) nothing is written unless every transfer succeeds; in best_effort
+// mode each transfer succeeds or fails on its own and rejected transfers are recorded like
+// a rejected TransferMoney. The outcome of every transfer is returned.
+func (cc *Chaincode) BatchTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering BatchTransfer with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required batch JSON")
+	}
+	batch, err := model.CreateBatch([]byte(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing batch JSON. Error: %s", err)
+	}
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	state := newTxState(stub)
+	result := &model.BatchResult{Mode: batch.Mode, Committed: true}
+	for i := range batch.Transfers {
+		item, err := cc.batchItem(state, src, batch, i)
+		if err != nil {
+			return nil, err
+		}
+		result.Results = append(result.Results, item)
+		switch item.Status {
+		case model.BatchItemSucceeded:
+			result.Succeeded++
+			continue
+		case model.BatchItemPending:
+			result.Pending++
+			continue
+		}
+		result.Failed++
+		if batch.Mode == model.AllOrNothing {
+			result.Committed = false
+			break
+		}
+	}
+
+	if !result.Committed {
+		logger.Infof("Rolling back batch of %d transfers", len(batch.Transfers))
+		for _, item := range result.Results {
+			if item.Status == model.BatchItemSucceeded || item.Status == model.BatchItemPending {
+				item.Status = model.BatchItemRolledBack
+				item.TransferID = ""
+				item.TransferStatus = ""
+			}
+		}
+		for i := len(result.Results); i < len(batch.Transfers); i++ {
+			result.Results = append(result.Results, &model.BatchItemResult{Index: i, Status: model.BatchItemRolledBack})
+		}
+		result.Succeeded = 0
+		result.Pending = 0
+		return json.Marshal(result)
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+// batchItem makes the transfer at the given index of a batch in a child state. Errors are only
+// returned if the outcome of a best effort transfer cannot be recorded.
+func (cc *Chaincode) batchItem(state *txState, src model.Source, batch *model.Batch, index int) (*model.BatchItemResult, error) {
+	item := &model.BatchItemResult{Index: index}
+	t, err := batch.ParseTransfer(index)
+	if err != nil {
+		item.Status = model.BatchItemFailed
+		item.FailureCode = model.InvalidTransfer
+		item.Message = err.Error()
+		return item, nil
+	}
+	t.Begin(src)
+	if err := cc.initiate(state.stub, t); err != nil {
+		return nil, err
+	}
+
+	child := state.child()
+	err = cc.transfer(child, src, t)
+	if err == nil {
+		item.Status = model.BatchItemSucceeded
+		if t.Status != model.TransferCompleted {
+			item.Status = model.BatchItemPending
+		}
+		item.TransferID = t.ID
+		item.TransferStatus = t.Status
+		return item, child.commit()
+	}
+	item.Status = model.BatchItemFailed
+	item.FailureCode = model.InvalidTransfer
+	item.Message = err.Error()
+	if terr, ok := err.(*TransferError); ok {
+		item.FailureCode = terr.Code
+		if batch.Mode == model.BestEffort {
+			item.TransferID = t.ID
+		}
+	}
+	if batch.Mode == model.AllOrNothing {
+		return item, nil
+	}
+	return item, cc.stageFailure(state, src, t, err)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// batchTransfer makes a batch of transfers and returns the result
+func (suite *ChaincodeSuite) batchTransfer(batch string) *model.BatchResult {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "BatchTransfer", []string{batch})
+	suite.Nil(err)
+	result := new(model.BatchResult)
+	json.Unmarshal(res, result)
+	return result
+}
+
+const (
+	testBatchItem1 = `{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":600}`
+	testBatchItem2 = `{"from_customer":"2","from_account":"5678","to_customer":"3","to_account":"9012","currency":"AUD","amount":500}`
+	testBatchItem3 = `{"from_customer":"1","from_account":"1234","to_customer":"3","to_account":"9012","currency":"AUD","amount":600}`
+)
+
+func (suite *ChaincodeSuite) setupBatch() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.openAccount("3", "9012", "AU", "AUD", 0)
+}
+
+func (suite *ChaincodeSuite) TestBatchTransferValidation() {
+	_, err := suite.stub.MockInvoke("t1", "BatchTransfer", []string{})
+	suite.Equal("Missing required batch JSON", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "BatchTransfer", []string{`{"transfers":[]}`})
+	suite.Equal("Error parsing batch JSON. Error: Missing required transfers", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestBatchTransferSequencesTransfers() {
+	suite.setupBatch()
+	// the second transfer is funded by the first
+	result := suite.batchTransfer(`{"transfers":[` + testBatchItem1 + `,` + testBatchItem2 + `]}`)
+	suite.True(result.Committed)
+	suite.Equal(2, result.Succeeded)
+	suite.Equal(int64(400), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(100), suite.getAccount("2", "5678").Balance)
+	suite.Equal(int64(500), suite.getAccount("3", "9012").Balance)
+	suite.Equal(model.TransferCompleted, suite.getTransfer(result.Results[1].TransferID).Status)
+}
+
+func (suite *ChaincodeSuite) TestBatchTransferReportsPendingTransfers() {
+	suite.setupEscrow()
+	escrowed := `{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":300,"escrow":{"condition":"confirmation"}}`
+	paid := `{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":200}`
+	result := suite.batchTransfer(`{"mode":"best_effort","transfers":[` + escrowed + `,` + paid + `]}`)
+	suite.True(result.Committed)
+	suite.Equal(1, result.Succeeded, "Only completed transfers have succeeded")
+	suite.Equal(1, result.Pending)
+	suite.Equal(model.BatchItemPending, result.Results[0].Status)
+	suite.Equal(model.TransferPending, result.Results[0].TransferStatus)
+	suite.Equal(model.BatchItemSucceeded, result.Results[1].Status)
+	suite.Equal(model.TransferCompleted, result.Results[1].TransferStatus)
+	suite.Equal(int64(200), suite.getAccount("2", "5678").Balance)
+}
+
+func (suite *ChaincodeSuite) TestBatchTransferAllOrNothing() {
+	suite.setupBatch()
+	// the third transfer overdraws account 1234, which has been debited by the first
+	result := suite.batchTransfer(`{"mode":"all_or_nothing","transfers":[` + testBatchItem1 + `,` + testBatchItem3 + `,` + testBatchItem2 + `]}`)
+	suite.False(result.Committed)
+	suite.Equal(0, result.Succeeded)
+	suite.Equal(1, result.Failed)
+	suite.Equal(&model.BatchItemResult{Index: 0, Status: model.BatchItemRolledBack}, result.Results[0])
+	suite.Equal(&model.BatchItemResult{Index: 1, Status: model.BatchItemFailed, FailureCode: model.InsufficientFunds, Message: "Insufficient funds available in account 1234"}, result.Results[1])
+	suite.Equal(&model.BatchItemResult{Index: 2, Status: model.BatchItemRolledBack}, result.Results[2])
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(0, len(suite.getTransactions("1", "1234")))
+}
+
+func (suite *ChaincodeSuite) TestBatchTransferBestEffort() {
+	suite.setupBatch()
+	result := suite.batchTransfer(`{"mode":"best_effort","transfers":[` + testBatchItem1 + `,` + testBatchItem3 + `,{"from_customer":"1"},` + testBatchItem2 + `]}`)
+	suite.True(result.Committed)
+	suite.Equal(2, result.Succeeded)
+	suite.Equal(2, result.Failed)
+	suite.Equal(model.BatchItemSucceeded, result.Results[0].Status)
+	suite.Equal(model.InsufficientFunds, result.Results[1].FailureCode)
+	suite.Equal(model.TransferFailed, suite.getTransfer(result.Results[1].TransferID).Status)
+	suite.Equal(&model.BatchItemResult{Index: 2, Status: model.BatchItemFailed, FailureCode: model.InvalidTransfer, Message: "Missing required from_account value"}, result.Results[2])
+	suite.Equal(model.BatchItemSucceeded, result.Results[3].Status)
+	suite.Equal(int64(400), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(500), suite.getAccount("3", "9012").Balance)
+	suite.Equal(model.InsufficientFunds, suite.getFailedTransaction("1", "1234").FailureCode)
+}
+
+func (suite *ChaincodeSuite) TestBatchTransferUnknownAccount() {
+	suite.setupBatch()
+	result := suite.batchTransfer(`{"mode":"best_effort","transfers":[{"from_customer":"1","from_account":"1234","to_customer":"4","to_account":"0000","currency":"AUD","amount":100}]}`)
+	suite.Equal(&model.BatchItemResult{Index: 0, Status: model.BatchItemFailed, FailureCode: model.InvalidTransfer, Message: "Account with number 0000 not found."}, result.Results[0])
+}
@@ -28,13 +28,17 @@ import (
 	"os"
 	"sort"
 	"strconv"
-	"unicode/utf8"
+	"time"
 
+	"github.com/mschimk1/passport-chaincode/currency"
 	"github.com/mschimk1/passport-chaincode/model"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim" // v0.6
 )
 
+// maxAccountIDAttempts number of IDs generated for a new account before giving up
+const maxAccountIDAttempts = 10
+
 var (
 	// passport chaincode application logger
 	logger = shim.NewLogger("passport-chaincode")
@@ -54,7 +58,12 @@ func main() {
 }
 
 // Chaincode Chaincode shim method receiver struct
-type Chaincode struct{}
+type Chaincode struct {
+	// clock returns the time of the current transaction, defaults to the transaction timestamp
+	clock func(stub shim.ChaincodeStubInterface) (time.Time, error)
+	// attribute reads an attribute of the caller's enrollment certificate, defaults to the certificate of the transaction
+	attribute func(stub shim.ChaincodeStubInterface, name string) ([]byte, error)
+}
 
 //------------------------
 // Chaincode API functions
@@ -89,7 +98,8 @@ func (cc *Chaincode) handleInvocation(stub shim.ChaincodeStubInterface, function
 // Handler functions
 //------------------
 
-// GetAccountList query blockchain accounts by customer ID
+// GetAccountList query blockchain accounts by customer ID, including the accounts of other
+// customers the customer is a joint holder or signatory of
 func (cc *Chaincode) GetAccountList(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	logger.Debugf("Entering GetAccountList with args %v", args)
 
@@ -113,6 +123,12 @@ func (cc *Chaincode) GetAccountList(stub shim.ChaincodeStubInterface, args []str
 		}
 		accountList.Accounts = append(accountList.Accounts, acc)
 	}
+	partyAccounts, err := cc.getPartyAccounts(stub, customerID)
+	if err != nil {
+		logger.Errorf("Failed to get joint and delegated accounts. Error: %s", err)
+		return nil, err
+	}
+	accountList.Accounts = append(accountList.Accounts, partyAccounts...)
 	jsonList, _ := json.Marshal(accountList)
 	logger.Debugf("Returning account list: %s", jsonList)
 	return jsonList, nil
@@ -146,18 +162,65 @@ func (cc *Chaincode) OpenAccount(stub shim.ChaincodeStubInterface, args []string
 		return nil, errors.New("Missing required account data JSON")
 	}
 
-	account, err := model.CreateAccount([]byte(args[0]))
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	account, err := model.CreateAccount(src, []byte(args[0]))
 	if err != nil {
 		logger.Errorf("Error when creating new account. Error: %s", err)
 		return nil, fmt.Errorf("Error creating new account. Error: %s", err)
 	}
-	key, _ := cc.createCompositeKey(account.GetObjectType(), []string{account.CustomerID, account.ID})
-	accountData, _ := json.Marshal(account)
-	stub.PutState(key, accountData)
-
+	state := newTxState(stub)
+	customer, err := cc.getCustomer(state, account.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	openAccounts, err := cc.countOpenAccounts(stub, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := customer.CanOpenAccount(openAccounts, src.Now()); err != nil {
+		return nil, err
+	}
+	if err := cc.screenAccount(state, account); err != nil {
+		return nil, err
+	}
+	if err := cc.claimAccountID(state, src, account, jsonString("id")(args) == ""); err != nil {
+		return nil, err
+	}
+	accountData, err := cc.putAccount(state, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
 	return accountData, nil
 }
 
+// claimAccountID checks that no account of the customer exists with the ID of a new account.
+// A generated ID that is already taken is replaced by the next generated ID, up to
+// maxAccountIDAttempts times; an ID chosen by the caller must not be taken.
+func (cc *Chaincode) claimAccountID(state *txState, src model.Source, account *model.Account, generated bool) error {
+	for attempt := 0; attempt < maxAccountIDAttempts; attempt++ {
+		key, err := cc.createCompositeKey(model.AccountObjectType, []string{account.CustomerID, account.ID})
+		if err != nil {
+			return err
+		}
+		found, err := state.getObject(key, new(model.Account))
+		if err != nil || !found {
+			return err
+		}
+		if !generated {
+			return fmt.Errorf("Account %s of customer %s already exists", account.ID, account.CustomerID)
+		}
+		logger.Infof("Generated account ID %s of customer %s is taken, generating another", account.ID, account.CustomerID)
+		account.NewID(src)
+	}
+	return fmt.Errorf("Cannot generate an unused account ID for customer %s", account.CustomerID)
+}
+
 // TopupAccount update account balance
 func (cc *Chaincode) TopupAccount(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	logger.Debugf("Entering TopupAccount with args %v", args)
@@ -166,24 +229,71 @@ func (cc *Chaincode) TopupAccount(stub shim.ChaincodeStubInterface, args []strin
 		return nil, errors.New("Missing required input arguments")
 	}
 
-	accountData, err := cc.GetAccount(stub, args[:2])
+	src, err := cc.newSource(stub)
 	if err != nil {
 		return nil, err
 	}
-	if accountData == nil {
-		return nil, fmt.Errorf("Account with number %s not found.", args[1])
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
 	}
-	account := new(model.Account)
-	bytesToStruct([]byte(accountData), account)
 	amount, err := strconv.ParseInt(args[2], 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing amount value %s", args[2])
 	}
+	customer, err := cc.getCustomer(state, account.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := customer.CanTopup(src.Now()); err != nil {
+		return nil, err
+	}
+	logger.Debugf("Topping up account %s with %s", account.ID, currency.Format(amount, account.CurrencyCode))
 	account.Credit(amount)
-	key, _ := cc.createCompositeKey(account.GetObjectType(), []string{account.CustomerID, account.ID})
-	accountData, _ = json.Marshal(account)
-	stub.PutState(key, accountData)
+	accountData, err := cc.putAccount(state, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return accountData, nil
+}
+
+// UpdateOverdraftLimit sets the overdraft facility of an account. Args are the customer ID,
+// account ID and the limit in minor units of the account currency, 0 removes the facility.
+// Only bank operators can update overdraft limits.
+func (cc *Chaincode) UpdateOverdraftLimit(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering UpdateOverdraftLimit with args %v", args)
 
+	if len(args) != 3 {
+		return nil, errors.New("Missing required input arguments")
+	}
+
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Cannot update overdraft limit of closed account %s", account.ID)
+	}
+	limit, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing overdraft limit value %s", args[2])
+	}
+	if err := account.SetOverdraftLimit(limit); err != nil {
+		return nil, err
+	}
+	logger.Debugf("Setting overdraft limit of account %s to %s", account.ID, currency.Format(limit, account.CurrencyCode))
+	accountData, err := cc.putAccount(state, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
 	return accountData, nil
 }
 
@@ -195,77 +305,87 @@ func (cc *Chaincode) CloseAccount(stub shim.ChaincodeStubInterface, args []strin
 		return nil, errors.New("Missing required customer ID and / or account ID")
 	}
 
-	accountData, err := cc.GetAccount(stub, args[:2])
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, args[0], args[1])
 	if err != nil {
 		return nil, err
 	}
-	if accountData == nil {
-		return nil, fmt.Errorf("Account with number %s not found.", args[1])
-	}
-
-	account := new(model.Account)
-	bytesToStruct(accountData, account)
 	account.Closed = true
-	key, _ := cc.createCompositeKey(account.GetObjectType(), []string{account.CustomerID, account.ID})
-	accountData, _ = json.Marshal(account)
-	stub.PutState(key, accountData)
-
+	accountData, err := cc.putAccount(state, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
 	return accountData, nil
 }
 
-// TransferMoney transfer money
+// TransferMoney transfer money. The transfer is all-or-nothing: either both
+// accounts and transactions are written or, if any step fails, only a failed
+// transaction is recorded and the failed transfer is returned. Transfers with an
+// idempotency key are only executed once, retries return the result of the
+// original transfer.
+//
+// A rejected transfer is returned with status failed and no error, as the ledger
+// discards all writes of an invocation returning an error, including the failure
+// record. Callers must check the status of the returned transfer; an error is only
+// returned for invalid input or if the outcome cannot be recorded.
 func (cc *Chaincode) TransferMoney(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	logger.Debugf("Entering with args %v", args)
 
 	if len(args) == 0 {
 		return nil, errors.New("Missing transfer details JSON")
 	}
-	transferData := args[0]
 	t := new(model.Transfer)
-	bytesToStruct([]byte(transferData), t)
+	if err := bytesToStruct([]byte(args[0]), t); err != nil {
+		return nil, fmt.Errorf("Error parsing transfer details JSON. Error: %s", err)
+	}
 	if err := t.Validate(); err != nil {
 		return nil, err
 	}
-	accountData, err := cc.GetAccount(stub, []string{t.FromCustomerID, t.FromAccountID})
+	logger.Debugf("Transferring %s from account %s to account %s", currency.Format(t.Amount, t.CurrencyCode), t.FromAccountID, t.ToAccountID)
+	src, err := cc.newSource(stub)
 	if err != nil {
 		return nil, err
 	}
-	if accountData == nil {
-		return nil, fmt.Errorf("Account with number %s not found.", t.FromAccountID)
+
+	state := newTxState(stub)
+	requestHash := t.Hash()
+	if t.IdempotencyKey != "" {
+		record, err := cc.getIdempotencyRecord(state, t.FromCustomerID, t.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			logger.Infof("Replaying transfer with idempotency key %s", t.IdempotencyKey)
+			if record.RequestHash != requestHash {
+				return nil, fmt.Errorf("Idempotency key %s has already been used for a different transfer", t.IdempotencyKey)
+			}
+			return record.Result, nil
+		}
 	}
-	fromAccount := new(model.Account)
-	bytesToStruct(accountData, fromAccount)
-	accountData, err = cc.GetAccount(stub, []string{t.ToCustomerID, t.ToAccountID})
-	if err != nil {
+
+	t.Begin(src)
+	if err := cc.initiate(stub, t); err != nil {
 		return nil, err
 	}
-	if accountData == nil {
-		return nil, fmt.Errorf("Account with number %s not found.", t.ToAccountID)
+	if err := cc.transfer(state, src, t); err != nil {
+		return cc.failTransfer(stub, src, t, err)
 	}
-	toAccount := new(model.Account)
-	bytesToStruct(accountData, toAccount)
-
-	if fromAccount.Closed {
-		cc.recordTransaction(stub, fromAccount.CustomerID, fromAccount.ID, t, model.AccountClosed, model.Failed)
-		return nil, fmt.Errorf("Cannot transfer money from closed account %s", t.FromAccountID)
+	resultData, err := json.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling transfer. Error: %s", err)
 	}
-
-	if toAccount.Closed {
-		cc.recordTransaction(stub, toAccount.CustomerID, toAccount.ID, t, model.AccountClosed, model.Failed)
-		return nil, fmt.Errorf("Cannot transfer money into closed account %s", t.ToAccountID)
+	if t.IdempotencyKey != "" {
+		if err := cc.putIdempotencyRecord(state, src, t.FromCustomerID, t.IdempotencyKey, requestHash, resultData); err != nil {
+			return nil, err
+		}
 	}
-
-	if fromAccount.Balance-t.Amount < 0 {
-		cc.recordTransaction(stub, fromAccount.CustomerID, fromAccount.ID, t, model.InsufficientFunds, model.Failed)
-		return nil, fmt.Errorf("Insufficient funds available in account %s", t.FromAccountID)
+	if err := state.commit(); err != nil {
+		return nil, err
 	}
-
-	cc.debitAccount(stub, fromAccount, t.Amount+t.Fee)
-	cc.recordTransaction(stub, fromAccount.CustomerID, fromAccount.ID, t, "", model.Debited)
-	cc.creditAccount(stub, toAccount, t.Amount)
-	cc.recordTransaction(stub, toAccount.CustomerID, toAccount.ID, t, "", model.Credited)
-
-	return nil, nil
+	return resultData, nil
 }
 
 // GetTransactionList query blockchain accounts by account ID
@@ -322,31 +442,296 @@ func (cc *Chaincode) GetTransaction(stub shim.ChaincodeStubInterface, args []str
 	return txnBytes, nil
 }
 
-func (cc *Chaincode) recordTransaction(stub shim.ChaincodeStubInterface, customerID string, accountID string, t *model.Transfer, code model.TxFailureCode, status model.TxStatus) error {
-	txn, _ := model.CreateTransaction(customerID, accountID, t, code, status)
-	txnData, err := json.Marshal(txn)
+// GetTransfer returns a transfer with the status and transactions of its legs
+func (cc *Chaincode) GetTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetTransfer with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required transfer ID")
+	}
+
+	t, err := cc.getTransfer(newTxState(stub), args[0])
 	if err != nil {
-		return fmt.Errorf("Error marshalling transaction data. Error: %s", err)
+		return nil, err
 	}
-	key, _ := cc.createCompositeKey(txn.GetObjectType(), []string{txn.CustomerID, txn.AccountID, txn.ID})
-	stub.PutState(key, txnData)
-	return nil
+	return json.Marshal(t)
+}
+
+// transfer stages the account updates, transactions and the completed transfer record.
+// The funds of an escrowed transfer are deposited into the escrow account instead.
+func (cc *Chaincode) transfer(state *txState, src model.Source, t *model.Transfer) error {
+	fromAccount, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return err
+	}
+	credits, err := cc.getCredits(state, t)
+	if err != nil {
+		return err
+	}
+
+	if fromAccount.Closed {
+		return newTransferError(model.AccountClosed, fromAccount, nil, "Cannot transfer money from closed account %s", t.FromAccountID)
+	}
+	for _, c := range credits {
+		if c.account.Closed {
+			return newTransferError(model.AccountClosed, c.account, nil, "Cannot transfer money into closed account %s", c.account.ID)
+		}
+	}
+	if err := cc.checkCustomers(state, src, t, fromAccount, credits); err != nil {
+		return err
+	}
+	if t.CurrencyCode != fromAccount.CurrencyCode {
+		return fmt.Errorf("Transfer currency %s does not match currency %s of account %s", t.CurrencyCode, fromAccount.CurrencyCode, t.FromAccountID)
+	}
+	if t.Escrow != nil {
+		if err := t.Escrow.CheckDeadline(src.Now()); err != nil {
+			return newTransferError(model.InvalidTransfer, fromAccount, nil, "%s", err)
+		}
+	}
+
+	// Convert into the currency of the destination accounts using the rates for today.
+	// Escrowed funds are converted when they are released.
+	for _, c := range credits {
+		if c.account.CurrencyCode != fromAccount.CurrencyCode && t.Escrow == nil {
+			date := src.Now().UTC().Format(model.RatesDateFormat)
+			c.conversion, err = cc.convert(state.stub, c.payee.Share, fromAccount.CurrencyCode, c.account.CurrencyCode, date)
+			if err != nil {
+				return newTransferError(model.RatesUnavailable, fromAccount, nil, "%s", err)
+			}
+		}
+	}
+	// the debit leg of a split transfer has no single conversion
+	var conversion *model.Conversion
+	if !t.IsSplit() {
+		conversion = credits[0].conversion
+	}
+
+	// Fees are always calculated from the fee schedule, never taken from the request.
+	// The corridor of a split transfer is determined by its first payee.
+	schedule, err := cc.getFeeSchedule(state)
+	if err != nil {
+		return err
+	}
+	t.Fee = cc.calculateFee(schedule, t, fromAccount, credits[0].account)
+	// transfers are approved by the account's approvers before they are screened
+	held, err := cc.holdForApproval(state, src, t, fromAccount)
+	if err != nil || held {
+		return err
+	}
+	// transfers approved in review are not screened again
+	if t.Review == nil {
+		held, err := cc.holdForReview(state, src, t, fromAccount, credits)
+		if err != nil || held {
+			return err
+		}
+	}
+	if err := cc.checkLimits(state, src, fromAccount, credits); err != nil {
+		return err
+	}
+
+	if err := cc.expireHolds(state, src, fromAccount); err != nil {
+		return err
+	}
+	if fromAccount.Available()-(t.Amount+t.Fee) < 0 {
+		return newTransferError(fromAccount.FundsFailureCode(), fromAccount, conversion, "Insufficient funds available in account %s", t.FromAccountID)
+	}
+
+	if err := cc.debitAccount(state, fromAccount, t.Amount+t.Fee); err != nil {
+		return err
+	}
+	debit, err := cc.recordTransaction(state, src, fromAccount.CustomerID, fromAccount.ID, t, conversion, "", model.Debited)
+	if err != nil {
+		return err
+	}
+	t.AddLeg(debit)
+	if t.Escrow != nil {
+		if err := cc.depositEscrow(state, src, t); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range credits {
+			credit, err := cc.credit(state, src, t, c)
+			if err != nil {
+				return err
+			}
+			t.AddLeg(credit)
+		}
+	}
+	if t.Fee > 0 {
+		fee, err := cc.collectFee(state, src, schedule, t)
+		if err != nil {
+			return err
+		}
+		t.AddLeg(fee)
+	}
+	// escrowed transfers stay pending until they are released or refunded
+	if t.Escrow == nil {
+		t.Complete()
+	}
+	return cc.putTransfer(state, t)
+}
+
+// payeeCredit is the credit of a transfer to one of its payee accounts
+type payeeCredit struct {
+	payee      model.Payee
+	account    *model.Account
+	conversion *model.Conversion
+}
+
+// getCredits returns the credits of a transfer: one for each payee of a split transfer,
+// otherwise a single credit of the transfer amount to the destination account
+func (cc *Chaincode) getCredits(state *txState, t *model.Transfer) ([]*payeeCredit, error) {
+	payees := []model.Payee{{CustomerID: t.ToCustomerID, AccountID: t.ToAccountID, Share: t.Amount}}
+	if t.IsSplit() {
+		if err := t.Split(); err != nil {
+			return nil, err
+		}
+		payees = t.Payees
+	}
+	credits := make([]*payeeCredit, len(payees))
+	for i, p := range payees {
+		account, err := cc.getAccount(state, p.CustomerID, p.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		credits[i] = &payeeCredit{payee: p, account: account}
+	}
+	return credits, nil
 }
 
-func (cc *Chaincode) debitAccount(stub shim.ChaincodeStubInterface, a *model.Account, amount int64) error {
+// credit stages the credit of a payee's share of a transfer and records the credited transaction
+func (cc *Chaincode) credit(state *txState, src model.Source, t *model.Transfer, c *payeeCredit) (*model.Transaction, error) {
+	amount := c.payee.Share
+	if c.conversion != nil {
+		amount = c.conversion.DestinationAmount
+	}
+	if err := cc.creditAccount(state, c.account, amount); err != nil {
+		return nil, err
+	}
+	txn, err := model.CreatePayeeTransaction(src, c.payee, t, c.conversion)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating transaction. Error: %s", err)
+	}
+	return txn, cc.putTransaction(state, txn)
+}
+
+// failTransfer records a failed transaction and the failed transfer for a transfer rejected
+// with a TransferError and returns the failed transfer. The invocation succeeds, as the
+// ledger discards all writes of a failed invocation. Other errors are returned as they are.
+func (cc *Chaincode) failTransfer(stub shim.ChaincodeStubInterface, src model.Source, t *model.Transfer, err error) ([]byte, error) {
+	if _, ok := err.(*TransferError); !ok {
+		return nil, err
+	}
+	logger.Infof("Transfer %s failed. Error: %s", t.ID, err)
+	state := newTxState(stub)
+	if err := cc.stageFailure(state, src, t, err); err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// stageFailure stages the failed transaction and the failed transfer for a transfer
+// rejected with a TransferError. Other errors are not recorded.
+func (cc *Chaincode) stageFailure(state *txState, src model.Source, t *model.Transfer, err error) error {
+	terr, ok := err.(*TransferError)
+	if !ok {
+		return nil
+	}
+	t.Legs = nil // legs staged before the failure are discarded
+	t.Fail(terr.Code, terr.Error())
+	txn, err := cc.recordTransaction(state, src, terr.CustomerID, terr.AccountID, t, terr.Conversion, terr.Code, model.Failed)
+	if err != nil {
+		return err
+	}
+	t.AddLeg(txn)
+	return cc.putTransfer(state, t)
+}
+
+func (cc *Chaincode) recordTransaction(state *txState, src model.Source, customerID string, accountID string, t *model.Transfer, c *model.Conversion, code model.TxFailureCode, status model.TxStatus) (*model.Transaction, error) {
+	txn, err := model.CreateTransaction(src, customerID, accountID, t, c, code, status)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating transaction. Error: %s", err)
+	}
+	return txn, cc.putTransaction(state, txn)
+}
+
+// putTransaction stages a write of the transaction
+func (cc *Chaincode) putTransaction(state *txState, txn *model.Transaction) error {
+	key, err := cc.createCompositeKey(txn.GetObjectType(), []string{txn.CustomerID, txn.AccountID, txn.ID})
+	if err != nil {
+		return err
+	}
+	_, err = state.putObject(key, txn)
+	return err
+}
+
+// getTransfer reads a transfer from the (staged) state
+func (cc *Chaincode) getTransfer(state *txState, transferID string) (*model.Transfer, error) {
+	key, err := cc.createCompositeKey(model.TransferObjectType, []string{transferID})
+	if err != nil {
+		return nil, err
+	}
+	t := new(model.Transfer)
+	found, err := state.getObject(key, t)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Transfer with ID %s not found", transferID)
+	}
+	return t, nil
+}
+
+// putTransfer stages a write of the transfer
+func (cc *Chaincode) putTransfer(state *txState, t *model.Transfer) error {
+	key, err := cc.createCompositeKey(t.GetObjectType(), []string{t.ID})
+	if err != nil {
+		return err
+	}
+	_, err = state.putObject(key, t)
+	return err
+}
+
+// getAccount reads an account from the (staged) state
+func (cc *Chaincode) getAccount(state *txState, customerID string, accountID string) (*model.Account, error) {
+	key, err := cc.createCompositeKey(model.AccountObjectType, []string{customerID, accountID})
+	if err != nil {
+		return nil, err
+	}
+	account := new(model.Account)
+	found, err := state.getObject(key, account)
+	if err != nil {
+		logger.Errorf("Failed to get account details. Error: %s", err)
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Account with number %s not found.", accountID)
+	}
+	return account, nil
+}
+
+// putAccount stages a write of the account and returns its JSON representation
+func (cc *Chaincode) putAccount(state *txState, a *model.Account) ([]byte, error) {
+	key, err := cc.createCompositeKey(a.GetObjectType(), []string{a.CustomerID, a.ID})
+	if err != nil {
+		return nil, err
+	}
+	return state.putObject(key, a)
+}
+
+func (cc *Chaincode) debitAccount(state *txState, a *model.Account, amount int64) error {
 	a.Debit(amount)
-	accountData, _ := json.Marshal(a)
-	key, _ := cc.createCompositeKey(a.GetObjectType(), []string{a.CustomerID, a.ID})
-	stub.PutState(key, accountData)
-	return nil
+	_, err := cc.putAccount(state, a)
+	return err
 }
 
-func (cc *Chaincode) creditAccount(stub shim.ChaincodeStubInterface, a *model.Account, amount int64) error {
+func (cc *Chaincode) creditAccount(state *txState, a *model.Account, amount int64) error {
 	a.Credit(amount)
-	accountData, _ := json.Marshal(a)
-	key, _ := cc.createCompositeKey(a.GetObjectType(), []string{a.CustomerID, a.ID})
-	stub.PutState(key, accountData)
-	return nil
+	_, err := cc.putAccount(state, a)
+	return err
 }
 
 //-------------------------------------------------
@@ -358,37 +743,102 @@ func initLogging() {
 	shim.SetLoggingLevel(logLevel)
 }
 
-// Registers handler function mappings
+// Registers handler function mappings with the policies authorising their callers
 func (cc *Chaincode) registerHandlers() {
-	handlerMap.Add("OpenAccount", cc.OpenAccount)
-	handlerMap.Add("CloseAccount", cc.CloseAccount)
-	handlerMap.Add("GetAccount", cc.GetAccount)
-	handlerMap.Add("GetAccountList", cc.GetAccountList)
-	handlerMap.Add("TransferMoney", cc.TransferMoney)
-	handlerMap.Add("TopupAccount", cc.TopupAccount)
-	handlerMap.Add("GetTransaction", cc.GetTransaction)
-	handlerMap.Add("GetTransactionList", cc.GetTransactionList)
+	var (
+		anyone       = cc.allowRoles(CustomerRole, BankOperatorRole, ComplianceRole, AuditorRole)
+		operator     = cc.allowRoles(BankOperatorRole)
+		compliance   = cc.allowRoles(ComplianceRole)
+		ownerOrStaff = cc.allowOwner(argString(0), staffRoles...)
+		viewer       = cc.allowAccountParty(argString(0), argString(1), withPermission(model.ViewPermission), staffRoles...)
+		manager      = cc.allowAccountParty(argString(0), argString(1), withPermission(model.FullPermission), BankOperatorRole)
+		holder       = cc.allowAccountParty(argString(0), argString(1), isHolder, BankOperatorRole)
+		payer        = cc.allowAccountParty(jsonString("from_customer"), jsonString("from_account"), canPay, BankOperatorRole)
+	)
+	handlerMap.Add("RegisterCustomer", cc.RegisterCustomer, operator)
+	handlerMap.Add("GetCustomer", cc.GetCustomer, ownerOrStaff)
+	handlerMap.Add("UpdateCustomer", cc.UpdateCustomer, operator)
+	handlerMap.Add("DeactivateCustomer", cc.DeactivateCustomer, operator)
+	handlerMap.Add("RecordKYCResult", cc.RecordKYCResult, cc.allowRoles(KYCVerifierRole))
+	handlerMap.Add("OpenAccount", cc.OpenAccount, operator)
+	handlerMap.Add("CloseAccount", cc.CloseAccount, manager)
+	handlerMap.Add("GetAccount", cc.GetAccount, viewer)
+	handlerMap.Add("GetAccountList", cc.GetAccountList, ownerOrStaff)
+	handlerMap.Add("AddJointHolder", cc.AddJointHolder, operator)
+	handlerMap.Add("SetSignatory", cc.SetSignatory, holder)
+	handlerMap.Add("RemoveAccountParty", cc.RemoveAccountParty, holder)
+	handlerMap.Add("SetApprovalPolicy", cc.SetApprovalPolicy, holder)
+	handlerMap.Add("TransferMoney", cc.TransferMoney, payer)
+	handlerMap.Add("TopupAccount", cc.TopupAccount, operator)
+	handlerMap.Add("UpdateOverdraftLimit", cc.UpdateOverdraftLimit, operator)
+	handlerMap.Add("SetTransferLimits", cc.SetTransferLimits, operator)
+	handlerMap.Add("GetTransferLimits", cc.GetTransferLimits, anyone)
+	handlerMap.Add("GetLimitUsage", cc.GetLimitUsage, ownerOrStaff)
+	handlerMap.Add("SetWatchList", cc.SetWatchList, compliance)
+	handlerMap.Add("GetWatchList", cc.GetWatchList, compliance)
+	handlerMap.Add("SetReviewRules", cc.SetReviewRules, compliance)
+	handlerMap.Add("GetReviewRules", cc.GetReviewRules, cc.allowRoles(ComplianceRole, AuditorRole))
+	handlerMap.Add("ApproveTransfer", cc.ApproveTransfer, cc.allowRoles(ComplianceRole, CustomerRole))
+	handlerMap.Add("RejectTransfer", cc.RejectTransfer, compliance)
+	handlerMap.Add("ListPendingReviews", cc.ListPendingReviews, compliance)
+	handlerMap.Add("ListPendingApprovals", cc.ListPendingApprovals, ownerOrStaff)
+	handlerMap.Add("ExpirePendingApprovals", cc.ExpirePendingApprovals, operator)
+	handlerMap.Add("GetTransaction", cc.GetTransaction, viewer)
+	handlerMap.Add("GetTransactionList", cc.GetTransactionList, viewer)
+	handlerMap.Add("GetTransfer", cc.GetTransfer, cc.allowTransferParty(staffRoles...))
+	handlerMap.Add("ReverseTransfer", cc.ReverseTransfer, operator)
+	handlerMap.Add("PlaceHold", cc.PlaceHold, cc.allowAccountParty(jsonString("customer_id"), jsonString("account_id"), withPermission(model.FullPermission), BankOperatorRole))
+	handlerMap.Add("CaptureHold", cc.CaptureHold, manager)
+	handlerMap.Add("ReleaseHold", cc.ReleaseHold, manager)
+	handlerMap.Add("GetHold", cc.GetHold, viewer)
+	handlerMap.Add("GetHoldList", cc.GetHoldList, viewer)
+	handlerMap.Add("GetAccountBalance", cc.GetAccountBalance, viewer)
+	handlerMap.Add("SetEscrowAccount", cc.SetEscrowAccount, operator)
+	handlerMap.Add("ReleaseEscrow", cc.ReleaseEscrow, cc.allowTransferParty(BankOperatorRole))
+	handlerMap.Add("RefundEscrow", cc.RefundEscrow, cc.allowTransferParty(BankOperatorRole))
+	handlerMap.Add("ScheduleTransfer", cc.ScheduleTransfer, cc.allowAccountParty(jsonString("transfer", "from_customer"), jsonString("transfer", "from_account"), withPermission(model.FullPermission), BankOperatorRole))
+	handlerMap.Add("CancelScheduledTransfer", cc.CancelScheduledTransfer, cc.allowScheduleParty(withPermission(model.FullPermission), BankOperatorRole))
+	handlerMap.Add("ListScheduledTransfers", cc.ListScheduledTransfers, ownerOrStaff)
+	handlerMap.Add("ExecuteDueTransfers", cc.ExecuteDueTransfers, operator)
+	handlerMap.Add("BatchTransfer", cc.BatchTransfer, operator)
+	handlerMap.Add("PublishRates", cc.PublishRates, operator)
+	handlerMap.Add("GetRates", cc.GetRates, anyone)
+	handlerMap.Add("GetLatestRates", cc.GetLatestRates, anyone)
+	handlerMap.Add("GetRatesHistory", cc.GetRatesHistory, anyone)
+	handlerMap.Add("SetRatesConfig", cc.SetRatesConfig, operator)
+	handlerMap.Add("GetRatesConfig", cc.GetRatesConfig, anyone)
+	handlerMap.Add("MigrateKeys", cc.MigrateKeys, cc.allowRoles(AdminRole))
+	handlerMap.Add("SetFeeSchedule", cc.SetFeeSchedule, operator)
+	handlerMap.Add("GetFeeSchedule", cc.GetFeeSchedule, anyone)
 }
 
 // Helper functions
 
-func (cc *Chaincode) createCompositeKey(objectType string, attributes []string) (string, error) {
-	const minKeyValue = "0"
-	key := objectType + minKeyValue
-	for _, att := range attributes {
-		key += att + minKeyValue
+// newSource creates the source of timestamps and identifiers for new entities of the
+// current transaction. Both are derived from the transaction so that all endorsing
+// peers create the same entities.
+func (cc *Chaincode) newSource(stub shim.ChaincodeStubInterface) (model.Source, error) {
+	clock := cc.clock
+	if clock == nil {
+		clock = txTimestamp
+	}
+	now, err := clock(stub)
+	if err != nil {
+		return nil, err
 	}
-	logger.Debugf("Created composite key: %s", key)
-	return key, nil
+	return model.NewSequenceSource(stub.GetTxID(), now), nil
 }
 
-func (cc *Chaincode) partialCompositeKeyQuery(stub shim.ChaincodeStubInterface, objectType string, keys []string) (shim.StateRangeQueryIteratorInterface, error) {
-	partialCompositeKey, _ := cc.createCompositeKey(objectType, keys)
-	keysIter, err := stub.RangeQueryState(partialCompositeKey, partialCompositeKey+string(utf8.MaxRune))
+// txTimestamp returns the timestamp of the current transaction
+func txTimestamp(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
 	if err != nil {
-		return nil, fmt.Errorf("Error fetching rows: %s", err)
+		return time.Time{}, fmt.Errorf("Error getting transaction timestamp. Error: %s", err)
+	}
+	if ts == nil {
+		return time.Time{}, errors.New("Transaction timestamp not available")
 	}
-	return keysIter, nil
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
 }
 
 // bytesToStruct unmarshals byte slice into given data type
@@ -2,9 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"passport-chaincode/model"
 	"testing"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/stretchr/testify/suite"
@@ -19,12 +22,24 @@ type ChaincodeSuite struct {
 	suite.Suite
 	cc   *Chaincode
 	stub *shim.MockStub
+	now  time.Time
+
+	txCount int
 }
 
 func (suite *ChaincodeSuite) SetupTest() {
+	suite.now = time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC)
 	suite.cc = new(Chaincode)
+	suite.cc.clock = func(stub shim.ChaincodeStubInterface) (time.Time, error) {
+		return suite.now, nil
+	}
+	suite.asRole(BankOperatorRole)
 	suite.cc.registerHandlers()
 	suite.stub = shim.NewMockStub("mockStub", suite.cc)
+	// customers of the test accounts opened with OpenAccount directly
+	suite.registerCustomer("1")
+	suite.registerCustomer("2")
+	suite.registerCustomer("10")
 }
 
 func (suite *ChaincodeSuite) checkState(name string, value string) {
@@ -47,6 +62,50 @@ func (suite *ChaincodeSuite) checkQuery(name string, value string) {
 	suite.Equal(value, string(bytes), "Query value "+name+"was not as expected")
 }
 
+// openAccount opens a test account with the given details
+func (suite *ChaincodeSuite) openAccount(customerID string, accountID string, country string, currencyCode string, balance int64) {
+	suite.registerCustomer(customerID)
+	account := fmt.Sprintf(`{"id":"%s","customer_id":"%s","bank_name":"Test Bank","account_holder":"Customer %s","country":"%s","currency":"%s","balance":%d}`,
+		accountID, customerID, customerID, country, currencyCode, balance)
+	_, err := suite.stub.MockInvoke("t0", "OpenAccount", []string{account})
+	suite.Nil(err, "Failed to open account "+accountID)
+}
+
+// getAccount returns the current state of an account
+func (suite *ChaincodeSuite) getAccount(customerID string, accountID string) *model.Account {
+	accountBytes, err := suite.stub.MockInvoke("t0", "GetAccount", []string{customerID, accountID})
+	suite.Nil(err)
+	account := new(model.Account)
+	json.Unmarshal(accountBytes, account)
+	return account
+}
+
+// getTransactions returns the transactions of an account, latest first
+func (suite *ChaincodeSuite) getTransactions(customerID string, accountID string) []*model.Transaction {
+	txnBytes, err := suite.stub.MockInvoke("t0", "GetTransactionList", []string{customerID, accountID})
+	suite.Nil(err)
+	txnList := new(model.TransactionList)
+	json.Unmarshal(txnBytes, txnList)
+	return txnList.Transactions
+}
+
+// getTransfer returns a transfer by ID
+func (suite *ChaincodeSuite) getTransfer(transferID string) *model.Transfer {
+	transferBytes, err := suite.stub.MockInvoke("t0", "GetTransfer", []string{transferID})
+	suite.Nil(err)
+	t := new(model.Transfer)
+	json.Unmarshal(transferBytes, t)
+	return t
+}
+
+// failureReason returns the failure reason of the transfer returned by an invocation,
+// empty unless the transfer failed
+func (suite *ChaincodeSuite) failureReason(res []byte) string {
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	return t.FailureReason
+}
+
 func (suite *ChaincodeSuite) checkInvoke(function string, args []string) {
 	_, err := suite.stub.MockInvoke("t1234", function, args)
 	suite.Nil(err, "Invoke failed")
@@ -61,7 +120,52 @@ func (suite *ChaincodeSuite) TestOpenAccount() {
 	testAccount := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
 	_, err := suite.stub.MockInvoke("t1234", "OpenAccount", []string{testAccount})
 	suite.Nil(err)
-	suite.checkState("Account01012340", testAccount)
+	suite.checkState("Account\x001\x001234\x00", testAccount)
+}
+
+func (suite *ChaincodeSuite) TestOpenAccountGeneratesDeterministicID() {
+	testAccount := `{"customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","country":"AU","currency":"AUD","balance":1000}`
+	account1, _ := suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount})
+	otherStub := shim.NewMockStub("otherPeer", suite.cc)
+	otherStub.MockInvoke("t0", "RegisterCustomer", []string{`{"id":"1","name":"John Smith","country":"AU","kyc_status":"verified"}`})
+	suite.asVerifier("Test Verifier")
+	otherStub.MockInvoke("t0", "RecordKYCResult", []string{"1", fmt.Sprintf(`{"level":"full","document_type":"passport","expires":%d}`, suite.now.AddDate(1, 0, 0).Unix())})
+	suite.asRole(BankOperatorRole)
+	account2, _ := otherStub.MockInvoke("t1", "OpenAccount", []string{testAccount})
+	suite.Equal(string(account1), string(account2))
+}
+
+func (suite *ChaincodeSuite) TestOpenAccountExistingID() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	testAccount := `{"id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","country":"AU","currency":"AUD","balance":0}`
+	_, err := suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount})
+	suite.Equal("Account 1234 of customer 1 already exists", err.Error())
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestOpenAccountRegeneratesTakenID() {
+	// the ID generated first in transaction t1 is already taken
+	src := model.NewSequenceSource("t1", suite.now)
+	taken := &model.Account{}
+	taken.NewID(src)
+	suite.openAccount("1", taken.ID, "AU", "AUD", 1000)
+
+	testAccount := `{"customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","country":"AU","currency":"AUD","balance":0}`
+	res, err := suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount})
+	suite.Nil(err)
+	account := new(model.Account)
+	json.Unmarshal(res, account)
+	next := &model.Account{}
+	next.NewID(src)
+	suite.Equal(next.ID, account.ID)
+	suite.Equal(int64(1000), suite.getAccount("1", taken.ID).Balance)
+}
+
+func (suite *ChaincodeSuite) TestOpenAccountWithoutTimestamp() {
+	suite.cc.clock = nil
+	testAccount := `{"customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","country":"AU","currency":"AUD","balance":1000}`
+	_, err := suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount})
+	suite.Equal("Transaction timestamp not available", err.Error())
 }
 
 func (suite *ChaincodeSuite) TestGetAccountListValidation() {
@@ -171,8 +275,9 @@ func (suite *ChaincodeSuite) TestTransferMoneyInsufficientFunds() {
 
 	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "1", "to_account":"5678", "currency":"AUD", "amount":1000}`
 
-	_, err := suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
-	suite.Equal("Insufficient funds available in account 1234", err.Error())
+	res, err := suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal("Insufficient funds available in account 1234", suite.failureReason(res))
 }
 
 func (suite *ChaincodeSuite) TestTransferMoneyClosedFromAccount() {
@@ -184,8 +289,9 @@ func (suite *ChaincodeSuite) TestTransferMoneyClosedFromAccount() {
 
 	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "1", "to_account":"5678", "currency":"AUD", "amount":1000}`
 
-	_, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
-	suite.Equal("Cannot transfer money from closed account 1234", err.Error())
+	res, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal("Cannot transfer money from closed account 1234", suite.failureReason(res))
 }
 
 func (suite *ChaincodeSuite) TestTransferMoneyClosedToAccount() {
@@ -197,8 +303,9 @@ func (suite *ChaincodeSuite) TestTransferMoneyClosedToAccount() {
 
 	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "1", "to_account":"5678", "currency":"AUD", "amount":1000}`
 
-	_, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
-	suite.Equal("Cannot transfer money into closed account 5678", err.Error())
+	res, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal("Cannot transfer money into closed account 5678", suite.failureReason(res))
 }
 
 func (suite *ChaincodeSuite) TestGetTransactionListValidation() {
@@ -249,3 +356,171 @@ func (suite *ChaincodeSuite) TestGetTransaction() {
 	suite.NotNil(tran)
 	fmt.Println(string(tran))
 }
+
+func (suite *ChaincodeSuite) TestTransferMoneyReturnsTransfer() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400}`
+	res, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	suite.NotEmpty(t.ID)
+	suite.Equal(model.TransferCompleted, t.Status)
+	suite.Equal(2, len(t.Legs))
+
+	debit := suite.getTransactions("1", "1234")[0]
+	credit := suite.getTransactions("2", "5678")[0]
+	suite.Equal(model.TransferLeg{TransactionID: debit.ID, CustomerID: "1", AccountID: "1234", Status: model.Debited}, t.Legs[0])
+	suite.Equal(model.TransferLeg{TransactionID: credit.ID, CustomerID: "2", AccountID: "5678", Status: model.Credited}, t.Legs[1])
+	suite.Equal(t.ID, debit.TransferID)
+	suite.Equal(t.ID, credit.TransferID)
+	suite.Equal(t, suite.getTransfer(t.ID))
+}
+
+func (suite *ChaincodeSuite) TestGetTransferValidation() {
+	_, err := suite.stub.MockInvoke("t1", "GetTransfer", []string{})
+	suite.Equal("Missing required transfer ID", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "GetTransfer", []string{"unknown"})
+	suite.Equal("Transfer with ID unknown not found", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyRecordsFailedTransfer() {
+	suite.openAccount("1", "1234", "AU", "AUD", 100)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400}`
+	res, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.NotEmpty(suite.failureReason(res))
+
+	failed := suite.getTransactions("1", "1234")[0]
+	t := suite.getTransfer(failed.TransferID)
+	suite.Equal(model.TransferFailed, t.Status)
+	suite.Equal(model.InsufficientFunds, t.FailureCode)
+	suite.Equal([]model.TransferLeg{{TransactionID: failed.ID, CustomerID: "1", AccountID: "1234", Status: model.Failed}}, t.Legs)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyCrossCurrency() {
+	testAccount1 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+	testAccount2 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"5678","customer_id":"2","bank_name":"Test Bank","account_holder":"Jane Smith","description":"","country":"NZ","currency":"NZD","balance":1000,"default_account":true,"closed":false}`
+	rates := `{"base":"AUD","date":"2017-08-15","rates":{"HKD":6.1484,"NZD":1.0793,"SGD":1.0729}}`
+
+	suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount1})
+	suite.stub.MockInvoke("t2", "OpenAccount", []string{testAccount2})
+	suite.stub.MockInvoke("t2", "PublishRates", []string{rates})
+
+	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "2", "to_account":"5678", "currency":"AUD", "amount":1000}`
+
+	_, err := suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+
+	account1, _ := suite.stub.MockInvoke("t4", "GetAccount", []string{"1", "1234"})
+	account2, _ := suite.stub.MockInvoke("t4", "GetAccount", []string{"2", "5678"})
+	a1 := new(model.Account)
+	a2 := new(model.Account)
+	json.Unmarshal(account1, a1)
+	json.Unmarshal(account2, a2)
+	suite.Equal(int64(0), a1.Balance)
+	suite.Equal(int64(2079), a2.Balance)
+
+	transactions, _ := suite.stub.MockInvoke("t4", "GetTransactionList", []string{"2", "5678"})
+	txnList := new(model.TransactionList)
+	json.Unmarshal(transactions, txnList)
+	credit := txnList.Transactions[0]
+	suite.Equal(int64(1079), credit.Amount)
+	suite.Equal("NZD", credit.CurrencyCode)
+	suite.Equal(&model.Conversion{Rate: model.MustParseDecimal("1.0793"), SourceCurrency: "AUD", SourceAmount: 1000, DestinationCurrency: "NZD", DestinationAmount: 1079}, credit.Conversion)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyCrossCurrencyWithoutRates() {
+	testAccount1 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+	testAccount2 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"5678","customer_id":"2","bank_name":"Test Bank","account_holder":"Jane Smith","description":"","country":"SG","currency":"SGD","balance":1000,"default_account":true,"closed":false}`
+
+	suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount1})
+	suite.stub.MockInvoke("t2", "OpenAccount", []string{testAccount2})
+
+	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "2", "to_account":"5678", "currency":"AUD", "amount":1000}`
+
+	res, err := suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Contains(suite.failureReason(res), "No exchange rates available for base AUD")
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyInverseRate() {
+	testAccount1 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"NZ","currency":"NZD","balance":1079,"default_account":true,"closed":false}`
+	testAccount2 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"5678","customer_id":"2","bank_name":"Test Bank","account_holder":"Jane Smith","description":"","country":"AU","currency":"AUD","balance":0,"default_account":true,"closed":false}`
+	rates := `{"base":"AUD","date":"2017-08-15","rates":{"HKD":6.1484,"NZD":1.0793,"SGD":1.0729}}`
+
+	suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount1})
+	suite.stub.MockInvoke("t2", "OpenAccount", []string{testAccount2})
+	suite.stub.MockInvoke("t2", "PublishRates", []string{rates})
+
+	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "2", "to_account":"5678", "currency":"NZD", "amount":1079}`
+
+	_, err := suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+
+	account2, _ := suite.stub.MockInvoke("t4", "GetAccount", []string{"2", "5678"})
+	a2 := new(model.Account)
+	json.Unmarshal(account2, a2)
+	suite.Equal(int64(1000), a2.Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyInvalidJSON() {
+	_, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{`{"from_customer": "1",`})
+	suite.Contains(err.Error(), "Error parsing transfer details JSON")
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyFailureIsAtomic() {
+	testAccount1 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":100,"default_account":true,"closed":false}`
+	testAccount2 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"5678","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+
+	suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount1})
+	suite.stub.MockInvoke("t2", "OpenAccount", []string{testAccount2})
+
+	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "1", "to_account":"5678", "currency":"AUD", "amount":1000}`
+	res, err := suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	failed := new(model.Transfer)
+	json.Unmarshal(res, failed)
+	suite.Equal(model.InsufficientFunds, failed.FailureCode)
+
+	account1, _ := suite.stub.MockInvoke("t4", "GetAccount", []string{"1", "1234"})
+	account2, _ := suite.stub.MockInvoke("t4", "GetAccount", []string{"1", "5678"})
+	suite.Equal(testAccount1, string(account1))
+	suite.Equal(testAccount2, string(account2))
+
+	transactions, _ := suite.stub.MockInvoke("t4", "GetTransactionList", []string{"1", "1234"})
+	txnList := new(model.TransactionList)
+	json.Unmarshal(transactions, txnList)
+	suite.Equal(1, len(txnList.Transactions))
+	suite.Equal(model.Failed, txnList.Transactions[0].Status)
+	suite.Equal(model.InsufficientFunds, txnList.Transactions[0].FailureCode)
+	transactions, _ = suite.stub.MockInvoke("t4", "GetTransactionList", []string{"1", "5678"})
+	txnList = new(model.TransactionList)
+	json.Unmarshal(transactions, txnList)
+	suite.Equal(0, len(txnList.Transactions))
+}
+
+// failingStub fails every write to the ledger
+type failingStub struct {
+	*shim.MockStub
+}
+
+func (s *failingStub) PutState(key string, value []byte) error {
+	return errors.New("write failed")
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyPropagatesWriteErrors() {
+	testAccount1 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+	testAccount2 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"5678","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+
+	suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount1})
+	suite.stub.MockInvoke("t2", "OpenAccount", []string{testAccount2})
+
+	transfer := `{"from_customer": "1", "from_account": "1234", "to_customer": "1", "to_account":"5678", "currency":"AUD", "amount":1000}`
+	_, err := suite.cc.TransferMoney(&failingStub{suite.stub}, []string{transfer})
+	suite.Contains(err.Error(), "write failed")
+}
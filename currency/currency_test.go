@@ -0,0 +1,46 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	c, err := Lookup("AUD")
+	assert.Nil(t, err)
+	assert.Equal(t, Currency{"AUD", 2}, c)
+}
+
+func TestLookupMinorUnits(t *testing.T) {
+	jpy, _ := Lookup("JPY")
+	kwd, _ := Lookup("KWD")
+	assert.Equal(t, 0, jpy.MinorUnits)
+	assert.Equal(t, 3, kwd.MinorUnits)
+}
+
+func TestLookupUnknown(t *testing.T) {
+	_, err := Lookup("XYZ")
+	assert.Equal(t, "Invalid currency code XYZ", err.Error())
+}
+
+func TestIsValid(t *testing.T) {
+	assert.True(t, IsValid("NZD"))
+	assert.False(t, IsValid("nzd"))
+	assert.False(t, IsValid(""))
+}
+
+func TestFactor(t *testing.T) {
+	assert.Equal(t, int64(1), Currency{"JPY", 0}.Factor())
+	assert.Equal(t, int64(100), Currency{"AUD", 2}.Factor())
+	assert.Equal(t, int64(1000), Currency{"KWD", 3}.Factor())
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "10.50 AUD", Format(1050, "AUD"))
+	assert.Equal(t, "0.05 AUD", Format(5, "AUD"))
+	assert.Equal(t, "-1.00 AUD", Format(-100, "AUD"))
+	assert.Equal(t, "1050 JPY", Format(1050, "JPY"))
+	assert.Equal(t, "1.050 KWD", Format(1050, "KWD"))
+	assert.Equal(t, "1050 XYZ", Format(1050, "XYZ"))
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// RegisterCustomer registers a new customer. The customer details are provided as a JSON
+// string with a unique id, the name and the country of residence.
+func (cc *Chaincode) RegisterCustomer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering RegisterCustomer with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required customer data JSON")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	customer, err := model.CreateUser(src, []byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when registering customer. Error: %s", err)
+		return nil, fmt.Errorf("Error registering customer. Error: %s", err)
+	}
+	state := newTxState(stub)
+	key, err := cc.createCompositeKey(model.UserObjectType, []string{customer.ID})
+	if err != nil {
+		return nil, err
+	}
+	found, err := state.getObject(key, new(model.User))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, fmt.Errorf("Customer %s already exists", customer.ID)
+	}
+	customerData, err := cc.putCustomer(state, customer)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return customerData, nil
+}
+
+// GetCustomer query the details of a customer
+func (cc *Chaincode) GetCustomer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetCustomer with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required customer ID")
+	}
+	key, err := cc.createCompositeKey(model.UserObjectType, []string{args[0]})
+	if err != nil {
+		return nil, err
+	}
+	customerBytes, err := stub.GetState(key)
+	if err != nil {
+		logger.Errorf("Failed to get customer details. Error: %s", err)
+		return nil, err
+	}
+	if customerBytes == nil {
+		return nil, fmt.Errorf("Customer %s not found", args[0])
+	}
+	return customerBytes, nil
+}
+
+// UpdateCustomer changes the name, country of residence and / or contact references
+// of an active customer. Args are the customer ID and the update JSON.
+func (cc *Chaincode) UpdateCustomer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering UpdateCustomer with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required customer ID and / or customer data JSON")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	customer, err := cc.getCustomer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := customer.Update([]byte(args[1]), src.Now()); err != nil {
+		return nil, fmt.Errorf("Error updating customer. Error: %s", err)
+	}
+	customerData, err := cc.putCustomer(state, customer)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return customerData, nil
+}
+
+// DeactivateCustomer deactivates a customer, who may then no longer open accounts, transfer
+// money or receive transfers. Existing accounts are left open.
+func (cc *Chaincode) DeactivateCustomer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering DeactivateCustomer with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required customer ID")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	customer, err := cc.getCustomer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := customer.Deactivate(src.Now()); err != nil {
+		return nil, err
+	}
+	customerData, err := cc.putCustomer(state, customer)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return customerData, nil
+}
+
+// RecordKYCResult records the result of the identity verification of an active customer,
+// which sets the KYC level of the customer until the result expires. Args are the customer
+// ID and the result JSON. Only KYC verifiers can record results.
+func (cc *Chaincode) RecordKYCResult(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering RecordKYCResult with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required customer ID and / or KYC result JSON")
+	}
+
+	id, err := cc.caller(stub)
+	if err != nil {
+		return nil, err
+	}
+	verifier := id.VerifierID
+	if verifier == "" {
+		verifier = id.Role
+	}
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	result, err := model.CreateKYCResult(src, verifier, []byte(args[1]))
+	if err != nil {
+		logger.Errorf("Error when creating KYC result. Error: %s", err)
+		return nil, fmt.Errorf("Error creating KYC result. Error: %s", err)
+	}
+	state := newTxState(stub)
+	customer, err := cc.getCustomer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := customer.RecordKYC(result); err != nil {
+		return nil, err
+	}
+	customerData, err := cc.putCustomer(state, customer)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return customerData, nil
+}
+
+// checkCustomers checks that the KYC level of the payer of a transfer, and of the joint holder
+// or signatory initiating it, allows sending it and that its payees may receive it. Transfers
+// to accounts in another country than the payer's account are cross-border. A failed check is
+// reported as a TransferError with the customer_ineligible failure code.
+func (cc *Chaincode) checkCustomers(state *txState, src model.Source, t *model.Transfer, from *model.Account, credits []*payeeCredit) error {
+	crossBorder := false
+	for _, c := range credits {
+		if !strings.EqualFold(c.account.CountryCode, from.CountryCode) {
+			crossBorder = true
+		}
+	}
+	senders := []string{from.CustomerID}
+	if t.InitiatedBy != "" && t.InitiatedBy != from.CustomerID {
+		senders = append(senders, t.InitiatedBy)
+	}
+	for _, customerID := range senders {
+		sender, err := cc.getCustomer(state, customerID)
+		if err == nil {
+			err = sender.CanSend(crossBorder, src.Now())
+		}
+		if err != nil {
+			return newTransferError(model.CustomerIneligible, from, nil, "%s", err)
+		}
+	}
+	for _, c := range credits {
+		payee, err := cc.getCustomer(state, c.account.CustomerID)
+		if err == nil {
+			err = payee.CanReceive()
+		}
+		if err != nil {
+			return newTransferError(model.CustomerIneligible, c.account, nil, "%s", err)
+		}
+	}
+	return nil
+}
+
+// countOpenAccounts returns the number of accounts of a customer that are not closed
+func (cc *Chaincode) countOpenAccounts(stub shim.ChaincodeStubInterface, customerID string) (int, error) {
+	keysIter, err := cc.partialCompositeKeyQuery(stub, model.AccountObjectType, []string{customerID})
+	if err != nil {
+		return 0, err
+	}
+	defer keysIter.Close()
+
+	count := 0
+	for keysIter.HasNext() {
+		_, accountBytes, err := keysIter.Next()
+		if err != nil {
+			return 0, err
+		}
+		account := new(model.Account)
+		if err := bytesToStruct(accountBytes, account); err != nil {
+			return 0, err
+		}
+		if !account.Closed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// getCustomer reads a customer
+func (cc *Chaincode) getCustomer(state *txState, customerID string) (*model.User, error) {
+	key, err := cc.createCompositeKey(model.UserObjectType, []string{customerID})
+	if err != nil {
+		return nil, err
+	}
+	customer := new(model.User)
+	found, err := state.getObject(key, customer)
+	if err != nil {
+		logger.Errorf("Failed to get customer details. Error: %s", err)
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Customer %s not found", customerID)
+	}
+	return customer, nil
+}
+
+// putCustomer stages a write of the customer and returns its JSON representation
+func (cc *Chaincode) putCustomer(state *txState, customer *model.User) ([]byte, error) {
+	key, err := cc.createCompositeKey(model.UserObjectType, []string{customer.ID})
+	if err != nil {
+		return nil, err
+	}
+	return state.putObject(key, customer)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// registerCustomer registers a test customer with full KYC unless it is already registered
+func (suite *ChaincodeSuite) registerCustomer(customerID string) {
+	if _, err := suite.stub.MockInvoke("t0", "GetCustomer", []string{customerID}); err == nil {
+		return
+	}
+	customer := fmt.Sprintf(`{"id":"%s","name":"Customer %s","country":"AU"}`, customerID, customerID)
+	_, err := suite.stub.MockInvoke("t0", "RegisterCustomer", []string{customer})
+	suite.Nil(err, "Failed to register customer "+customerID)
+	suite.recordKYC(customerID, model.KYCFull)
+}
+
+// recordKYC records a KYC result of the given level, valid for a year, as a KYC verifier
+func (suite *ChaincodeSuite) recordKYC(customerID string, level model.KYCLevel) {
+	suite.asVerifier("Test Verifier")
+	defer suite.asRole(BankOperatorRole)
+	result := fmt.Sprintf(`{"level":"%s","document_type":"passport","expires":%d}`, level, suite.now.AddDate(1, 0, 0).Unix())
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "RecordKYCResult", []string{customerID, result})
+	suite.Nil(err)
+}
+
+// getCustomer returns the current state of a customer
+func (suite *ChaincodeSuite) getCustomer(customerID string) *model.User {
+	res, err := suite.stub.MockInvoke("t0", "GetCustomer", []string{customerID})
+	suite.Nil(err)
+	customer := new(model.User)
+	json.Unmarshal(res, customer)
+	return customer
+}
+
+func (suite *ChaincodeSuite) TestRegisterCustomer() {
+	res, err := suite.stub.MockInvoke("t1", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"NZ","contacts":{"email":"crm:42"}}`})
+	suite.Nil(err)
+	suite.Equal(`{"docType":"User","id":"42","name":"Jane Doe","country":"NZ","contacts":{"email":"crm:42"},"status":"active","created":1502798400,"updated":1502798400}`, string(res))
+	suite.Nil(suite.getCustomer("42").KYC)
+
+	_, err = suite.stub.MockInvoke("t2", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"NZ"}`})
+	suite.Equal("Customer 42 already exists", err.Error())
+	_, err = suite.stub.MockInvoke("t3", "RegisterCustomer", []string{`{"id":"43","country":"NZ"}`})
+	suite.Equal("Error registering customer. Error: Missing required name", err.Error())
+	_, err = suite.stub.MockInvoke("t4", "GetCustomer", []string{"44"})
+	suite.Equal("Customer 44 not found", err.Error())
+
+	suite.asCustomer("42")
+	_, err = suite.stub.MockInvoke("t5", "GetCustomer", []string{"42"})
+	suite.Nil(err)
+	_, err = suite.stub.MockInvoke("t5", "GetCustomer", []string{"1"})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	_, err = suite.stub.MockInvoke("t6", "RegisterCustomer", []string{`{"id":"45","name":"John Doe","country":"NZ"}`})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestRegisterCustomerKeepsUnreadableCustomer() {
+	key, _ := suite.cc.createCompositeKey(model.UserObjectType, []string{"42"})
+	suite.stub.State[key] = []byte(`{"id":`)
+	_, err := suite.stub.MockInvoke("t1", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"NZ"}`})
+	suite.Contains(err.Error(), "Error unmarshalling state for key")
+	suite.Equal(`{"id":`, string(suite.stub.State[key]))
+}
+
+func (suite *ChaincodeSuite) TestUpdateCustomer() {
+	_, err := suite.stub.MockInvoke("t1", "UpdateCustomer", []string{"1", `{"name":"Jane Smith","kyc":{"level":"none"}}`})
+	suite.Nil(err)
+	customer := suite.getCustomer("1")
+	suite.Equal("Jane Smith", customer.Name)
+	suite.Equal(model.KYCFull, customer.KYC.Level, "KYC results are only recorded by verifiers")
+
+	_, err = suite.stub.MockInvoke("t2", "UpdateCustomer", []string{"1", `{"country":"Australia"}`})
+	suite.Equal("Error updating customer. Error: Invalid country of residence Australia", err.Error())
+	_, err = suite.stub.MockInvoke("t3", "UpdateCustomer", []string{"9", `{"name":"Jane Smith"}`})
+	suite.Equal("Customer 9 not found", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestRecordKYCResult() {
+	suite.stub.MockInvoke("t1", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"AU"}`})
+	result := `{"level":"basic","document_type":"drivers_licence","verifier":"Acme KYC","expires":1534291200}`
+	_, err := suite.stub.MockInvoke("t2", "RecordKYCResult", []string{"42", result})
+	suite.Equal("Caller is not authorised, role kyc_verifier is required", err.Error())
+
+	suite.asVerifier("Acme KYC")
+	_, err = suite.stub.MockInvoke("t3", "RecordKYCResult", []string{"42", `{"level":"full"}`})
+	suite.Equal("Error creating KYC result. Error: Missing required document_type", err.Error())
+	_, err = suite.stub.MockInvoke("t4", "RecordKYCResult", []string{"43", result})
+	suite.Equal("Customer 43 not found", err.Error())
+	res, err := suite.stub.MockInvoke("t5", "RecordKYCResult", []string{"42", result})
+	suite.Nil(err)
+	customer := new(model.User)
+	json.Unmarshal(res, customer)
+	suite.Equal(&model.KYCResult{Level: model.KYCBasic, DocumentType: "drivers_licence", Verifier: "Acme KYC", Expires: 1534291200, Recorded: suite.now.Unix()}, customer.KYC)
+
+	// the verifier recorded is the caller, not the one in the result
+	suite.asVerifier("Other KYC")
+	res, err = suite.stub.MockInvoke("t6", "RecordKYCResult", []string{"42", result})
+	suite.Nil(err)
+	json.Unmarshal(res, customer)
+	suite.Equal("Other KYC", customer.KYC.Verifier)
+}
+
+func (suite *ChaincodeSuite) TestOpenAccountKYCTiers() {
+	account := `{"id":"%s","customer_id":"%s","bank_name":"Test Bank","account_holder":"Jane Doe","country":"AU","currency":"AUD","balance":1000}`
+	_, err := suite.stub.MockInvoke("t1", "OpenAccount", []string{fmt.Sprintf(account, "1234", "42")})
+	suite.Equal("Customer 42 not found", err.Error())
+
+	suite.stub.MockInvoke("t2", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"AU"}`})
+	_, err = suite.stub.MockInvoke("t3", "OpenAccount", []string{fmt.Sprintf(account, "1234", "42")})
+	suite.Equal("Customer 42 at KYC level none cannot open accounts", err.Error())
+
+	suite.recordKYC("42", model.KYCBasic)
+	for _, id := range []string{"1234", "1235", "1236"} {
+		_, err = suite.stub.MockInvoke("t4", "OpenAccount", []string{fmt.Sprintf(account, id, "42")})
+		suite.Nil(err)
+	}
+	_, err = suite.stub.MockInvoke("t5", "OpenAccount", []string{fmt.Sprintf(account, "1237", "42")})
+	suite.Equal("Customer 42 at KYC level basic may hold at most 3 open accounts", err.Error())
+
+	suite.stub.MockInvoke("t6", "DeactivateCustomer", []string{"42"})
+	_, err = suite.stub.MockInvoke("t7", "OpenAccount", []string{fmt.Sprintf(account, "1237", "42")})
+	suite.Equal("Customer 42 is not active", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestTopupRequiresKYC() {
+	suite.stub.MockInvoke("t1", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"AU"}`})
+	suite.recordKYC("42", model.KYCBasic)
+	suite.openAccount("42", "1234", "AU", "AUD", 0)
+	suite.recordKYC("42", model.KYCNone)
+	_, err := suite.stub.MockInvoke("t2", "TopupAccount", []string{"42", "1234", "100"})
+	suite.Equal("Customer 42 at KYC level none cannot top up accounts", err.Error())
+
+	suite.recordKYC("42", model.KYCBasic)
+	_, err = suite.stub.MockInvoke("t3", "TopupAccount", []string{"42", "1234", "100"})
+	suite.Nil(err)
+	suite.Equal(int64(100), suite.getAccount("42", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferKYCTiers() {
+	suite.stub.MockInvoke("t1", "RegisterCustomer", []string{`{"id":"42","name":"Jane Doe","country":"AU"}`})
+	suite.recordKYC("42", model.KYCBasic)
+	suite.openAccount("42", "1234", "AU", "AUD", 1000)
+	suite.recordKYC("42", model.KYCNone)
+	suite.openAccount("2", "5678", "AU", "AUD", 1000)
+	suite.openAccount("3", "9012", "NZ", "AUD", 0)
+
+	// customers without KYC can receive but not send
+	suite.Equal(model.TransferCompleted, suite.transferMoney("2", "5678", "42", "1234", "AUD", 100).Status)
+	err := suite.tryTransfer("42", "1234", "2", "5678", 100)
+	suite.Equal("Customer 42 at KYC level none cannot send transfers", err.Error())
+	suite.Equal(model.CustomerIneligible, suite.getFailedTransaction("42", "1234").FailureCode)
+
+	suite.recordKYC("42", model.KYCBasic)
+	suite.Nil(suite.tryTransfer("42", "1234", "2", "5678", 100))
+	err = suite.tryTransfer("42", "1234", "3", "9012", 100)
+	suite.Equal("Customer 42 at KYC level basic cannot send cross-border transfers", err.Error())
+	suite.Equal(int64(1000), suite.getAccount("42", "1234").Balance)
+
+	suite.recordKYC("42", model.KYCFull)
+	suite.Nil(suite.tryTransfer("42", "1234", "3", "9012", 100))
+
+	suite.now = suite.now.AddDate(1, 0, 0)
+	err = suite.tryTransfer("42", "1234", "2", "5678", 100)
+	suite.Equal("Customer 42 at KYC level none cannot send transfers", err.Error(), "KYC result has expired")
+}
+
+func (suite *ChaincodeSuite) TestTransferToDeactivatedCustomer() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "DeactivateCustomer", []string{"2"})
+
+	err := suite.tryTransfer("1", "1234", "2", "5678", 100)
+	suite.Equal("Customer 2 is not active", err.Error())
+	suite.Equal(model.CustomerIneligible, suite.getFailedTransaction("2", "5678").FailureCode)
+
+	_, err = suite.stub.MockInvoke("t4", "DeactivateCustomer", []string{"2"})
+	suite.Equal("Customer 2 is not active", err.Error())
+	suite.Equal(model.CustomerDeactivated, suite.getCustomer("2").Status)
+}
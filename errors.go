@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// TransferError reports why a transfer was rejected, along with the account the
+// resulting failed transaction is recorded against
+type TransferError struct {
+	Code       model.TxFailureCode
+	CustomerID string
+	AccountID  string
+	Conversion *model.Conversion
+	Message    string
+}
+
+// newTransferError creates a new TransferError for the given account
+func newTransferError(code model.TxFailureCode, a *model.Account, c *model.Conversion, format string, args ...interface{}) *TransferError {
+	return &TransferError{
+		Code:       code,
+		CustomerID: a.CustomerID,
+		AccountID:  a.ID,
+		Conversion: c,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the error message
+func (e *TransferError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// SetEscrowAccount registers the account holding escrowed funds in the currency of the account,
+// replacing any account previously registered for the currency
+func (cc *Chaincode) SetEscrowAccount(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetEscrowAccount with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required customer ID and / or account ID")
+	}
+
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Escrow account %s is closed", account.ID)
+	}
+	escrowAccount := &model.EscrowAccount{
+		Entity:       model.Entity{ObjectType: model.EscrowAccountObjectType},
+		CurrencyCode: account.CurrencyCode,
+		CustomerID:   account.CustomerID,
+		AccountID:    account.ID,
+	}
+	key, err := cc.createCompositeKey(escrowAccount.GetObjectType(), []string{escrowAccount.CurrencyCode})
+	if err != nil {
+		return nil, err
+	}
+	escrowData, err := state.putObject(key, escrowAccount)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return escrowData, nil
+}
+
+// ReleaseEscrow releases the funds of an escrowed transfer to the payee once its release
+// condition is met. A confirmation escrow is released by the payer or a bank operator, a
+// hashlocked escrow by the payee with the preimage of its hash as second argument.
+// Funds are converted into the payee currency using the rates of the release date.
+func (cc *Chaincode) ReleaseEscrow(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ReleaseEscrow with args %v", args)
+
+	if len(args) < 1 || len(args) > 2 {
+		return nil, errors.New("Missing required transfer ID")
+	}
+	preimage := ""
+	if len(args) == 2 {
+		preimage = args[1]
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	t, err := cc.getEscrowedTransfer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	by, err := cc.escrowSettler(stub, t)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Escrow.CanRelease(src.Now(), preimage, by); err != nil {
+		return nil, err
+	}
+	escrowAccount, err := cc.getAccount(state, t.Escrow.CustomerID, t.Escrow.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	payee, err := cc.getAccount(state, t.ToCustomerID, t.ToAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if payee.Closed {
+		return nil, fmt.Errorf("Cannot release escrow into closed account %s", payee.ID)
+	}
+	creditAmount := t.Amount
+	var conversion *model.Conversion
+	if payee.CurrencyCode != t.CurrencyCode {
+		date := src.Now().UTC().Format(model.RatesDateFormat)
+		if conversion, err = cc.convert(stub, t.Amount, t.CurrencyCode, payee.CurrencyCode, date); err != nil {
+			return nil, err
+		}
+		creditAmount = conversion.DestinationAmount
+	}
+
+	if err := cc.withdrawEscrow(state, src, t, escrowAccount, conversion, model.EscrowReleased); err != nil {
+		return nil, err
+	}
+	if err := cc.creditAccount(state, payee, creditAmount); err != nil {
+		return nil, err
+	}
+	credit, err := cc.recordTransaction(state, src, payee.CustomerID, payee.ID, t, conversion, "", model.Credited)
+	if err != nil {
+		return nil, err
+	}
+	t.AddLeg(credit)
+	t.Complete()
+	if err := cc.putTransfer(state, t); err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// RefundEscrow returns the funds of an escrowed transfer to the payer. Fees are not refunded.
+// Until the escrow deadline has passed, only a bank operator can refund it.
+func (cc *Chaincode) RefundEscrow(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering RefundEscrow with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required transfer ID")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	t, err := cc.getEscrowedTransfer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	by, err := cc.escrowSettler(stub, t)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Escrow.CanRefund(src.Now(), by); err != nil {
+		return nil, err
+	}
+	escrowAccount, err := cc.getAccount(state, t.Escrow.CustomerID, t.Escrow.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	payer, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if payer.Closed {
+		return nil, fmt.Errorf("Cannot refund escrow into closed account %s", payer.ID)
+	}
+
+	if err := cc.withdrawEscrow(state, src, t, escrowAccount, nil, model.EscrowRefunded); err != nil {
+		return nil, err
+	}
+	if err := cc.creditAccount(state, payer, t.Amount); err != nil {
+		return nil, err
+	}
+	credit, err := cc.recordTransaction(state, src, payer.CustomerID, payer.ID, t, nil, "", model.Refunded)
+	if err != nil {
+		return nil, err
+	}
+	t.AddLeg(credit)
+	t.Refund()
+	if err := cc.putTransfer(state, t); err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// depositEscrow stages the credit of the funds of a transfer to the escrow account of its currency
+func (cc *Chaincode) depositEscrow(state *txState, src model.Source, t *model.Transfer) error {
+	key, err := cc.createCompositeKey(model.EscrowAccountObjectType, []string{t.CurrencyCode})
+	if err != nil {
+		return err
+	}
+	escrowAccount := new(model.EscrowAccount)
+	found, err := state.getObject(key, escrowAccount)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("No escrow account available for currency %s", t.CurrencyCode)
+	}
+	account, err := cc.getAccount(state, escrowAccount.CustomerID, escrowAccount.AccountID)
+	if err != nil {
+		return err
+	}
+	if account.Closed {
+		return fmt.Errorf("Escrow account %s is closed", account.ID)
+	}
+	t.Escrow.CustomerID = account.CustomerID
+	t.Escrow.AccountID = account.ID
+	if err := cc.creditAccount(state, account, t.Amount); err != nil {
+		return err
+	}
+	deposit, err := cc.recordTransaction(state, src, account.CustomerID, account.ID, t, nil, "", model.EscrowDeposited)
+	if err != nil {
+		return err
+	}
+	t.AddLeg(deposit)
+	return nil
+}
+
+// withdrawEscrow stages the debit of the funds of a transfer from the escrow account
+func (cc *Chaincode) withdrawEscrow(state *txState, src model.Source, t *model.Transfer, account *model.Account, c *model.Conversion, status model.TxStatus) error {
+	if account.Balance-t.Amount < 0 {
+		return fmt.Errorf("Insufficient funds available in escrow account %s", account.ID)
+	}
+	if err := cc.debitAccount(state, account, t.Amount); err != nil {
+		return err
+	}
+	withdrawal, err := cc.recordTransaction(state, src, account.CustomerID, account.ID, t, c, "", status)
+	if err != nil {
+		return err
+	}
+	t.AddLeg(withdrawal)
+	return nil
+}
+
+// getEscrowedTransfer reads a transfer that is waiting for its escrow to be released or refunded
+func (cc *Chaincode) getEscrowedTransfer(state *txState, transferID string) (*model.Transfer, error) {
+	t, err := cc.getTransfer(state, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Escrow == nil {
+		return nil, fmt.Errorf("Transfer %s is not an escrowed transfer", t.ID)
+	}
+	if t.Status != model.TransferPending {
+		return nil, fmt.Errorf("Escrow of transfer %s has already been settled with status %s", t.ID, t.Status)
+	}
+	return t, nil
+}
+
+// escrowSettler returns how the caller is involved in an escrowed transfer
+func (cc *Chaincode) escrowSettler(stub shim.ChaincodeStubInterface, t *model.Transfer) (model.EscrowSettler, error) {
+	id, err := cc.caller(stub)
+	if err != nil {
+		return model.EscrowSettler{}, err
+	}
+	return model.EscrowSettler{
+		Payer:    id.actsFor(t.FromCustomerID),
+		Payee:    id.actsFor(t.ToCustomerID),
+		Operator: id.hasRole(BankOperatorRole),
+	}, nil
+}
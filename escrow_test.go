@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// escrowTransfer makes an escrowed transfer between two accounts and returns it
+func (suite *ChaincodeSuite) escrowTransfer(escrow string, amount int64) (*model.Transfer, error) {
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":` + strconv.FormatInt(amount, 10) + `, "escrow":` + escrow + `}`
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	if err != nil {
+		return nil, err
+	}
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	return t, nil
+}
+
+// settleEscrow releases or refunds an escrowed transfer and returns it
+func (suite *ChaincodeSuite) settleEscrow(function string, args ...string) (*model.Transfer, error) {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), function, args)
+	if err != nil {
+		return nil, err
+	}
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	return t, nil
+}
+
+func (suite *ChaincodeSuite) setupEscrow() {
+	suite.openAccount("bank", "escrow", "AU", "AUD", 0)
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	_, err := suite.stub.MockInvoke("t1", "SetEscrowAccount", []string{"bank", "escrow"})
+	suite.Nil(err)
+}
+
+func (suite *ChaincodeSuite) TestSetEscrowAccountValidation() {
+	_, err := suite.stub.MockInvoke("t1", "SetEscrowAccount", []string{})
+	suite.Equal("Missing required customer ID and / or account ID", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "SetEscrowAccount", []string{"bank", "escrow"})
+	suite.Equal("Account with number escrow not found.", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestEscrowWithoutEscrowAccount() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	_, err := suite.escrowTransfer(`{"condition":"confirmation"}`, 400)
+	suite.Equal("No escrow account available for currency AUD", err.Error())
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestEscrowConfirmation() {
+	suite.setupEscrow()
+	t, err := suite.escrowTransfer(`{"condition":"confirmation"}`, 400)
+	suite.Nil(err)
+	suite.Equal(model.TransferPending, t.Status)
+	suite.Equal("escrow", t.Escrow.AccountID)
+	suite.Equal(int64(600), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(400), suite.getAccount("bank", "escrow").Balance)
+	suite.Equal(int64(0), suite.getAccount("2", "5678").Balance)
+	suite.Equal(model.EscrowDeposited, suite.getLeg(t.Legs[1]).Status)
+
+	t, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Nil(err)
+	suite.Equal(model.TransferCompleted, t.Status)
+	suite.Equal(int64(0), suite.getAccount("bank", "escrow").Balance)
+	suite.Equal(int64(400), suite.getAccount("2", "5678").Balance)
+
+	var statuses []model.TxStatus
+	for _, leg := range t.Legs {
+		suite.Equal(t.ID, suite.getLeg(leg).TransferID)
+		statuses = append(statuses, leg.Status)
+	}
+	suite.Equal([]model.TxStatus{model.Debited, model.EscrowDeposited, model.EscrowReleased, model.Credited}, statuses)
+
+	_, err = suite.settleEscrow("RefundEscrow", t.ID)
+	suite.Equal("Escrow of transfer "+t.ID+" has already been settled with status completed", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestEscrowRefund() {
+	suite.setupEscrow()
+	t, _ := suite.escrowTransfer(`{"condition":"confirmation"}`, 400)
+
+	t, err := suite.settleEscrow("RefundEscrow", t.ID)
+	suite.Nil(err)
+	suite.Equal(model.TransferRefunded, t.Status)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(0), suite.getAccount("bank", "escrow").Balance)
+	suite.Equal(model.Refunded, t.Legs[3].Status)
+
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Equal("Escrow of transfer "+t.ID+" has already been settled with status refunded", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestEscrowHashlock() {
+	suite.setupEscrow()
+	t, err := suite.escrowTransfer(`{"condition":"hashlock","hash":"2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b","deadline":"2017-08-16T12:00:00Z"}`, 400)
+	suite.Nil(err)
+
+	_, err = suite.settleEscrow("RefundEscrow", t.ID)
+	suite.Equal("Escrow cannot be refunded before deadline 2017-08-16T12:00:00Z", err.Error())
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID, "secret")
+	suite.Equal("Escrow with hashlock condition can only be released by the payee", err.Error())
+
+	suite.asCustomer("1")
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID, "secret")
+	suite.Equal("Escrow with hashlock condition can only be released by the payee", err.Error())
+	suite.asCustomer("2")
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID, "guess")
+	suite.Equal("Invalid preimage for hashlock condition", err.Error())
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID, "secret")
+	suite.Nil(err)
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(400), suite.getAccount("2", "5678").Balance)
+}
+
+func (suite *ChaincodeSuite) TestEscrowConfirmationSettledByParties() {
+	suite.setupEscrow()
+	t, err := suite.escrowTransfer(`{"condition":"confirmation"}`, 400)
+	suite.Nil(err)
+
+	suite.asCustomer("2")
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Equal("Escrow with confirmation condition can only be released by the payer or a bank operator", err.Error())
+	_, err = suite.settleEscrow("RefundEscrow", t.ID)
+	suite.Equal("Escrow can only be refunded by a bank operator before its deadline has passed", err.Error())
+	suite.asCustomer("1")
+	_, err = suite.settleEscrow("RefundEscrow", t.ID)
+	suite.Equal("Escrow can only be refunded by a bank operator before its deadline has passed", err.Error())
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Nil(err)
+
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(400), suite.getAccount("2", "5678").Balance)
+}
+
+func (suite *ChaincodeSuite) TestEscrowDeadline() {
+	suite.setupEscrow()
+	t, err := suite.escrowTransfer(`{"condition":"deadline","deadline":"2017-08-16T12:00:00Z"}`, 400)
+	suite.Nil(err)
+
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Equal("Escrow cannot be released before deadline 2017-08-16T12:00:00Z", err.Error())
+	suite.now = suite.now.Add(24 * time.Hour)
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Nil(err)
+}
+
+func (suite *ChaincodeSuite) TestEscrowDeadlineMustBeInTheFuture() {
+	suite.setupEscrow()
+	for _, escrow := range []string{`{"condition":"deadline","deadline":"2017-08-15T12:00:00Z"}`, `{"condition":"hashlock","hash":"2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b","deadline":"2017-08-14T12:00:00Z"}`} {
+		t, err := suite.escrowTransfer(escrow, 400)
+		suite.Nil(err)
+		suite.Equal(model.TransferFailed, t.Status)
+		suite.Equal(model.InvalidTransfer, t.FailureCode)
+	}
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(0), suite.getAccount("bank", "escrow").Balance)
+}
+
+func (suite *ChaincodeSuite) TestEscrowCrossCurrencyReleasedAtReleaseRates() {
+	suite.publishTestRates()
+	suite.setupEscrow()
+	suite.stub.MockInvoke("t1", "CloseAccount", []string{"2", "5678"})
+	suite.openAccount("2", "9012", "NZ", "NZD", 0)
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"9012", "currency":"AUD", "amount":1000, "escrow":{"condition":"confirmation"}}`
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	suite.Equal(int64(1000), suite.getAccount("bank", "escrow").Balance)
+
+	// released two days later, using the rates of 2017-08-17
+	suite.now = suite.now.Add(48 * time.Hour)
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Nil(err)
+	suite.Equal(int64(1079), suite.getAccount("2", "9012").Balance)
+}
+
+func (suite *ChaincodeSuite) TestReleaseEscrowValidation() {
+	suite.setupEscrow()
+	_, err := suite.settleEscrow("ReleaseEscrow")
+	suite.Equal("Missing required transfer ID", err.Error())
+	t := suite.transferMoney("1", "1234", "2", "5678", "AUD", 100)
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Equal("Transfer "+t.ID+" is not an escrowed transfer", err.Error())
+
+	t, _ = suite.escrowTransfer(`{"condition":"confirmation"}`, 400)
+	suite.stub.MockInvoke("t1", "CloseAccount", []string{"2", "5678"})
+	_, err = suite.settleEscrow("ReleaseEscrow", t.ID)
+	suite.Equal("Cannot release escrow into closed account 5678", err.Error())
+	suite.Equal(model.TransferPending, suite.getTransfer(t.ID).Status)
+}
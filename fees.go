@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// SetFeeSchedule stores the fee schedule applied to all subsequent transfers,
+// replacing any previous schedule. The fee collection account must exist.
+func (cc *Chaincode) SetFeeSchedule(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetFeeSchedule with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required fee schedule JSON")
+	}
+	schedule, err := model.CreateFeeSchedule([]byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when creating fee schedule. Error: %s", err)
+		return nil, fmt.Errorf("Error creating fee schedule. Error: %s", err)
+	}
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, schedule.CollectionCustomerID, schedule.CollectionAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Fee collection account %s is closed", account.ID)
+	}
+	key, err := cc.createCompositeKey(model.FeeScheduleObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	scheduleData, err := state.putObject(key, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return scheduleData, nil
+}
+
+// GetFeeSchedule query the current fee schedule
+func (cc *Chaincode) GetFeeSchedule(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetFeeSchedule with args %v", args)
+
+	key, err := cc.createCompositeKey(model.FeeScheduleObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	scheduleBytes, err := stub.GetState(key)
+	if err != nil {
+		logger.Errorf("Failed to get fee schedule. Error: %s", err)
+		return nil, err
+	}
+	if scheduleBytes == nil {
+		return nil, errors.New("No fee schedule available")
+	}
+	return scheduleBytes, nil
+}
+
+// getFeeSchedule reads the current fee schedule, or nil if no schedule has been set
+func (cc *Chaincode) getFeeSchedule(state *txState) (*model.FeeSchedule, error) {
+	key, err := cc.createCompositeKey(model.FeeScheduleObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	schedule := new(model.FeeSchedule)
+	found, err := state.getObject(key, schedule)
+	if err != nil || !found {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// calculateFee returns the fee of a transfer between two accounts according to the fee schedule.
+// Transfers are free if no schedule has been set or no rule matches.
+func (cc *Chaincode) calculateFee(schedule *model.FeeSchedule, t *model.Transfer, from *model.Account, to *model.Account) int64 {
+	if schedule == nil {
+		return 0
+	}
+	rule := schedule.Match(accountCorridor(from, to))
+	if rule == nil {
+		return 0
+	}
+	return rule.Calculate(t.Amount)
+}
+
+// accountCorridor returns the corridor of a transfer between two accounts
+func accountCorridor(from *model.Account, to *model.Account) model.Corridor {
+	return model.Corridor{
+		FromCurrency: from.CurrencyCode,
+		ToCurrency:   to.CurrencyCode,
+		FromCountry:  from.CountryCode,
+		ToCountry:    to.CountryCode,
+	}
+}
+
+// collectFee stages the credit of the transfer fee to the fee collection account,
+// converting it into the currency of that account if necessary
+func (cc *Chaincode) collectFee(state *txState, src model.Source, schedule *model.FeeSchedule, t *model.Transfer) (*model.Transaction, error) {
+	account, err := cc.getAccount(state, schedule.CollectionCustomerID, schedule.CollectionAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Fee collection account %s is closed", account.ID)
+	}
+	amount := t.Fee
+	var conversion *model.Conversion
+	if account.CurrencyCode != t.CurrencyCode {
+		date := src.Now().UTC().Format(model.RatesDateFormat)
+		if conversion, err = cc.convert(state.stub, t.Fee, t.CurrencyCode, account.CurrencyCode, date); err != nil {
+			return nil, err
+		}
+		amount = conversion.DestinationAmount
+	}
+	if err := cc.creditAccount(state, account, amount); err != nil {
+		return nil, err
+	}
+	txn, err := model.CreateFeeTransaction(src, account.CustomerID, account.ID, t, conversion)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating fee transaction. Error: %s", err)
+	}
+	return txn, cc.putTransaction(state, txn)
+}
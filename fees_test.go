@@ -0,0 +1,92 @@
+package main
+
+import "github.com/mschimk1/passport-chaincode/model"
+
+const testFeeSchedule = `{"collection_customer":"bank","collection_account":"fees","rules":[` +
+	`{"type":"flat","flat":50},` +
+	`{"from_currency":"AUD","to_currency":"NZD","type":"percentage","percentage":1.5,"min":20}]}`
+
+func (suite *ChaincodeSuite) TestSetFeeScheduleValidation() {
+	_, err := suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{})
+	suite.Equal("Missing required fee schedule JSON", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestSetFeeScheduleMissingCollectionAccount() {
+	_, err := suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.Equal("Account with number fees not found.", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestGetFeeSchedule() {
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	schedule, err := suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.Nil(err)
+	actual, err := suite.stub.MockInvoke("t2", "GetFeeSchedule", []string{})
+	suite.Nil(err)
+	suite.Equal(string(schedule), string(actual))
+}
+
+func (suite *ChaincodeSuite) TestGetFeeScheduleNotAvailable() {
+	_, err := suite.stub.MockInvoke("t1", "GetFeeSchedule", []string{})
+	suite.Equal("No fee schedule available", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyChargesScheduledFee() {
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.openAccount("1", "1234", "AU", "AUD", 1050)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+
+	// the fee supplied by the client is ignored
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":1000, "fee":0}`
+	_, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+
+	suite.Equal(int64(0), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(1000), suite.getAccount("2", "5678").Balance)
+	suite.Equal(int64(50), suite.getAccount("bank", "fees").Balance)
+	debit := suite.getTransactions("1", "1234")[0]
+	suite.Equal(int64(1000), debit.Amount)
+	suite.Equal(int64(50), debit.Fee)
+	fee := suite.getTransactions("bank", "fees")[0]
+	suite.Equal(model.FeeCollected, fee.Status)
+	suite.Equal(int64(50), fee.Amount)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyFeeInclusiveFundsCheck() {
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":1000}`
+	res, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal("Insufficient funds available in account 1234", suite.failureReason(res))
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(0), suite.getAccount("bank", "fees").Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyCorridorFee() {
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.openAccount("1", "1234", "AU", "AUD", 20000)
+	suite.openAccount("2", "5678", "NZ", "NZD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.stub.MockInvoke("t1", "PublishRates", []string{`{"base":"AUD","date":"2017-08-15","rates":{"NZD":1.0793}}`})
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":10000}`
+	_, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal(int64(9850), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(10793), suite.getAccount("2", "5678").Balance)
+	suite.Equal(int64(150), suite.getAccount("bank", "fees").Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyWithoutFeeSchedule() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":1000, "fee":100}`
+	_, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal(int64(0), suite.getAccount("1", "1234").Balance)
+}
@@ -9,25 +9,35 @@ import (
 // HandlerFunc is a chaincode API handler function type
 type HandlerFunc func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error)
 
+// Policy authorises a call of a handler function, returning an error if the caller is not allowed to make it
+type Policy func(stub shim.ChaincodeStubInterface, args []string) error
+
 // FuncMap is a mapping of function name to handler function
 type FuncMap struct {
 	handlers map[string]HandlerFunc
+	policies map[string][]Policy
 }
 
 // NewHandlerMap creates a new handler mapping and returns a pointer
 func NewHandlerMap() *FuncMap {
-	return &FuncMap{make(map[string]HandlerFunc)}
+	return &FuncMap{make(map[string]HandlerFunc), make(map[string][]Policy)}
 }
 
-// Add registers a handler function
-func (p *FuncMap) Add(name string, handler HandlerFunc) {
+// Add registers a handler function, which is only invoked if all given policies allow the call
+func (p *FuncMap) Add(name string, handler HandlerFunc, policies ...Policy) {
 	p.handlers[name] = handler
+	p.policies[name] = policies
 }
 
-// Handle gets a handler function by name and invokes it
+// Handle gets a handler function by name, checks its policies and invokes it
 func (p *FuncMap) Handle(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	for name, handlerFunc := range p.handlers {
 		if name == function {
+			for _, policy := range p.policies[name] {
+				if err := policy(stub, args); err != nil {
+					return nil, err
+				}
+			}
 			return handlerFunc(stub, args)
 		}
 	}
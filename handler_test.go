@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"reflect"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
@@ -55,3 +56,17 @@ func (suite *HandlerSuite) TestHandleWithoutHandlerFunction() {
 	_, err := funcMap.Handle(nil, "testFn", nil)
 	suite.NotNil(err)
 }
+
+func (suite *HandlerSuite) TestHandleChecksPolicies() {
+	funcMap := NewHandlerMap()
+	allow := func(stub shim.ChaincodeStubInterface, args []string) error { return nil }
+	deny := func(stub shim.ChaincodeStubInterface, args []string) error { return errors.New("Denied") }
+	funcMap.Add("allowed", testFn, allow)
+	funcMap.Add("denied", testFn, allow, deny)
+	res, err := funcMap.Handle(nil, "allowed", nil)
+	suite.Nil(err)
+	suite.Equal("Success", string(res[:]))
+	res, err = funcMap.Handle(nil, "denied", nil)
+	suite.Nil(res)
+	suite.Equal("Denied", err.Error())
+}
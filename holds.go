@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/mschimk1/passport-chaincode/currency"
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// PlaceHold reserves funds of an account. Held funds remain part of the ledger
+// balance but are not available for transfers.
+func (cc *Chaincode) PlaceHold(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering PlaceHold with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required hold details JSON")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := model.CreateHold(src, []byte(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating hold. Error: %s", err)
+	}
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, hold.CustomerID, hold.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Cannot place hold on closed account %s", account.ID)
+	}
+	if hold.CurrencyCode == "" {
+		hold.CurrencyCode = account.CurrencyCode
+	}
+	if hold.CurrencyCode != account.CurrencyCode {
+		return nil, fmt.Errorf("Hold currency %s does not match currency %s of account %s", hold.CurrencyCode, account.CurrencyCode, account.ID)
+	}
+	if err := cc.expireHolds(state, src, account); err != nil {
+		return nil, err
+	}
+	if account.Available()-hold.Amount < 0 {
+		return nil, fmt.Errorf("Insufficient funds available in account %s", account.ID)
+	}
+	logger.Debugf("Holding %s of account %s", currency.Format(hold.Amount, hold.CurrencyCode), account.ID)
+
+	account.Held += hold.Amount
+	if _, err := cc.putAccount(state, account); err != nil {
+		return nil, err
+	}
+	holdData, err := cc.putHold(state, hold)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return holdData, nil
+}
+
+// CaptureHold captures the held funds, in full or in part, by transferring them to a payee.
+// Args are the customer ID, account ID and hold ID and a JSON object with the payee
+// (to_customer, to_account) and optionally the amount and description of the transfer.
+// The amount defaults to the remaining held amount. Any amount not captured stays held.
+// Like TransferMoney, a rejected capture returns the failed transfer, not an error.
+func (cc *Chaincode) CaptureHold(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering CaptureHold with args %v", args)
+
+	if len(args) != 4 {
+		return nil, errors.New("Missing required customer ID, account ID, hold ID and / or capture details JSON")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	hold, err := cc.getHold(state, args[0], args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if hold.IsExpired(src.Now()) {
+		return nil, fmt.Errorf("Hold %s has expired", hold.ID)
+	}
+	if hold.PendingID != "" {
+		return nil, fmt.Errorf("Hold %s reserves funds of pending transfer %s", hold.ID, hold.PendingID)
+	}
+	t := new(model.Transfer)
+	if err := bytesToStruct([]byte(args[3]), t); err != nil {
+		return nil, fmt.Errorf("Error parsing capture details JSON. Error: %s", err)
+	}
+	t.FromCustomerID = hold.CustomerID
+	t.FromAccountID = hold.AccountID
+	t.CurrencyCode = hold.CurrencyCode
+	if t.Amount == 0 {
+		t.Amount = hold.Remaining()
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	t.Begin(src)
+	if err := cc.initiate(stub, t); err != nil {
+		return nil, err
+	}
+	if err := hold.Capture(t.Amount, t.ID); err != nil {
+		return nil, err
+	}
+
+	// the captured amount is released from the account before it is transferred
+	account, err := cc.getAccount(state, hold.CustomerID, hold.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	account.Held -= t.Amount
+	if _, err := cc.putAccount(state, account); err != nil {
+		return nil, err
+	}
+	if _, err := cc.putHold(state, hold); err != nil {
+		return nil, err
+	}
+	if err := cc.transfer(state, src, t); err != nil {
+		return cc.failTransfer(stub, src, t, err)
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// ReleaseHold releases the remaining funds of a hold
+func (cc *Chaincode) ReleaseHold(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ReleaseHold with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required customer ID, account ID and / or hold ID")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	// expired holds, including this one, are released first
+	if err := cc.expireHolds(state, src, account); err != nil {
+		return nil, err
+	}
+	hold, err := cc.getHold(state, args[0], args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if hold.PendingID != "" {
+		return nil, fmt.Errorf("Hold %s reserves funds of pending transfer %s", hold.ID, hold.PendingID)
+	}
+	if err := cc.releaseHold(state, account, hold, model.HoldReleased); err != nil {
+		return nil, err
+	}
+	if _, err := cc.putAccount(state, account); err != nil {
+		return nil, err
+	}
+	holdData, err := cc.putHold(state, hold)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return holdData, nil
+}
+
+// GetHold returns a hold of an account. Holds past their expiry are reported as expired.
+func (cc *Chaincode) GetHold(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetHold with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required customer ID, account ID and / or hold ID")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := cc.getHold(newTxState(stub), args[0], args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if hold.IsExpired(src.Now()) {
+		hold.Release(model.HoldExpired)
+	}
+	return json.Marshal(hold)
+}
+
+// GetHoldList returns the holds of an account. Holds past their expiry are reported as expired.
+func (cc *Chaincode) GetHoldList(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetHoldList with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required customer ID and / or account ID")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := cc.getHolds(newTxState(stub), args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, hold := range holds {
+		if hold.IsExpired(src.Now()) {
+			hold.Release(model.HoldExpired)
+		}
+	}
+	return json.Marshal(&model.HoldList{Holds: holds})
+}
+
+// GetAccountBalance returns the ledger balance, the held funds and the available balance of an account
+func (cc *Chaincode) GetAccountBalance(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetAccountBalance with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required customer ID and / or account ID")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	// expired holds are released in a state that is never committed
+	state := newTxState(stub)
+	account, err := cc.getAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.expireHolds(state, src, account); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&model.AccountBalance{
+		CustomerID:   account.CustomerID,
+		AccountID:    account.ID,
+		CurrencyCode: account.CurrencyCode,
+		Balance:      account.Balance,
+		Held:         account.Held,
+		Overdraft:    account.Overdraft,
+		Available:    account.Available(),
+	})
+}
+
+// expireHolds releases the expired holds of an account. The account is updated
+// in memory and staged, so callers see the released funds as available.
+func (cc *Chaincode) expireHolds(state *txState, src model.Source, account *model.Account) error {
+	if account.Held == 0 {
+		return nil
+	}
+	holds, err := cc.getHolds(state, account.CustomerID, account.ID)
+	if err != nil {
+		return err
+	}
+	expired := false
+	for _, hold := range holds {
+		if !hold.IsExpired(src.Now()) {
+			continue
+		}
+		logger.Infof("Hold %s of account %s has expired", hold.ID, account.ID)
+		if err := cc.releaseHold(state, account, hold, model.HoldExpired); err != nil {
+			return err
+		}
+		if _, err := cc.putHold(state, hold); err != nil {
+			return err
+		}
+		expired = true
+	}
+	if !expired {
+		return nil
+	}
+	_, err = cc.putAccount(state, account)
+	return err
+}
+
+// releaseHold ends a hold and releases its remaining funds from the account
+func (cc *Chaincode) releaseHold(state *txState, account *model.Account, hold *model.Hold, status model.HoldStatus) error {
+	released, err := hold.Release(status)
+	if err != nil {
+		return err
+	}
+	account.Held -= released
+	return nil
+}
+
+// getHold reads a hold from the (staged) state
+func (cc *Chaincode) getHold(state *txState, customerID string, accountID string, holdID string) (*model.Hold, error) {
+	key, err := cc.createCompositeKey(model.HoldObjectType, []string{customerID, accountID, holdID})
+	if err != nil {
+		return nil, err
+	}
+	hold := new(model.Hold)
+	found, err := state.getObject(key, hold)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Hold with ID %s not found", holdID)
+	}
+	return hold, nil
+}
+
+// getHolds reads the holds of an account, preferring staged versions of holds already on the ledger
+func (cc *Chaincode) getHolds(state *txState, customerID string, accountID string) ([]*model.Hold, error) {
+	keysIter, err := cc.partialCompositeKeyQuery(state.stub, model.HoldObjectType, []string{customerID, accountID})
+	if err != nil {
+		return nil, err
+	}
+	defer keysIter.Close()
+
+	var holds []*model.Hold
+	for keysIter.HasNext() {
+		key, _, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		hold := new(model.Hold)
+		if _, err := state.getObject(key, hold); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, nil
+}
+
+// putHold stages a write of the hold
+func (cc *Chaincode) putHold(state *txState, hold *model.Hold) ([]byte, error) {
+	key, err := cc.createCompositeKey(hold.GetObjectType(), []string{hold.CustomerID, hold.AccountID, hold.ID})
+	if err != nil {
+		return nil, err
+	}
+	return state.putObject(key, hold)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// placeHold places a hold on an account and returns it
+func (suite *ChaincodeSuite) placeHold(hold string) (*model.Hold, error) {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "PlaceHold", []string{hold})
+	if err != nil {
+		return nil, err
+	}
+	h := new(model.Hold)
+	json.Unmarshal(res, h)
+	return h, nil
+}
+
+// getBalance returns the balances of an account
+func (suite *ChaincodeSuite) getBalance(customerID string, accountID string) *model.AccountBalance {
+	res, err := suite.stub.MockInvoke("t0", "GetAccountBalance", []string{customerID, accountID})
+	suite.Nil(err)
+	balance := new(model.AccountBalance)
+	json.Unmarshal(res, balance)
+	return balance
+}
+
+func (suite *ChaincodeSuite) TestPlaceHold() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	hold, err := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":400}`)
+	suite.Nil(err)
+	suite.Equal(model.HoldActive, hold.Status)
+	suite.Equal("AUD", hold.CurrencyCode)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 1000, Held: 400, Available: 600}, suite.getBalance("1", "1234"))
+
+	_, err = suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":700}`)
+	suite.Equal("Insufficient funds available in account 1234", err.Error())
+	_, err = suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":100,"currency":"NZD"}`)
+	suite.Equal("Hold currency NZD does not match currency AUD of account 1234", err.Error())
+	_, err = suite.placeHold(`{"customer_id":"1","account_id":"1234"}`)
+	suite.Equal("Error creating hold. Error: Invalid hold amount 0", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestPlaceHoldClosedAccount() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.stub.MockInvoke("t1", "CloseAccount", []string{"1", "1234"})
+	_, err := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":400}`)
+	suite.Equal("Cannot place hold on closed account 1234", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyChecksAvailableBalance() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":700}`)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400}`
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal("Insufficient funds available in account 1234", suite.failureReason(res))
+
+	suite.transferMoney("1", "1234", "2", "5678", "AUD", 300)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 700, Held: 700, Available: 0}, suite.getBalance("1", "1234"))
+}
+
+func (suite *ChaincodeSuite) TestCaptureHold() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	hold, _ := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":400}`)
+
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678","amount":150}`})
+	suite.Nil(err)
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	suite.Equal(model.TransferCompleted, t.Status)
+	suite.Equal(int64(150), t.Amount)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 850, Held: 250, Available: 600}, suite.getBalance("1", "1234"))
+	suite.Equal(int64(150), suite.getAccount("2", "5678").Balance)
+
+	// the remaining amount is captured by default
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678"}`})
+	suite.Nil(err)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 600, Held: 0, Available: 600}, suite.getBalance("1", "1234"))
+
+	res, _ = suite.stub.MockInvoke("t0", "GetHold", []string{"1", "1234", hold.ID})
+	json.Unmarshal(res, hold)
+	suite.Equal(model.HoldCaptured, hold.Status)
+	suite.Equal(2, len(hold.TransferIDs))
+
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678","amount":1}`})
+	suite.Equal("Cannot capture hold "+hold.ID+" with status captured", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestCaptureHoldValidation() {
+	_, err := suite.stub.MockInvoke("t1", "CaptureHold", []string{"1", "1234", "h1"})
+	suite.Equal("Missing required customer ID, account ID, hold ID and / or capture details JSON", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "CaptureHold", []string{"1", "1234", "h1", `{}`})
+	suite.Equal("Hold with ID h1 not found", err.Error())
+
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	hold, _ := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":400}`)
+	_, err = suite.stub.MockInvoke("t1", "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2"}`})
+	suite.Equal("Missing required to_account value", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678"}`})
+	suite.Equal("Account with number 5678 not found.", err.Error())
+	suite.Equal(int64(400), suite.getBalance("1", "1234").Held)
+}
+
+func (suite *ChaincodeSuite) TestCaptureHoldCoversFee() {
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.openAccount("1", "1234", "AU", "AUD", 420)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	hold, _ := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":400}`)
+
+	// the fee must be covered by the funds available besides the hold
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678"}`})
+	suite.Nil(err)
+	suite.Equal("Insufficient funds available in account 1234", suite.failureReason(res))
+	suite.Equal(int64(400), suite.getBalance("1", "1234").Held)
+
+	suite.stub.MockInvoke("t2", "TopupAccount", []string{"1", "1234", "30"})
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678"}`})
+	suite.Nil(err)
+	suite.Equal(int64(0), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestReleaseHold() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	hold, _ := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":400}`)
+
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "ReleaseHold", []string{"1", "1234", hold.ID})
+	suite.Nil(err)
+	json.Unmarshal(res, hold)
+	suite.Equal(model.HoldReleased, hold.Status)
+	suite.Equal(int64(1000), suite.getBalance("1", "1234").Available)
+
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "ReleaseHold", []string{"1", "1234", hold.ID})
+	suite.Equal("Cannot release hold "+hold.ID+" with status released", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestHoldExpiry() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	hold, _ := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":800,"expires":"2017-08-16T12:00:00Z"}`)
+	suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":100}`)
+	suite.Equal(int64(100), suite.getBalance("1", "1234").Available)
+
+	suite.now = suite.now.Add(24 * time.Hour)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 1000, Held: 100, Available: 900}, suite.getBalance("1", "1234"))
+	res, _ := suite.stub.MockInvoke("t0", "GetHoldList", []string{"1", "1234"})
+	holds := new(model.HoldList)
+	json.Unmarshal(res, holds)
+	suite.Equal(2, len(holds.Holds))
+
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", hold.ID, `{"to_customer":"2","to_account":"5678"}`})
+	suite.Equal("Hold "+hold.ID+" has expired", err.Error())
+
+	// the expired hold is released by the next transfer
+	suite.transferMoney("1", "1234", "2", "5678", "AUD", 900)
+	suite.Equal(int64(100), suite.getAccount("1", "1234").Held)
+	res, _ = suite.stub.MockInvoke("t0", "GetHold", []string{"1", "1234", hold.ID})
+	json.Unmarshal(res, hold)
+	suite.Equal(model.HoldExpired, hold.Status)
+}
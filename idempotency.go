@@ -0,0 +1,31 @@
+package main
+
+import "github.com/mschimk1/passport-chaincode/model"
+
+// getIdempotencyRecord reads the record stored for a customer's idempotency key, or nil if there is none
+func (cc *Chaincode) getIdempotencyRecord(state *txState, customerID string, key string) (*model.IdempotencyRecord, error) {
+	stateKey, err := cc.createCompositeKey(model.IdempotencyObjectType, []string{customerID, key})
+	if err != nil {
+		return nil, err
+	}
+	record := new(model.IdempotencyRecord)
+	found, err := state.getObject(stateKey, record)
+	if err != nil || !found {
+		return nil, err
+	}
+	return record, nil
+}
+
+// putIdempotencyRecord stages a write of the result of a request made with an idempotency key
+func (cc *Chaincode) putIdempotencyRecord(state *txState, src model.Source, customerID string, key string, requestHash string, result []byte) error {
+	record, err := model.CreateIdempotencyRecord(src, customerID, key, requestHash, result)
+	if err != nil {
+		return err
+	}
+	stateKey, err := cc.createCompositeKey(record.GetObjectType(), []string{customerID, key})
+	if err != nil {
+		return err
+	}
+	_, err = state.putObject(stateKey, record)
+	return err
+}
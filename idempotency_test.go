@@ -0,0 +1,54 @@
+package main
+
+func (suite *ChaincodeSuite) TestTransferMoneyIdempotentReplay() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400, "idempotency_key":"abc"}`
+	res1, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	res2, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal(string(res1), string(res2))
+	suite.Equal(int64(600), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(400), suite.getAccount("2", "5678").Balance)
+	suite.Equal(1, len(suite.getTransactions("1", "1234")))
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyIdempotencyConflict() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400, "idempotency_key":"abc"}`
+	suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	transfer = `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":500, "idempotency_key":"abc"}`
+	_, err := suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Equal("Idempotency key abc has already been used for a different transfer", err.Error())
+	suite.Equal(int64(600), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyIdempotencyKeysPerCustomer() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 1000)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400, "idempotency_key":"abc"}`
+	_, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	transfer = `{"from_customer":"2", "from_account":"5678", "to_customer":"1", "to_account":"1234", "currency":"AUD", "amount":100, "idempotency_key":"abc"}`
+	_, err = suite.stub.MockInvoke("t2", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal(int64(700), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferMoneyFailedTransferCanBeRetried() {
+	suite.openAccount("1", "1234", "AU", "AUD", 100)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+
+	transfer := `{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400, "idempotency_key":"abc"}`
+	res, err := suite.stub.MockInvoke("t1", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.NotEmpty(suite.failureReason(res))
+	suite.stub.MockInvoke("t2", "TopupAccount", []string{"1", "1234", "300"})
+	_, err = suite.stub.MockInvoke("t3", "TransferMoney", []string{transfer})
+	suite.Nil(err)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Composite keys consist of the object type and attributes, each terminated
+// by a null separator. Null and escape characters within attributes are escaped,
+// so no key is a prefix of another key unless its attributes are.
+const (
+	keySeparator = "\x00"
+	keyEscape    = "\x01"
+)
+
+var keyEscaper = strings.NewReplacer(keyEscape, keyEscape+"\x01", keySeparator, keyEscape+"\x02")
+
+// legacyKeySeparator separator of keys created before the null separator was introduced
+const legacyKeySeparator = "0"
+
+// MigrationResult holds the number of keys rewritten per object type
+type MigrationResult struct {
+	Accounts     int `json:"accounts"`
+	Transactions int `json:"transactions"`
+	Rates        int `json:"rates"`
+}
+
+// MigrateKeys rewrites Account, Transaction and Rates keys created with the legacy
+// "0" separator into the current composite key format. Legacy keys are ambiguous,
+// so the new keys are built from the stored objects rather than the old keys.
+// The latest rates of every base currency and the due times of active scheduled
+// transfers are indexed again afterwards.
+func (cc *Chaincode) MigrateKeys(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering MigrateKeys with args %v", args)
+
+	result := MigrationResult{}
+	var err error
+	if result.Accounts, err = cc.migrateKeys(stub, model.AccountObjectType, func(data []byte) (model.Model, []string, error) {
+		a := new(model.Account)
+		err := bytesToStruct(data, a)
+		return a, []string{a.CustomerID, a.ID}, err
+	}); err != nil {
+		return nil, err
+	}
+	if result.Transactions, err = cc.migrateKeys(stub, model.TransactionObjectType, func(data []byte) (model.Model, []string, error) {
+		t := new(model.Transaction)
+		err := bytesToStruct(data, t)
+		return t, []string{t.CustomerID, t.AccountID, t.ID}, err
+	}); err != nil {
+		return nil, err
+	}
+	if result.Rates, err = cc.migrateKeys(stub, model.RatesObjectType, func(data []byte) (model.Model, []string, error) {
+		r := new(model.Rates)
+		err := bytesToStruct(data, r)
+		return r, []string{r.Base, r.Date}, err
+	}); err != nil {
+		return nil, err
+	}
+	if err := cc.indexLatestRates(stub); err != nil {
+		return nil, err
+	}
+	if err := cc.indexScheduledTransfers(stub); err != nil {
+		return nil, err
+	}
+	logger.Infof("Migrated keys: %+v", result)
+	return json.Marshal(result)
+}
+
+// migrateKeys moves all objects of the given type stored under legacy keys and returns their number
+func (cc *Chaincode) migrateKeys(stub shim.ChaincodeStubInterface, objectType string, parse func([]byte) (model.Model, []string, error)) (int, error) {
+	prefix := objectType + legacyKeySeparator
+	keysIter, err := stub.RangeQueryState(prefix, prefix+string(utf8.MaxRune))
+	if err != nil {
+		return 0, fmt.Errorf("Error fetching rows: %s", err)
+	}
+	// rows are kept in key order, so that every peer writes the same keys in the same order
+	type legacyRow struct {
+		key   string
+		value []byte
+	}
+	var legacyRows []legacyRow
+	for keysIter.HasNext() {
+		key, value, err := keysIter.Next()
+		if err != nil {
+			return 0, fmt.Errorf("Error fetching rows: %s", err)
+		}
+		legacyRows = append(legacyRows, legacyRow{key, value})
+	}
+	keysIter.Close()
+
+	for _, row := range legacyRows {
+		legacyKey, value := row.key, row.value
+		obj, attributes, err := parse(value)
+		if err != nil {
+			return 0, fmt.Errorf("Error migrating key %q. Error: %s", legacyKey, err)
+		}
+		if obj.GetObjectType() != objectType {
+			return 0, fmt.Errorf("Error migrating key %q. Unexpected object type %s", legacyKey, obj.GetObjectType())
+		}
+		key, err := cc.createCompositeKey(objectType, attributes)
+		if err != nil {
+			return 0, err
+		}
+		if err := stub.PutState(key, value); err != nil {
+			return 0, fmt.Errorf("Error migrating key %q. Error: %s", legacyKey, err)
+		}
+		if err := stub.DelState(legacyKey); err != nil {
+			return 0, fmt.Errorf("Error migrating key %q. Error: %s", legacyKey, err)
+		}
+	}
+	return len(legacyRows), nil
+}
+
+// indexLatestRates points the latest rates of every base currency to its most recently
+// published rates, for rates stored before the latest rates were indexed
+func (cc *Chaincode) indexLatestRates(stub shim.ChaincodeStubInterface) error {
+	startKey, _ := cc.createCompositeKey(model.RatesObjectType, nil)
+	keysIter, err := stub.RangeQueryState(startKey, startKey+string(utf8.MaxRune))
+	if err != nil {
+		return fmt.Errorf("Error fetching exchange rates: %s", err)
+	}
+	defer keysIter.Close()
+
+	// rates are ordered by base and date, so the last rates of a base are its latest
+	latest := map[string]string{}
+	var bases []string
+	for keysIter.HasNext() {
+		_, ratesBytes, err := keysIter.Next()
+		if err != nil {
+			return fmt.Errorf("Error fetching exchange rates: %s", err)
+		}
+		rates := new(model.Rates)
+		if err := bytesToStruct(ratesBytes, rates); err != nil {
+			return err
+		}
+		if _, ok := latest[rates.Base]; !ok {
+			bases = append(bases, rates.Base)
+		}
+		latest[rates.Base] = rates.Date
+	}
+	for _, base := range bases {
+		if err := cc.putLatestRates(stub, base, latest[base]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCompositeKey creates a key from the object type and the given attributes
+func (cc *Chaincode) createCompositeKey(objectType string, attributes []string) (string, error) {
+	if objectType == "" {
+		return "", errors.New("Missing required object type for composite key")
+	}
+	key := keyEscaper.Replace(objectType) + keySeparator
+	for _, att := range attributes {
+		key += keyEscaper.Replace(att) + keySeparator
+	}
+	logger.Debugf("Created composite key: %q", key)
+	return key, nil
+}
+
+// splitCompositeKey splits a composite key into the object type and attributes
+func (cc *Chaincode) splitCompositeKey(key string) (string, []string, error) {
+	if !strings.HasSuffix(key, keySeparator) {
+		return "", nil, fmt.Errorf("Invalid composite key %q", key)
+	}
+	parts := strings.Split(strings.TrimSuffix(key, keySeparator), keySeparator)
+	for i, part := range parts {
+		unescaped, ok := unescapeKeyPart(part)
+		if !ok {
+			return "", nil, fmt.Errorf("Invalid composite key %q", key)
+		}
+		parts[i] = unescaped
+	}
+	return parts[0], parts[1:], nil
+}
+
+// unescapeKeyPart reverses the escaping of a composite key part
+func unescapeKeyPart(part string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(part); i++ {
+		if part[i] != keyEscape[0] {
+			b.WriteByte(part[i])
+			continue
+		}
+		if i+1 == len(part) {
+			return "", false
+		}
+		i++
+		switch part[i] {
+		case '\x01':
+			b.WriteString(keyEscape)
+		case '\x02':
+			b.WriteString(keySeparator)
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+func (cc *Chaincode) partialCompositeKeyQuery(stub shim.ChaincodeStubInterface, objectType string, keys []string) (shim.StateRangeQueryIteratorInterface, error) {
+	partialCompositeKey, _ := cc.createCompositeKey(objectType, keys)
+	keysIter, err := stub.RangeQueryState(partialCompositeKey, partialCompositeKey+string(utf8.MaxRune))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching rows: %s", err)
+	}
+	return keysIter, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+func (suite *ChaincodeSuite) TestCreateCompositeKey() {
+	key, err := suite.cc.createCompositeKey("Account", []string{"1", "1234"})
+	suite.Nil(err)
+	suite.Equal("Account\x001\x001234\x00", key)
+}
+
+func (suite *ChaincodeSuite) TestCreateCompositeKeyMissingObjectType() {
+	_, err := suite.cc.createCompositeKey("", []string{"1"})
+	suite.NotNil(err)
+}
+
+func (suite *ChaincodeSuite) TestCompositeKeysDoNotCollide() {
+	key1, _ := suite.cc.createCompositeKey("Account", []string{"1", "01"})
+	key2, _ := suite.cc.createCompositeKey("Account", []string{"10", "1"})
+	suite.NotEqual(key1, key2)
+	key3, _ := suite.cc.createCompositeKey("Account", []string{"1\x00", "1"})
+	key4, _ := suite.cc.createCompositeKey("Account", []string{"1", "\x001"})
+	suite.NotEqual(key3, key4)
+}
+
+func (suite *ChaincodeSuite) TestSplitCompositeKey() {
+	attributes := []string{"1", "a\x00b", "c\x01d", ""}
+	key, _ := suite.cc.createCompositeKey("Transaction", attributes)
+	objectType, actual, err := suite.cc.splitCompositeKey(key)
+	suite.Nil(err)
+	suite.Equal("Transaction", objectType)
+	suite.Equal(attributes, actual)
+}
+
+func (suite *ChaincodeSuite) TestSplitCompositeKeyInvalid() {
+	_, _, err := suite.cc.splitCompositeKey("Account01012340")
+	suite.NotNil(err)
+	_, _, err = suite.cc.splitCompositeKey("Account\x001\x01\x03\x00")
+	suite.NotNil(err)
+}
+
+func (suite *ChaincodeSuite) TestGetAccountListDoesNotLeakAcrossCustomers() {
+	testAccount1 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"01","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+	testAccount2 := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1","customer_id":"10","bank_name":"Test Bank","account_holder":"Jane Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+	suite.stub.MockInvoke("t1", "OpenAccount", []string{testAccount1})
+	suite.stub.MockInvoke("t2", "OpenAccount", []string{testAccount2})
+	accountList, err := suite.stub.MockInvoke("t3", "GetAccountList", []string{"1"})
+	suite.Nil(err)
+	suite.Equal(`{"accounts":[`+testAccount1+"]}", string(accountList))
+}
+
+func (suite *ChaincodeSuite) TestMigrateKeys() {
+	testAccount := `{"created":"2017-08-15T00:00:00+10:00","docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","description":"","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`
+	testTransaction := `{"created":"2017-08-15T00:00:00+10:00","docType":"Transaction","id":"cc0f9b4d761e64e548827f2de4b49d8f","customer_id":"1","account_id":"1234","amount":100,"fee":0,"currency":"AUD","description":"","status":"credited"}`
+	testRates := `{"docType":"Rates","base":"AUD","date":"2017-08-14","rates":{"NZD":1.0793}}`
+	suite.stub.MockTransactionStart("t0")
+	suite.stub.PutState("Account01012340", []byte(testAccount))
+	suite.stub.PutState("Transaction01012340cc0f9b4d761e64e548827f2de4b49d8f0", []byte(testTransaction))
+	suite.stub.PutState("Rates0AUD02017-08-140", []byte(testRates))
+	suite.stub.MockTransactionEnd("t0")
+
+	suite.asRole(AdminRole)
+	res, err := suite.stub.MockInvoke("t1", "MigrateKeys", []string{})
+	suite.Nil(err)
+	suite.Equal(`{"accounts":1,"transactions":1,"rates":1}`, string(res))
+	suite.Nil(suite.stub.State["Account01012340"])
+	suite.Nil(suite.stub.State["Transaction01012340cc0f9b4d761e64e548827f2de4b49d8f0"])
+	suite.Nil(suite.stub.State["Rates0AUD02017-08-140"])
+
+	account, _ := suite.stub.MockInvoke("t2", "GetAccount", []string{"1", "1234"})
+	suite.Equal(testAccount, string(account))
+	transactions, _ := suite.stub.MockInvoke("t2", "GetTransactionList", []string{"1", "1234"})
+	txnList := new(model.TransactionList)
+	json.Unmarshal(transactions, txnList)
+	suite.Equal(1, len(txnList.Transactions))
+	_, err = suite.stub.MockInvoke("t2", "GetRates", []string{"AUD", "2017-08-14"})
+	suite.Nil(err)
+	rates, err := suite.stub.MockInvoke("t2", "GetLatestRates", []string{"AUD"})
+	suite.Nil(err)
+	suite.Equal(testRates, string(rates))
+
+	res, _ = suite.stub.MockInvoke("t3", "MigrateKeys", []string{})
+	suite.Equal(`{"accounts":0,"transactions":0,"rates":0}`, string(res))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// SetTransferLimits stores the limit policy applied to all subsequent transfers,
+// replacing any previous policy. Usage counted under rules of the same id is kept.
+// Only bank operators can set transfer limits.
+func (cc *Chaincode) SetTransferLimits(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetTransferLimits with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required limit policy JSON")
+	}
+	policy, err := model.CreateLimitPolicy([]byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when creating limit policy. Error: %s", err)
+		return nil, fmt.Errorf("Error creating limit policy. Error: %s", err)
+	}
+	key, err := cc.createCompositeKey(model.LimitPolicyObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	policyData, err := state.putObject(key, policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return policyData, nil
+}
+
+// GetTransferLimits query the current limit policy
+func (cc *Chaincode) GetTransferLimits(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetTransferLimits with args %v", args)
+
+	key, err := cc.createCompositeKey(model.LimitPolicyObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	policyBytes, err := stub.GetState(key)
+	if err != nil {
+		logger.Errorf("Failed to get limit policy. Error: %s", err)
+		return nil, err
+	}
+	if policyBytes == nil {
+		return nil, errors.New("No limit policy available")
+	}
+	return policyBytes, nil
+}
+
+// GetLimitUsage query the daily and monthly limit usage of a customer. Args are the
+// customer ID and optionally an account ID to return the usage of that account only.
+func (cc *Chaincode) GetLimitUsage(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetLimitUsage with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required customer ID")
+	}
+	if len(args) > 2 {
+		args = args[:2]
+	}
+	keysIter, err := cc.partialCompositeKeyQuery(stub, model.LimitUsageObjectType, args)
+	if err != nil {
+		return nil, err
+	}
+	defer keysIter.Close()
+
+	list := model.LimitUsageList{Usage: []*model.LimitUsage{}}
+	for keysIter.HasNext() {
+		_, usageBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		usage := new(model.LimitUsage)
+		if err := bytesToStruct(usageBytes, usage); err != nil {
+			return nil, err
+		}
+		list.Usage = append(list.Usage, usage)
+	}
+	return json.Marshal(&list)
+}
+
+// checkLimits checks a transfer against the limit policy and stages the updated usage.
+// Each rule applies to the amount credited to the payees in corridors it matches.
+// A breach is reported as a TransferError with the limit_exceeded failure code.
+func (cc *Chaincode) checkLimits(state *txState, src model.Source, from *model.Account, credits []*payeeCredit) error {
+	policy, err := cc.getLimitPolicy(state)
+	if err != nil || policy == nil {
+		return err
+	}
+	for _, rule := range policy.Rules {
+		amount := int64(0)
+		for _, c := range credits {
+			if ok, _ := rule.Corridor.Matches(accountCorridor(from, c.account)); ok {
+				amount += c.payee.Share
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+		usage := rule.NewUsage(from.CustomerID, from.ID, src.Now())
+		if rule.Period != model.TransactionLimit {
+			if err := cc.getLimitUsage(state, usage); err != nil {
+				return err
+			}
+		}
+		if err := rule.Check(usage, amount); err != nil {
+			return newTransferError(model.LimitExceeded, from, nil, "%s", err)
+		}
+		if rule.Period != model.TransactionLimit {
+			usage.Add(amount)
+			if err := cc.putLimitUsage(state, usage); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getLimitPolicy reads the current limit policy, or nil if no policy has been set
+func (cc *Chaincode) getLimitPolicy(state *txState) (*model.LimitPolicy, error) {
+	key, err := cc.createCompositeKey(model.LimitPolicyObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(model.LimitPolicy)
+	found, err := state.getObject(key, policy)
+	if err != nil || !found {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// getLimitUsage reads the usage counted so far into usage, which is left empty if nothing has been counted
+func (cc *Chaincode) getLimitUsage(state *txState, usage *model.LimitUsage) error {
+	key, err := cc.createCompositeKey(model.LimitUsageObjectType, []string{usage.CustomerID, usage.AccountID, usage.RuleID, usage.Window})
+	if err != nil {
+		return err
+	}
+	_, err = state.getObject(key, usage)
+	return err
+}
+
+// putLimitUsage stages a write of the limit usage, customer usage is stored with an empty account ID
+func (cc *Chaincode) putLimitUsage(state *txState, usage *model.LimitUsage) error {
+	key, err := cc.createCompositeKey(model.LimitUsageObjectType, []string{usage.CustomerID, usage.AccountID, usage.RuleID, usage.Window})
+	if err != nil {
+		return err
+	}
+	_, err = state.putObject(key, usage)
+	return err
+}
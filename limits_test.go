@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// setTransferLimits sets the limit policy as a bank operator
+func (suite *ChaincodeSuite) setTransferLimits(policy string) {
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "SetTransferLimits", []string{policy})
+	suite.Nil(err)
+}
+
+// getLimitUsage returns the limit usage of a customer or account
+func (suite *ChaincodeSuite) getLimitUsage(args ...string) []*model.LimitUsage {
+	res, err := suite.stub.MockInvoke("t0", "GetLimitUsage", args)
+	suite.Nil(err)
+	list := new(model.LimitUsageList)
+	json.Unmarshal(res, list)
+	return list.Usage
+}
+
+// tryTransfer makes a transfer that may be rejected and returns the error, or the failure
+// reason of a rejected transfer as an error
+func (suite *ChaincodeSuite) tryTransfer(fromCustomerID string, fromAccountID string, toCustomerID string, toAccountID string, amount int64) error {
+	t := &model.Transfer{FromCustomerID: fromCustomerID, FromAccountID: fromAccountID, ToCustomerID: toCustomerID, ToAccountID: toAccountID, CurrencyCode: "AUD", Amount: amount}
+	data, _ := json.Marshal(t)
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{string(data)})
+	if err != nil {
+		return err
+	}
+	if reason := suite.failureReason(res); reason != "" {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+func (suite *ChaincodeSuite) setupLimits() {
+	suite.openAccount("1", "1234", "AU", "AUD", 10000)
+	suite.openAccount("1", "4321", "AU", "AUD", 10000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+}
+
+func (suite *ChaincodeSuite) TestSetTransferLimitsValidation() {
+	suite.asRole(ComplianceRole)
+	_, err := suite.stub.MockInvoke("t1", "SetTransferLimits", []string{`{"rules":[]}`})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+	suite.asRole(BankOperatorRole)
+	_, err = suite.stub.MockInvoke("t1", "SetTransferLimits", []string{})
+	suite.Equal("Missing required limit policy JSON", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "SetTransferLimits", []string{`{"rules":[{"id":"a","period":"hourly","max_count":1}]}`})
+	suite.Equal("Error creating limit policy. Error: Invalid limit rule 1. Error: Invalid limit period hourly", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "GetTransferLimits", []string{})
+	suite.Equal("No limit policy available", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestPerTransactionLimit() {
+	suite.setupLimits()
+	suite.setTransferLimits(`{"rules":[{"id":"max","from_currency":"AUD","period":"transaction","max_amount":5000}]}`)
+
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 5000))
+	err := suite.tryTransfer("1", "1234", "2", "5678", 5001)
+	suite.Equal("Transfer exceeds the transaction limit of 50.00 AUD of rule max", err.Error())
+	failed := suite.getFailedTransaction("1", "1234")
+	suite.Equal(model.LimitExceeded, failed.FailureCode)
+	suite.Equal(model.TransferFailed, suite.getTransfer(failed.TransferID).Status)
+	suite.Equal(int64(5000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(0, len(suite.getLimitUsage("1")))
+}
+
+func (suite *ChaincodeSuite) TestDailyAccountLimit() {
+	suite.setupLimits()
+	suite.setTransferLimits(`{"rules":[{"id":"daily","from_currency":"AUD","period":"daily","max_amount":5000}]}`)
+
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 3000))
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 2000))
+	suite.NotNil(suite.tryTransfer("1", "1234", "2", "5678", 1))
+	// the limit applies to each account separately
+	suite.Nil(suite.tryTransfer("1", "4321", "2", "5678", 5000))
+
+	usage := suite.getLimitUsage("1", "1234")
+	suite.Equal(1, len(usage))
+	suite.Equal("2017-08-15", usage[0].Window)
+	suite.Equal(int64(5000), usage[0].Amount)
+	suite.Equal(int64(2), usage[0].Count)
+
+	// the next day starts a new window
+	suite.now = suite.now.Add(24 * time.Hour)
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 1))
+	suite.Equal(2, len(suite.getLimitUsage("1", "1234")))
+}
+
+func (suite *ChaincodeSuite) TestMonthlyCustomerLimits() {
+	suite.setupLimits()
+	suite.setTransferLimits(`{"rules":[{"id":"monthly","scope":"customer","from_currency":"AUD","period":"monthly","max_amount":8000,"max_count":3}]}`)
+
+	// transfers from all accounts of the customer count towards the limit
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 5000))
+	err := suite.tryTransfer("1", "4321", "2", "5678", 3001)
+	suite.Equal("Transfer exceeds the monthly limit of 80.00 AUD of rule monthly", err.Error())
+	suite.Nil(suite.tryTransfer("1", "4321", "2", "5678", 1000))
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 1000))
+	err = suite.tryTransfer("1", "4321", "2", "5678", 1)
+	suite.Equal("Transfer exceeds the monthly limit of 3 transfers of rule monthly", err.Error())
+
+	usage := suite.getLimitUsage("1")
+	suite.Equal(1, len(usage))
+	suite.Equal("", usage[0].AccountID)
+	suite.Equal("2017-08", usage[0].Window)
+	suite.Equal(int64(7000), usage[0].Amount)
+}
+
+func (suite *ChaincodeSuite) TestCorridorLimit() {
+	suite.setupLimits()
+	suite.publishTestRates()
+	suite.openAccount("3", "9012", "NZ", "NZD", 0)
+	suite.setTransferLimits(`{"rules":[{"id":"nz","from_currency":"AUD","to_country":"NZ","period":"daily","max_amount":1000}]}`)
+
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 5000))
+	suite.Nil(suite.tryTransfer("1", "1234", "3", "9012", 1000))
+	suite.NotNil(suite.tryTransfer("1", "1234", "3", "9012", 1))
+
+	// only the share of a split transfer paid into the corridor counts
+	split := `{"from_customer":"1","from_account":"4321","currency":"AUD","amount":3000,"payees":[` +
+		`{"customer":"2","account":"5678","amount":2000},{"customer":"3","account":"9012","amount":1000}]}`
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{split})
+	suite.Nil(err)
+	suite.Equal(int64(1000), suite.getLimitUsage("1", "4321")[0].Amount)
+}
+
+func (suite *ChaincodeSuite) TestRejectedTransferDoesNotCountTowardsLimits() {
+	suite.setupLimits()
+	suite.setTransferLimits(`{"rules":[{"id":"daily","period":"daily","max_count":1}]}`)
+
+	err := suite.tryTransfer("1", "1234", "2", "5678", 20000)
+	suite.Equal("Insufficient funds available in account 1234", err.Error())
+	suite.Equal(0, len(suite.getLimitUsage("1")))
+	suite.Nil(suite.tryTransfer("1", "1234", "2", "5678", 100))
+}
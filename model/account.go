@@ -3,14 +3,19 @@ package model
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/mschimk1/passport-chaincode/currency"
 	"github.com/mschimk1/passport-chaincode/utils"
 )
 
 // AccountObjectType blockchain object type
 const AccountObjectType = "Account"
 
+// AccountIDLength number of digits of generated account IDs
+const AccountIDLength = 8
+
 // Account struct holds information about a bank account
 type Account struct {
 	Entity
@@ -21,11 +26,27 @@ type Account struct {
 	Description   string            `json:"description"`
 	CountryCode   string            `json:"country"`
 	CurrencyCode  string            `json:"currency"`
-	Created       int64             `json:"created"` // unix timestamp
-	Balance       int64             `json:"balance"` // account balance in cents
+	Created       int64             `json:"created"`                   // unix timestamp
+	Balance       int64             `json:"balance"`                   // account balance in minor units of the account currency
+	Held          int64             `json:"held,omitempty"`            // funds reserved by active holds
+	Overdraft     int64             `json:"overdraft_limit,omitempty"` // approved overdraft facility in minor units of the account currency
 	Default       bool              `json:"default_account"`
 	Closed        bool              `json:"closed"`
-	Params        map[string]string `json:"params,omitempty"` // additional name / value pairs
+	Params        map[string]string `json:"params,omitempty"`          // additional name / value pairs
+	JointHolders  []string          `json:"joint_holders,omitempty"`   // customers holding the account together with its owner
+	Signatories   []*Signatory      `json:"signatories,omitempty"`     // customers authorised to act on the account
+	Approval      *ApprovalPolicy   `json:"approval_policy,omitempty"` // approvals required for large transfers
+}
+
+// AccountBalance holds the ledger and available balances of an account
+type AccountBalance struct {
+	CustomerID   string `json:"customer_id"`
+	AccountID    string `json:"account_id"`
+	CurrencyCode string `json:"currency"`
+	Balance      int64  `json:"ledger_balance"`
+	Held         int64  `json:"held"`
+	Overdraft    int64  `json:"overdraft_limit"`
+	Available    int64  `json:"available_balance"`
 }
 
 // AccountList holds a list of bank accounts
@@ -68,7 +89,7 @@ func (a *Account) MarshalJSON() ([]byte, error) {
 }
 
 // CreateAccount Factory function creates a new Account struct and returns a pointer to it
-func CreateAccount(accountBytes []byte) (*Account, error) {
+func CreateAccount(src Source, accountBytes []byte) (*Account, error) {
 	account := new(Account)
 	if err := json.Unmarshal(accountBytes, account); err != nil {
 		return nil, err
@@ -77,18 +98,58 @@ func CreateAccount(accountBytes []byte) (*Account, error) {
 		return nil, errors.New("Error unmarshalling account data")
 	}
 	account.ObjectType = AccountObjectType
+	account.Held = 0      // funds can only be reserved by placing holds
+	account.Overdraft = 0 // overdraft facilities are approved by bank operators
+	account.JointHolders = nil
+	account.Signatories = nil
+	account.Approval = nil
 	if account.CustomerID == "" {
 		return nil, errors.New("Missing required customer_id")
 	}
-	if account.ID == "" { // generate hash
-		account.ID = utils.GenerateID(8)
+	if account.CurrencyCode == "" {
+		return nil, errors.New("Missing required currency value")
+	}
+	if !currency.IsValid(account.CurrencyCode) {
+		return nil, fmt.Errorf("Invalid currency code %s", account.CurrencyCode)
+	}
+	if account.ID == "" {
+		account.NewID(src)
 	}
 	if account.Created == 0 {
-		account.Created = time.Now().Unix()
+		account.Created = src.Now().Unix()
 	}
 	return account, nil
 }
 
+// NewID assigns a new ID generated from the next ID of the source to the account
+func (a *Account) NewID(src Source) {
+	a.ID = utils.GenerateID(src.NextID(), AccountIDLength)
+}
+
+// Available returns the funds that can be debited: the balance that is not reserved
+// by holds plus any overdraft facility
+func (a *Account) Available() int64 {
+	return a.Balance - a.Held + a.Overdraft
+}
+
+// FundsFailureCode returns the failure code of a debit not covered by the available funds
+func (a *Account) FundsFailureCode() TxFailureCode {
+	if a.Overdraft > 0 {
+		return OverdraftExceeded
+	}
+	return InsufficientFunds
+}
+
+// SetOverdraftLimit sets the overdraft facility of the account. Lowering the limit
+// below the amount currently overdrawn prevents further debits but does not fail.
+func (a *Account) SetOverdraftLimit(limit int64) error {
+	if limit < 0 {
+		return fmt.Errorf("Invalid overdraft limit %d", limit)
+	}
+	a.Overdraft = limit
+	return nil
+}
+
 // Debit - debit the account
 func (a *Account) Debit(amount int64) {
 	a.Balance -= amount
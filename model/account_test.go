@@ -13,12 +13,14 @@ import (
 
 type AccountSuite struct {
 	suite.Suite
+	src         Source
 	testAccount *Account
 }
 
 func (suite *AccountSuite) SetupTest() {
 	ts := time.Now().Unix()
-	suite.testAccount = &Account{Entity{"Account"}, "1234", "1", "Test Bank", "John Smith", "", "AU", "AUD", ts, 1000, true, false, map[string]string(nil)}
+	suite.src = NewSequenceSource("t1", time.Unix(ts, 0))
+	suite.testAccount = &Account{Entity{"Account"}, "1234", "1", "Test Bank", "John Smith", "", "AU", "AUD", ts, 1000, 0, 0, true, false, map[string]string(nil), nil, nil, nil}
 }
 
 func (suite *AccountSuite) TestGetObjectType() {
@@ -52,7 +54,7 @@ func (suite *AccountSuite) TestMarshalAccountCreated() {
 
 func (suite *AccountSuite) TestCreateAccountHappyPath() {
 	accountData := []byte(`{"docType":"Account","id":"1234","customer_id":"1","bank_name":"Test Bank","account_holder":"John Smith","country":"AU","currency":"AUD","balance":1000,"default_account":true,"closed":false}`)
-	a, err := CreateAccount(accountData)
+	a, err := CreateAccount(suite.src, accountData)
 	suite.Nil(err)
 	suite.Equal(suite.testAccount, a)
 }
@@ -60,19 +62,40 @@ func (suite *AccountSuite) TestCreateAccountHappyPath() {
 func (suite *AccountSuite) TestCreateAccountMissingCustomerID() {
 	accountData := "{\"bank_name\":\"Test Bank\", \"account_holder\": \"Mike\", \"country\": \"AU\", \"currency\": \"AUD\", \"balance\":100}"
 	errMsg := "Missing required customer_id"
-	_, err := CreateAccount([]byte(accountData))
+	_, err := CreateAccount(suite.src, []byte(accountData))
 	suite.Equal(errMsg, err.Error())
 }
 
+func (suite *AccountSuite) TestCreateAccountMissingCurrency() {
+	accountData := []byte(`{"customer_id":"1","bank_name":"Test Bank","account_holder":"Mike","country":"AU","balance":100}`)
+	_, err := CreateAccount(suite.src, accountData)
+	suite.Equal("Missing required currency value", err.Error())
+}
+
+func (suite *AccountSuite) TestCreateAccountInvalidCurrency() {
+	accountData := []byte(`{"customer_id":"1","bank_name":"Test Bank","account_holder":"Mike","country":"AU","currency":"AUS","balance":100}`)
+	_, err := CreateAccount(suite.src, accountData)
+	suite.Equal("Invalid currency code AUS", err.Error())
+}
+
 func (suite *AccountSuite) TestCreateAccountWithoutID() {
 	accountData := []byte(`{"customer_id":"1","bank_name":"Test Bank","account_holder":"Mike","country":"AU","currency":"AUD","balance":100}`)
-	acc, _ := CreateAccount(accountData)
+	acc, _ := CreateAccount(suite.src, accountData)
 	suite.Equal(8, len(acc.ID))
 }
 
+func (suite *AccountSuite) TestCreateAccountWithoutIDIsDeterministic() {
+	accountData := []byte(`{"customer_id":"1","bank_name":"Test Bank","account_holder":"Mike","country":"AU","currency":"AUD","balance":100}`)
+	acc1, _ := CreateAccount(NewSequenceSource("t1", time.Time{}), accountData)
+	acc2, _ := CreateAccount(NewSequenceSource("t1", time.Time{}), accountData)
+	acc3, _ := CreateAccount(NewSequenceSource("t2", time.Time{}), accountData)
+	suite.Equal(acc1.ID, acc2.ID)
+	suite.NotEqual(acc1.ID, acc3.ID)
+}
+
 func (suite *AccountSuite) TestCreateAccountAddsCreated() {
 	accountData := []byte(`{"customer_id":"1","bank_name":"Test Bank","account_holder":"Mike","country":"AU","currency":"AUD","balance":100}`)
-	acc, _ := CreateAccount(accountData)
+	acc, _ := CreateAccount(suite.src, accountData)
 	var valid = regexp.MustCompile(`^[0-9]+$`)
 	matched := valid.MatchString(strconv.FormatInt(acc.Created, 10))
 	suite.True(matched)
@@ -99,3 +122,29 @@ func (suite *AccountSuite) TestCredit() {
 	a.Credit(amount)
 	suite.Equal(expected, a.Balance)
 }
+
+func (suite *AccountSuite) TestAvailable() {
+	a := &Account{
+		Balance: 1000,
+		Held:    400,
+	}
+	suite.Equal(int64(600), a.Available())
+}
+
+func (suite *AccountSuite) TestAvailableWithOverdraft() {
+	a := &Account{
+		Balance: 1000,
+		Held:    400,
+	}
+	suite.Equal(InsufficientFunds, a.FundsFailureCode())
+	suite.Nil(a.SetOverdraftLimit(500))
+	suite.Equal(int64(1100), a.Available())
+	suite.Equal(OverdraftExceeded, a.FundsFailureCode())
+	suite.Equal("Invalid overdraft limit -1", a.SetOverdraftLimit(-1).Error())
+}
+
+func (suite *AccountSuite) TestCreateAccountIgnoresOverdraft() {
+	a, err := CreateAccount(suite.src, []byte(`{"customer_id":"1","currency":"AUD","balance":100,"overdraft_limit":10000}`))
+	suite.Nil(err)
+	suite.Equal(int64(0), a.Overdraft)
+}
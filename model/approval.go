@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// PendingApprovalObjectType blockchain object type of the index of transfers pending approval
+const PendingApprovalObjectType = "PendingApproval"
+
+// TransferApproval records the approvals of a transfer requiring approval by the approvers of the payer account
+type TransferApproval struct {
+	Required  int        `json:"required"`
+	Approvals []Approval `json:"approvals"`
+	Expires   int64      `json:"expires"` // unix time, the transfer fails unless approved before
+}
+
+// PendingApproval indexes a transfer pending approval
+type PendingApproval struct {
+	Entity
+	TransferID string `json:"transfer_id"`
+}
+
+// Approval records the approval of a transfer by one of the approvers of the payer account
+type Approval struct {
+	CustomerID string `json:"customer_id"`
+	Approved   int64  `json:"approved"` // unix time
+}
+
+// Complete returns true once the required number of approvals has been given
+func (a *TransferApproval) Complete() bool {
+	return len(a.Approvals) >= a.Required
+}
+
+// Expired returns true if the approval deadline has passed
+func (a *TransferApproval) Expired(now time.Time) bool {
+	return now.Unix() >= a.Expires
+}
+
+// HoldForApproval marks the transfer pending approval by the given number of approvers
+// before the deadline, with its funds reserved by the given hold
+func (t *Transfer) HoldForApproval(required int, deadline time.Time, holdID string) {
+	t.Approval = &TransferApproval{Required: required, Approvals: []Approval{}, Expires: deadline.Unix()}
+	t.Status = TransferPendingApproval
+	t.HoldID = holdID
+}
+
+// AddApproval records the approval of the transfer by a customer. Once the required number
+// of approvals has been given, the transfer is pending execution.
+func (t *Transfer) AddApproval(customerID string, now time.Time) error {
+	if t.Status != TransferPendingApproval {
+		return fmt.Errorf("Transfer %s is not pending approval", t.ID)
+	}
+	if t.Approval.Expired(now) {
+		return fmt.Errorf("Approval of transfer %s has expired", t.ID)
+	}
+	for _, a := range t.Approval.Approvals {
+		if a.CustomerID == customerID {
+			return fmt.Errorf("Transfer %s has already been approved by customer %s", t.ID, customerID)
+		}
+	}
+	t.Approval.Approvals = append(t.Approval.Approvals, Approval{CustomerID: customerID, Approved: now.Unix()})
+	if t.Approval.Complete() {
+		t.Status = TransferPending
+	}
+	return nil
+}
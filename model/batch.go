@@ -0,0 +1,90 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// BatchMode stores allowed values for the execution mode of a batch of transfers.
+// Allowed values are "all_or_nothing", "best_effort"
+type BatchMode string
+
+// BatchItemStatus stores allowed values for the outcome of a transfer in a batch.
+// Allowed values are "succeeded", "pending", "failed", "rolled_back"
+type BatchItemStatus string
+
+const (
+	// AllOrNothing batch mode, no transfer is made unless all transfers succeed
+	AllOrNothing BatchMode = "all_or_nothing"
+	// BestEffort batch mode, each transfer succeeds or fails on its own
+	BestEffort BatchMode = "best_effort"
+	// BatchItemSucceeded status of a transfer made as part of a batch
+	BatchItemSucceeded BatchItemStatus = "succeeded"
+	// BatchItemPending status of a transfer of a batch that has been accepted but not completed,
+	// as it is held for review or approval or escrowed
+	BatchItemPending BatchItemStatus = "pending"
+	// BatchItemFailed status of a rejected transfer of a batch
+	BatchItemFailed BatchItemStatus = "failed"
+	// BatchItemRolledBack status of a transfer not made because another transfer of an all-or-nothing batch failed
+	BatchItemRolledBack BatchItemStatus = "rolled_back"
+)
+
+// Batch holds a list of transfers made in a single invocation
+type Batch struct {
+	Mode      BatchMode         `json:"mode"`
+	Transfers []json.RawMessage `json:"transfers"` // parsed one by one, so a malformed transfer only fails itself
+}
+
+// BatchItemResult holds the outcome of a transfer of a batch
+type BatchItemResult struct {
+	Index       int             `json:"index"`
+	TransferID     string          `json:"transfer_id,omitempty"`
+	Status         BatchItemStatus `json:"status"`
+	TransferStatus TransferStatus  `json:"transfer_status,omitempty"`
+	FailureCode TxFailureCode   `json:"failure_code,omitempty"`
+	Message     string          `json:"message,omitempty"`
+}
+
+// BatchResult holds the outcomes of the transfers of a batch
+type BatchResult struct {
+	Mode      BatchMode          `json:"mode"`
+	Committed bool               `json:"committed"`
+	Succeeded int                `json:"succeeded"` // completed transfers
+	Pending   int                `json:"pending"`   // accepted transfers not completed yet
+	Failed    int                `json:"failed"`
+	Results   []*BatchItemResult `json:"results"`
+}
+
+// CreateBatch a factory function parsing and validating a batch of transfers
+func CreateBatch(batchBytes []byte) (*Batch, error) {
+	batch := new(Batch)
+	if err := json.Unmarshal(batchBytes, batch); err != nil {
+		return nil, err
+	}
+	if batch.Mode == "" {
+		batch.Mode = AllOrNothing
+	}
+	if batch.Mode != AllOrNothing && batch.Mode != BestEffort {
+		return nil, fmt.Errorf("Invalid batch mode %s", batch.Mode)
+	}
+	if len(batch.Transfers) == 0 {
+		return nil, errors.New("Missing required transfers")
+	}
+	return batch, nil
+}
+
+// ParseTransfer parses and validates the transfer at the given index of the batch
+func (b *Batch) ParseTransfer(index int) (*Transfer, error) {
+	t := new(Transfer)
+	if err := json.Unmarshal(b.Transfers[index], t); err != nil {
+		return nil, fmt.Errorf("Error parsing transfer details JSON. Error: %s", err)
+	}
+	if t.IdempotencyKey != "" {
+		return nil, errors.New("Idempotency keys are not supported for transfers in a batch")
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,38 @@
+package model
+
+import "github.com/stretchr/testify/suite"
+
+type BatchSuite struct {
+	suite.Suite
+}
+
+func (suite *BatchSuite) TestCreateBatch() {
+	batch, err := CreateBatch([]byte(`{"transfers":[{"from_customer":"1"}]}`))
+	suite.Nil(err)
+	suite.Equal(AllOrNothing, batch.Mode)
+	suite.Equal(1, len(batch.Transfers))
+}
+
+func (suite *BatchSuite) TestCreateBatchValidation() {
+	_, err := CreateBatch([]byte(`{"mode":"some","transfers":[{}]}`))
+	suite.Equal("Invalid batch mode some", err.Error())
+	_, err = CreateBatch([]byte(`{"mode":"best_effort","transfers":[]}`))
+	suite.Equal("Missing required transfers", err.Error())
+}
+
+func (suite *BatchSuite) TestParseTransfer() {
+	batch, _ := CreateBatch([]byte(`{"transfers":[` +
+		`{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":100},` +
+		`{"from_customer":"1"},` +
+		`{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":100,"idempotency_key":"abc"},` +
+		`"transfer"]}`))
+	t, err := batch.ParseTransfer(0)
+	suite.Nil(err)
+	suite.Equal(int64(100), t.Amount)
+	_, err = batch.ParseTransfer(1)
+	suite.Equal("Missing required from_account value", err.Error())
+	_, err = batch.ParseTransfer(2)
+	suite.Equal("Idempotency keys are not supported for transfers in a batch", err.Error())
+	_, err = batch.ParseTransfer(3)
+	suite.Contains(err.Error(), "Error parsing transfer details JSON")
+}
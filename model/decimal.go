@@ -0,0 +1,114 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// Decimal is an exact decimal number, used for exchange rates and percentages.
+// It is represented as a JSON number.
+type Decimal struct {
+	value *big.Rat
+	scale int // number of fractional digits
+}
+
+// ParseDecimal parses a decimal string such as "1.0793"
+func ParseDecimal(s string) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("Invalid decimal value %s", s)
+	}
+	scale, ok := decimalScale(r)
+	if !ok {
+		return Decimal{}, fmt.Errorf("Invalid decimal value %s", s)
+	}
+	return Decimal{r, scale}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics if the value cannot be parsed
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewDecimalFromRat rounds the given rational number half away from zero to
+// the given number of fractional digits, dropping trailing zeros
+func NewDecimalFromRat(r *big.Rat, scale int) Decimal {
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(exp))
+	value := new(big.Rat).SetFrac(roundHalfAwayFromZero(scaled), exp)
+	s, _ := decimalScale(value)
+	return Decimal{value, s}
+}
+
+// Rat returns the value as a rational number
+func (d Decimal) Rat() *big.Rat {
+	if d.value == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(d.value)
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the value
+func (d Decimal) Sign() int {
+	return d.Rat().Sign()
+}
+
+// String formats the value as a decimal string
+func (d Decimal) String() string {
+	return d.Rat().FloatString(d.scale)
+}
+
+// MarshalJSON formats the value as a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON parses a JSON number or a quoted decimal string
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value, err := ParseDecimal(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+	*d = value
+	return nil
+}
+
+// decimalScale returns the number of fractional digits needed to represent r
+// exactly, or false if r has no finite decimal representation
+func decimalScale(r *big.Rat) (int, bool) {
+	denom := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	two, five, rem := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for {
+		if q, m := new(big.Int).QuoRem(denom, two, rem); m.Sign() == 0 {
+			denom, twos = q, twos+1
+			continue
+		}
+		if q, m := new(big.Int).QuoRem(denom, five, rem); m.Sign() == 0 {
+			denom, fives = q, fives+1
+			continue
+		}
+		break
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+// roundHalfAwayFromZero rounds a rational number to an integer, rounding halves away from zero
+func roundHalfAwayFromZero(r *big.Rat) *big.Int {
+	quo, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(int64(r.Sign())))
+	}
+	return quo
+}
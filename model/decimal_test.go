@@ -0,0 +1,49 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DecimalSuite struct {
+	suite.Suite
+}
+
+func (suite *DecimalSuite) TestParseDecimal() {
+	d, err := ParseDecimal("1.0793")
+	suite.Nil(err)
+	suite.Equal(0, d.Rat().Cmp(big.NewRat(10793, 10000)))
+	suite.Equal("1.0793", d.String())
+}
+
+func (suite *DecimalSuite) TestParseDecimalInvalid() {
+	_, err := ParseDecimal("1.07a")
+	suite.Equal("Invalid decimal value 1.07a", err.Error())
+}
+
+func (suite *DecimalSuite) TestParseDecimalNonTerminating() {
+	_, err := ParseDecimal("1/3")
+	suite.NotNil(err)
+}
+
+func (suite *DecimalSuite) TestNewDecimalFromRat() {
+	suite.Equal("0.3333", NewDecimalFromRat(big.NewRat(1, 3), 4).String())
+	suite.Equal("0.6667", NewDecimalFromRat(big.NewRat(2, 3), 4).String())
+	suite.Equal("0.5", NewDecimalFromRat(big.NewRat(1, 2), 4).String())
+}
+
+func (suite *DecimalSuite) TestMarshalDecimal() {
+	b, err := json.Marshal(map[string]Decimal{"NZD": MustParseDecimal("1.0793")})
+	suite.Nil(err)
+	suite.Equal(`{"NZD":1.0793}`, string(b))
+}
+
+func (suite *DecimalSuite) TestUnmarshalDecimal() {
+	var d Decimal
+	suite.Nil(json.Unmarshal([]byte(`0.1`), &d))
+	suite.Equal(0, d.Rat().Cmp(big.NewRat(1, 10)))
+	suite.Nil(json.Unmarshal([]byte(`"6.1484"`), &d))
+	suite.Equal("6.1484", d.String())
+}
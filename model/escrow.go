@@ -0,0 +1,141 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EscrowAccountObjectType blockchain object type
+const EscrowAccountObjectType = "EscrowAccount"
+
+// EscrowConditionType stores allowed values for the release condition of an escrow.
+// Allowed values are "confirmation", "hashlock", "deadline"
+type EscrowConditionType string
+
+const (
+	// ConfirmationCondition escrow released on confirmation by the counter-party
+	ConfirmationCondition EscrowConditionType = "confirmation"
+	// HashlockCondition escrow released on presentation of the preimage of a SHA-256 hash
+	HashlockCondition EscrowConditionType = "hashlock"
+	// DeadlineCondition escrow released once a deadline has passed
+	DeadlineCondition EscrowConditionType = "deadline"
+)
+
+// Escrow holds the release condition of a conditional transfer and the escrow
+// account the funds are held in until the transfer is released or refunded
+type Escrow struct {
+	Condition  EscrowConditionType `json:"condition"`
+	Hash       string              `json:"hash,omitempty"`     // hex encoded SHA-256 hash of the preimage
+	Deadline   string              `json:"deadline,omitempty"` // RFC3339 time
+	CustomerID string              `json:"customer_id,omitempty"`
+	AccountID  string              `json:"account_id,omitempty"`
+}
+
+// EscrowAccount registers the account holding escrowed funds of a currency
+type EscrowAccount struct {
+	Entity
+	CurrencyCode string `json:"currency"`
+	CustomerID   string `json:"customer_id"`
+	AccountID    string `json:"account_id"`
+}
+
+// Validate checks the release condition of the escrow
+func (e *Escrow) Validate() error {
+	switch e.Condition {
+	case ConfirmationCondition:
+	case HashlockCondition:
+		if hash, err := hex.DecodeString(e.Hash); err != nil || len(hash) != sha256.Size {
+			return errors.New("Hashlock condition requires a hex encoded SHA-256 hash")
+		}
+	case DeadlineCondition:
+		if e.Deadline == "" {
+			return errors.New("Deadline condition requires a deadline")
+		}
+	default:
+		return fmt.Errorf("Invalid escrow condition %s", e.Condition)
+	}
+	if e.Deadline != "" {
+		if _, err := time.Parse(time.RFC3339, e.Deadline); err != nil {
+			return fmt.Errorf("Invalid escrow deadline %s", e.Deadline)
+		}
+	}
+	return nil
+}
+
+// CheckDeadline checks that the optional deadline of a new escrow is in the future
+func (e *Escrow) CheckDeadline(now time.Time) error {
+	if e.deadlinePassed(now) {
+		return fmt.Errorf("Escrow deadline %s must be in the future", e.Deadline)
+	}
+	return nil
+}
+
+// deadlinePassed returns whether the escrow has a deadline which has passed
+func (e *Escrow) deadlinePassed(now time.Time) bool {
+	if e.Deadline == "" {
+		return false
+	}
+	deadline, err := time.Parse(time.RFC3339, e.Deadline)
+	return err == nil && !now.Before(deadline)
+}
+
+// EscrowSettler describes how the caller releasing or refunding an escrow is involved in its transfer
+type EscrowSettler struct {
+	Payer    bool
+	Payee    bool
+	Operator bool
+}
+
+// CanRelease checks that the release condition of the escrow is met and that the settler
+// may release it. A confirmation escrow is released by the payer or a bank operator. A
+// hashlocked escrow is released by the payee with the preimage of its hash and can no
+// longer be released once its optional deadline has passed; a deadline escrow can only
+// be released after its deadline.
+func (e *Escrow) CanRelease(now time.Time, preimage string, by EscrowSettler) error {
+	switch e.Condition {
+	case ConfirmationCondition:
+		if !by.Payer && !by.Operator {
+			return errors.New("Escrow with confirmation condition can only be released by the payer or a bank operator")
+		}
+	case HashlockCondition:
+		if !by.Payee {
+			return errors.New("Escrow with hashlock condition can only be released by the payee")
+		}
+		if e.deadlinePassed(now) {
+			return fmt.Errorf("Escrow deadline %s has passed", e.Deadline)
+		}
+		if fmt.Sprintf("%x", sha256.Sum256([]byte(preimage))) != strings.ToLower(e.Hash) {
+			return errors.New("Invalid preimage for hashlock condition")
+		}
+	case DeadlineCondition:
+		if !e.deadlinePassed(now) {
+			return fmt.Errorf("Escrow cannot be released before deadline %s", e.Deadline)
+		}
+	}
+	return nil
+}
+
+// CanRefund checks that the escrow can be refunded by the settler. A hashlocked escrow
+// with a deadline can only be refunded after the deadline, giving the payee until then
+// to present the preimage; a deadline escrow can only be refunded before its deadline.
+// Until its deadline has passed, an escrow can only be refunded by a bank operator.
+func (e *Escrow) CanRefund(now time.Time, by EscrowSettler) error {
+	switch e.Condition {
+	case HashlockCondition:
+		if e.Deadline != "" && !e.deadlinePassed(now) {
+			return fmt.Errorf("Escrow cannot be refunded before deadline %s", e.Deadline)
+		}
+	case DeadlineCondition:
+		if e.deadlinePassed(now) {
+			return fmt.Errorf("Escrow deadline %s has passed", e.Deadline)
+		}
+	}
+	if !e.deadlinePassed(now) && !by.Operator {
+		return errors.New("Escrow can only be refunded by a bank operator before its deadline has passed")
+	}
+	return nil
+}
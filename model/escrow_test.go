@@ -0,0 +1,92 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const testHash = "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b" // sha256("secret")
+
+type EscrowSuite struct {
+	suite.Suite
+	before time.Time
+	after  time.Time
+}
+
+func (suite *EscrowSuite) SetupTest() {
+	suite.before = time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC)
+	suite.after = time.Date(2017, 8, 16, 12, 0, 0, 0, time.UTC)
+}
+
+func (suite *EscrowSuite) TestValidate() {
+	suite.Nil((&Escrow{Condition: ConfirmationCondition}).Validate())
+	suite.Nil((&Escrow{Condition: HashlockCondition, Hash: testHash}).Validate())
+	suite.Nil((&Escrow{Condition: DeadlineCondition, Deadline: "2017-08-16T12:00:00Z"}).Validate())
+	suite.Equal("Invalid escrow condition ", (&Escrow{}).Validate().Error())
+	suite.Equal("Hashlock condition requires a hex encoded SHA-256 hash", (&Escrow{Condition: HashlockCondition, Hash: "abc"}).Validate().Error())
+	suite.Equal("Hashlock condition requires a hex encoded SHA-256 hash", (&Escrow{Condition: HashlockCondition, Hash: strings.Repeat("z", 64)}).Validate().Error())
+	suite.Equal("Deadline condition requires a deadline", (&Escrow{Condition: DeadlineCondition}).Validate().Error())
+	suite.Equal("Invalid escrow deadline tomorrow", (&Escrow{Condition: DeadlineCondition, Deadline: "tomorrow"}).Validate().Error())
+}
+
+func (suite *EscrowSuite) TestCheckDeadline() {
+	suite.Nil((&Escrow{Condition: ConfirmationCondition}).CheckDeadline(suite.after))
+	escrow := &Escrow{Condition: DeadlineCondition, Deadline: "2017-08-16T12:00:00Z"}
+	suite.Nil(escrow.CheckDeadline(suite.before))
+	suite.Equal("Escrow deadline 2017-08-16T12:00:00Z must be in the future", escrow.CheckDeadline(suite.after).Error())
+}
+
+func (suite *EscrowSuite) TestTransferValidatesEscrow() {
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD", Escrow: &Escrow{Condition: "delivery"}}
+	suite.Equal("Invalid escrow condition delivery", transfer.Validate().Error())
+}
+
+var (
+	payer    = EscrowSettler{Payer: true}
+	payee    = EscrowSettler{Payee: true}
+	operator = EscrowSettler{Operator: true}
+)
+
+func (suite *EscrowSuite) TestConfirmation() {
+	escrow := &Escrow{Condition: ConfirmationCondition}
+	suite.Nil(escrow.CanRelease(suite.before, "", payer))
+	suite.Nil(escrow.CanRelease(suite.before, "", operator))
+	suite.Equal("Escrow with confirmation condition can only be released by the payer or a bank operator", escrow.CanRelease(suite.before, "", payee).Error())
+	suite.Nil(escrow.CanRefund(suite.before, operator))
+	suite.Equal("Escrow can only be refunded by a bank operator before its deadline has passed", escrow.CanRefund(suite.before, payer).Error())
+}
+
+func (suite *EscrowSuite) TestConfirmationWithDeadline() {
+	escrow := &Escrow{Condition: ConfirmationCondition, Deadline: "2017-08-16T12:00:00Z"}
+	suite.Equal("Escrow can only be refunded by a bank operator before its deadline has passed", escrow.CanRefund(suite.before, payer).Error())
+	suite.Nil(escrow.CanRefund(suite.after, payer))
+}
+
+func (suite *EscrowSuite) TestHashlock() {
+	escrow := &Escrow{Condition: HashlockCondition, Hash: testHash}
+	suite.Nil(escrow.CanRelease(suite.before, "secret", payee))
+	suite.Equal("Invalid preimage for hashlock condition", escrow.CanRelease(suite.before, "guess", payee).Error())
+	suite.Equal("Escrow with hashlock condition can only be released by the payee", escrow.CanRelease(suite.before, "secret", payer).Error())
+	suite.Equal("Escrow with hashlock condition can only be released by the payee", escrow.CanRelease(suite.before, "secret", operator).Error())
+	suite.Nil(escrow.CanRefund(suite.before, operator))
+	suite.Equal("Escrow can only be refunded by a bank operator before its deadline has passed", escrow.CanRefund(suite.before, payer).Error())
+}
+
+func (suite *EscrowSuite) TestHashlockWithDeadline() {
+	escrow := &Escrow{Condition: HashlockCondition, Hash: testHash, Deadline: "2017-08-16T12:00:00Z"}
+	suite.Nil(escrow.CanRelease(suite.before, "secret", payee))
+	suite.Equal("Escrow cannot be refunded before deadline 2017-08-16T12:00:00Z", escrow.CanRefund(suite.before, operator).Error())
+	suite.Equal("Escrow deadline 2017-08-16T12:00:00Z has passed", escrow.CanRelease(suite.after, "secret", payee).Error())
+	suite.Nil(escrow.CanRefund(suite.after, payer))
+}
+
+func (suite *EscrowSuite) TestDeadline() {
+	escrow := &Escrow{Condition: DeadlineCondition, Deadline: "2017-08-16T12:00:00Z"}
+	suite.Equal("Escrow cannot be released before deadline 2017-08-16T12:00:00Z", escrow.CanRelease(suite.before, "", payee).Error())
+	suite.Nil(escrow.CanRefund(suite.before, operator))
+	suite.Equal("Escrow can only be refunded by a bank operator before its deadline has passed", escrow.CanRefund(suite.before, payer).Error())
+	suite.Nil(escrow.CanRelease(suite.after, "", payee))
+	suite.Equal("Escrow deadline 2017-08-16T12:00:00Z has passed", escrow.CanRefund(suite.after, operator).Error())
+}
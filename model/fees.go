@@ -0,0 +1,194 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
+// FeeScheduleObjectType blockchain object type
+const FeeScheduleObjectType = "FeeSchedule"
+
+// FeeType stores allowed values for the way a fee is calculated.
+// Allowed values are "flat", "percentage", "tiered"
+type FeeType string
+
+const (
+	// FlatFee a fixed fee per transfer
+	FlatFee FeeType = "flat"
+	// PercentageFee a percentage of the transfer amount
+	PercentageFee FeeType = "percentage"
+	// TieredFee a flat fee and / or percentage depending on the tier the transfer amount falls into
+	TieredFee FeeType = "tiered"
+)
+
+// Corridor describes the currencies and countries money is transferred between.
+// Empty values match any currency or country.
+type Corridor struct {
+	FromCurrency string `json:"from_currency,omitempty"`
+	ToCurrency   string `json:"to_currency,omitempty"`
+	FromCountry  string `json:"from_country,omitempty"`
+	ToCountry    string `json:"to_country,omitempty"`
+}
+
+// Matches checks whether the given transfer corridor is covered by this corridor and returns
+// the number of values matched exactly, i.e. how specific the match is
+func (c Corridor) Matches(other Corridor) (bool, int) {
+	specificity := 0
+	for _, pair := range [][2]string{
+		{c.FromCurrency, other.FromCurrency},
+		{c.ToCurrency, other.ToCurrency},
+		{c.FromCountry, other.FromCountry},
+		{c.ToCountry, other.ToCountry},
+	} {
+		if pair[0] == "" {
+			continue
+		}
+		if pair[0] != pair[1] {
+			return false, 0
+		}
+		specificity++
+	}
+	return true, specificity
+}
+
+// FeeTier a band of transfer amounts with its fee
+type FeeTier struct {
+	UpTo       int64    `json:"up_to,omitempty"` // inclusive upper bound of the tier, 0 for no bound
+	Flat       int64    `json:"flat,omitempty"`
+	Percentage *Decimal `json:"percentage,omitempty"`
+}
+
+// FeeRule defines the fee charged for transfers in a corridor. Amounts are in
+// minor units of the transfer currency and percentages are given in percent.
+type FeeRule struct {
+	Corridor
+	Type       FeeType   `json:"type"`
+	Flat       int64     `json:"flat,omitempty"`
+	Percentage *Decimal  `json:"percentage,omitempty"`
+	Tiers      []FeeTier `json:"tiers,omitempty"` // tiers ordered by upper bound
+	Min        int64     `json:"min,omitempty"`
+	Max        int64     `json:"max,omitempty"` // 0 for no maximum
+}
+
+// FeeSchedule holds the fee rules applied to transfers and the account fees are credited to
+type FeeSchedule struct {
+	Entity
+	CollectionCustomerID string     `json:"collection_customer"`
+	CollectionAccountID  string     `json:"collection_account"`
+	Rules                []*FeeRule `json:"rules"`
+}
+
+// CreateFeeSchedule Factory function creates a new FeeSchedule struct and returns a pointer to it
+func CreateFeeSchedule(scheduleBytes []byte) (*FeeSchedule, error) {
+	schedule := new(FeeSchedule)
+	if err := json.Unmarshal(scheduleBytes, schedule); err != nil {
+		return nil, err
+	}
+	schedule.ObjectType = FeeScheduleObjectType
+	if schedule.CollectionCustomerID == "" {
+		return nil, errors.New("Missing required collection_customer value")
+	}
+	if schedule.CollectionAccountID == "" {
+		return nil, errors.New("Missing required collection_account value")
+	}
+	for i, rule := range schedule.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid fee rule %d. Error: %s", i+1, err)
+		}
+	}
+	return schedule, nil
+}
+
+// Match returns the most specific rule matching the given corridor, or nil if no rule matches.
+// Of equally specific rules the first one wins.
+func (s *FeeSchedule) Match(corridor Corridor) *FeeRule {
+	var match *FeeRule
+	best := -1
+	for _, rule := range s.Rules {
+		if ok, specificity := rule.Corridor.Matches(corridor); ok && specificity > best {
+			match, best = rule, specificity
+		}
+	}
+	return match
+}
+
+// Validate - checks that the fee rule is complete and consistent
+func (r *FeeRule) Validate() error {
+	for _, code := range []string{r.FromCurrency, r.ToCurrency} {
+		if code != "" && !currency.IsValid(code) {
+			return fmt.Errorf("Invalid currency code %s", code)
+		}
+	}
+	switch r.Type {
+	case FlatFee:
+		if r.Flat < 0 {
+			return fmt.Errorf("Invalid flat fee %d", r.Flat)
+		}
+	case PercentageFee:
+		if r.Percentage == nil || r.Percentage.Sign() < 0 {
+			return errors.New("Missing or invalid percentage value")
+		}
+	case TieredFee:
+		if len(r.Tiers) == 0 {
+			return errors.New("Missing required tiers value")
+		}
+		for i, tier := range r.Tiers {
+			if tier.Flat < 0 || (tier.Percentage != nil && tier.Percentage.Sign() < 0) {
+				return fmt.Errorf("Invalid fee of tier %d", i+1)
+			}
+			if i > 0 && (r.Tiers[i-1].UpTo == 0 || tier.UpTo != 0 && tier.UpTo <= r.Tiers[i-1].UpTo) {
+				return errors.New("Tiers must be ordered by ascending up_to values")
+			}
+		}
+		// the last tier applies to all amounts above the bounds of the other tiers
+		if r.Tiers[len(r.Tiers)-1].UpTo != 0 {
+			return errors.New("Last tier must not have an up_to value")
+		}
+	default:
+		return fmt.Errorf("Invalid fee type %s", r.Type)
+	}
+	if r.Min < 0 || r.Max < 0 || (r.Max > 0 && r.Min > r.Max) {
+		return fmt.Errorf("Invalid fee caps min %d, max %d", r.Min, r.Max)
+	}
+	return nil
+}
+
+// Calculate returns the fee for the given transfer amount. Percentage fees are rounded
+// half away from zero to whole minor units before the min / max caps are applied.
+func (r *FeeRule) Calculate(amount int64) int64 {
+	var fee int64
+	switch r.Type {
+	case FlatFee:
+		fee = r.Flat
+	case PercentageFee:
+		fee = percentageOf(amount, r.Percentage)
+	case TieredFee:
+		for _, tier := range r.Tiers {
+			if tier.UpTo == 0 || amount <= tier.UpTo {
+				fee = tier.Flat + percentageOf(amount, tier.Percentage)
+				break
+			}
+		}
+	}
+	if fee < r.Min {
+		fee = r.Min
+	}
+	if r.Max > 0 && fee > r.Max {
+		fee = r.Max
+	}
+	return fee
+}
+
+// percentageOf returns the given percentage of an amount rounded to whole minor units
+func percentageOf(amount int64, percentage *Decimal) int64 {
+	if percentage == nil {
+		return 0
+	}
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), percentage.Rat())
+	value.Quo(value, big.NewRat(100, 1))
+	return roundHalfAwayFromZero(value).Int64()
+}
@@ -0,0 +1,86 @@
+package model
+
+import "github.com/stretchr/testify/suite"
+
+type FeesSuite struct {
+	suite.Suite
+	schedule *FeeSchedule
+}
+
+func (suite *FeesSuite) SetupTest() {
+	schedule, err := CreateFeeSchedule([]byte(`{
+		"collection_customer": "bank",
+		"collection_account": "fees",
+		"rules": [
+			{"type": "flat", "flat": 100},
+			{"from_currency": "AUD", "to_currency": "NZD", "type": "percentage", "percentage": 1.5, "min": 50, "max": 2000},
+			{"from_currency": "AUD", "to_currency": "SGD", "to_country": "SG", "type": "tiered", "tiers": [
+				{"up_to": 10000, "flat": 200},
+				{"up_to": 100000, "flat": 100, "percentage": 0.5},
+				{"percentage": 0.25}
+			]}
+		]}`))
+	suite.Nil(err)
+	suite.schedule = schedule
+}
+
+func (suite *FeesSuite) TestGetObjectType() {
+	suite.Equal(FeeScheduleObjectType, suite.schedule.GetObjectType())
+}
+
+func (suite *FeesSuite) TestCreateFeeScheduleMissingCollectionAccount() {
+	_, err := CreateFeeSchedule([]byte(`{"collection_customer": "bank", "rules": []}`))
+	suite.Equal("Missing required collection_account value", err.Error())
+}
+
+func (suite *FeesSuite) TestCreateFeeScheduleInvalidRule() {
+	_, err := CreateFeeSchedule([]byte(`{"collection_customer": "bank", "collection_account": "fees", "rules": [{"type": "percentage"}]}`))
+	suite.Equal("Invalid fee rule 1. Error: Missing or invalid percentage value", err.Error())
+	_, err = CreateFeeSchedule([]byte(`{"collection_customer": "bank", "collection_account": "fees", "rules": [{"type": "flat", "min": 100, "max": 50}]}`))
+	suite.Equal("Invalid fee rule 1. Error: Invalid fee caps min 100, max 50", err.Error())
+	_, err = CreateFeeSchedule([]byte(`{"collection_customer": "bank", "collection_account": "fees", "rules": [{"type": "tiered", "tiers": [{"flat": 1}, {"up_to": 10, "flat": 1}]}]}`))
+	suite.Equal("Invalid fee rule 1. Error: Tiers must be ordered by ascending up_to values", err.Error())
+	_, err = CreateFeeSchedule([]byte(`{"collection_customer": "bank", "collection_account": "fees", "rules": [{"type": "tiered", "tiers": [{"up_to": 10, "flat": 1}, {"up_to": 100, "flat": 2}]}]}`))
+	suite.Equal("Invalid fee rule 1. Error: Last tier must not have an up_to value", err.Error())
+}
+
+func (suite *FeesSuite) TestCorridorMatches() {
+	ok, specificity := Corridor{FromCurrency: "AUD"}.Matches(Corridor{"AUD", "NZD", "AU", "NZ"})
+	suite.True(ok)
+	suite.Equal(1, specificity)
+	ok, _ = Corridor{FromCurrency: "AUD", ToCountry: "SG"}.Matches(Corridor{"AUD", "NZD", "AU", "NZ"})
+	suite.False(ok)
+}
+
+func (suite *FeesSuite) TestMatchMostSpecificRule() {
+	suite.Equal(suite.schedule.Rules[0], suite.schedule.Match(Corridor{"AUD", "AUD", "AU", "AU"}))
+	suite.Equal(suite.schedule.Rules[1], suite.schedule.Match(Corridor{"AUD", "NZD", "AU", "NZ"}))
+	suite.Equal(suite.schedule.Rules[2], suite.schedule.Match(Corridor{"AUD", "SGD", "AU", "SG"}))
+}
+
+func (suite *FeesSuite) TestMatchNoRule() {
+	schedule := &FeeSchedule{Rules: []*FeeRule{{Corridor: Corridor{FromCurrency: "NZD"}, Type: FlatFee}}}
+	suite.Nil(schedule.Match(Corridor{"AUD", "AUD", "AU", "AU"}))
+}
+
+func (suite *FeesSuite) TestCalculateFlat() {
+	suite.Equal(int64(100), suite.schedule.Rules[0].Calculate(12345))
+}
+
+func (suite *FeesSuite) TestCalculatePercentageWithCaps() {
+	rule := suite.schedule.Rules[1]
+	suite.Equal(int64(150), rule.Calculate(10000))
+	suite.Equal(int64(185), rule.Calculate(12345)) // 185.175
+	suite.Equal(int64(50), rule.Calculate(1000))
+	suite.Equal(int64(2000), rule.Calculate(1000000))
+}
+
+func (suite *FeesSuite) TestCalculateTiered() {
+	rule := suite.schedule.Rules[2]
+	suite.Equal(int64(200), rule.Calculate(10000))
+	suite.Equal(int64(150), rule.Calculate(10001)) // 100 + 50.005
+	suite.Equal(int64(600), rule.Calculate(100000))
+	suite.Equal(int64(500), rule.Calculate(200000))
+	// amounts above the top bound are charged by the last, unbounded tier
+	suite.Equal(int64(250000), rule.Calculate(100000000))
+}
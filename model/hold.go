@@ -0,0 +1,190 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
+// HoldObjectType blockchain object type
+const HoldObjectType = "Hold"
+
+// HoldStatus stores allowed values for a hold's status.
+// Allowed values are "active", "captured", "released", "expired"
+type HoldStatus string
+
+const (
+	// HoldActive status of a hold reserving funds
+	HoldActive HoldStatus = "active"
+	// HoldCaptured status of a hold whose amount has been captured in full
+	HoldCaptured HoldStatus = "captured"
+	// HoldReleased status of a hold released before it was captured in full
+	HoldReleased HoldStatus = "released"
+	// HoldExpired status of a hold released because it expired
+	HoldExpired HoldStatus = "expired"
+)
+
+// Hold reserves funds of an account, e.g. for a card pre-authorisation. Held funds
+// are not available for transfers until the hold is captured, released or expires.
+type Hold struct {
+	Entity
+	ID           string     `json:"id"`
+	CustomerID   string     `json:"customer_id"`
+	AccountID    string     `json:"account_id"`
+	Amount       int64      `json:"amount"`   // amount held in minor units of the account currency
+	Captured     int64      `json:"captured"` // amount captured so far
+	CurrencyCode string     `json:"currency"`
+	Description  string     `json:"description"`
+	Status       HoldStatus `json:"status"`
+	Expires      int64      `json:"expires,omitempty"` // unix time, holds without expiry are held until captured or released
+	Created      int64      `json:"created"`           // unix time
+	TransferIDs  []string   `json:"transfers,omitempty"`
+	PendingID    string     `json:"pending_transfer,omitempty"` // transfer pending review or approval the funds are reserved for
+}
+
+// HoldList stores a list of holds
+type HoldList struct {
+	Holds []*Hold `json:"holds"`
+}
+
+// UnmarshalJSON custom unmarshalling handles time conversion
+func (h *Hold) UnmarshalJSON(data []byte) error {
+	type HoldData Hold
+	wrapper := &struct {
+		Created string `json:"created"`
+		Expires string `json:"expires"`
+		*HoldData
+	}{
+		HoldData: (*HoldData)(h),
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return err
+	}
+	var err error
+	if h.Created, err = parseTime(wrapper.Created); err != nil {
+		return err
+	}
+	h.Expires, err = parseTime(wrapper.Expires)
+	return err
+}
+
+// MarshalJSON custom marshalling handles time conversion
+func (h *Hold) MarshalJSON() ([]byte, error) {
+	type HoldData Hold
+	wrapper := &struct {
+		Created string `json:"created"`
+		Expires string `json:"expires,omitempty"`
+		*HoldData
+	}{
+		Created:  time.Unix(h.Created, 0).Format(time.RFC3339),
+		HoldData: (*HoldData)(h),
+	}
+	if h.Expires != 0 {
+		wrapper.Expires = time.Unix(h.Expires, 0).Format(time.RFC3339)
+	}
+	return json.Marshal(wrapper)
+}
+
+// parseTime parses an optional RFC3339 time into unix time
+func parseTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// CreateHold a factory function for creating new Hold entities from the hold details JSON
+func CreateHold(src Source, holdBytes []byte) (*Hold, error) {
+	hold := new(Hold)
+	if err := json.Unmarshal(holdBytes, hold); err != nil {
+		return nil, err
+	}
+	if hold.CustomerID == "" {
+		return nil, errors.New("Missing required customer_id")
+	}
+	if hold.AccountID == "" {
+		return nil, errors.New("Missing required account_id")
+	}
+	if hold.Amount <= 0 {
+		return nil, fmt.Errorf("Invalid hold amount %d", hold.Amount)
+	}
+	if hold.CurrencyCode != "" && !currency.IsValid(hold.CurrencyCode) {
+		return nil, fmt.Errorf("Invalid currency code %s", hold.CurrencyCode)
+	}
+	now := src.Now().Unix()
+	if hold.Expires != 0 && hold.Expires <= now {
+		return nil, errors.New("Hold expiry must be in the future")
+	}
+	hold.Entity = Entity{HoldObjectType}
+	hold.ID = src.NextID()
+	hold.Captured = 0
+	hold.Status = HoldActive
+	hold.Created = now
+	hold.TransferIDs = nil
+	hold.PendingID = ""
+	return hold, nil
+}
+
+// CreatePendingHold a factory function for the hold reserving the amount plus fee of a transfer
+// pending review or approval, the reason
+func CreatePendingHold(src Source, t *Transfer, reason string) *Hold {
+	return &Hold{
+		Entity:       Entity{HoldObjectType},
+		ID:           src.NextID(),
+		CustomerID:   t.FromCustomerID,
+		AccountID:    t.FromAccountID,
+		Amount:       t.Amount + t.Fee,
+		CurrencyCode: t.CurrencyCode,
+		Description:  "Transfer " + t.ID + " pending " + reason,
+		Status:       HoldActive,
+		Created:      src.Now().Unix(),
+		PendingID:    t.ID,
+	}
+}
+
+// Remaining returns the amount that is still held
+func (h *Hold) Remaining() int64 {
+	return h.Amount - h.Captured
+}
+
+// IsExpired returns whether an active hold has expired at the given time
+func (h *Hold) IsExpired(now time.Time) bool {
+	return h.Status == HoldActive && h.Expires != 0 && now.Unix() >= h.Expires
+}
+
+// Capture captures amount of the hold for the given transfer
+func (h *Hold) Capture(amount int64, transferID string) error {
+	if h.Status != HoldActive {
+		return fmt.Errorf("Cannot capture hold %s with status %s", h.ID, h.Status)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Invalid capture amount %d", amount)
+	}
+	if amount > h.Remaining() {
+		return fmt.Errorf("Cannot capture %s of hold %s, only %s is held",
+			currency.Format(amount, h.CurrencyCode), h.ID, currency.Format(h.Remaining(), h.CurrencyCode))
+	}
+	h.Captured += amount
+	h.TransferIDs = append(h.TransferIDs, transferID)
+	if h.Remaining() == 0 {
+		h.Status = HoldCaptured
+	}
+	return nil
+}
+
+// Release ends an active hold with the given status (released or expired) and
+// returns the amount that is no longer held
+func (h *Hold) Release(status HoldStatus) (int64, error) {
+	if h.Status != HoldActive {
+		return 0, fmt.Errorf("Cannot release hold %s with status %s", h.ID, h.Status)
+	}
+	h.Status = status
+	return h.Remaining(), nil
+}
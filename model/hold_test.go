@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HoldSuite struct {
+	suite.Suite
+	src Source
+}
+
+func (suite *HoldSuite) SetupTest() {
+	suite.src = NewSequenceSource("tx1", time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC))
+}
+
+func (suite *HoldSuite) TestCreateHold() {
+	hold, err := CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500,"status":"captured","captured":100,"expires":"2017-08-16T12:00:00Z"}`))
+	suite.Nil(err)
+	suite.Equal(HoldObjectType, hold.GetObjectType())
+	suite.NotEmpty(hold.ID)
+	suite.Equal(HoldActive, hold.Status)
+	suite.Equal(int64(0), hold.Captured)
+	suite.Equal(int64(500), hold.Remaining())
+	suite.Equal(time.Date(2017, 8, 16, 12, 0, 0, 0, time.UTC).Unix(), hold.Expires)
+}
+
+func (suite *HoldSuite) TestCreateHoldValidation() {
+	_, err := CreateHold(suite.src, []byte(`{"account_id":"1234","amount":500}`))
+	suite.Equal("Missing required customer_id", err.Error())
+	_, err = CreateHold(suite.src, []byte(`{"customer_id":"1","amount":500}`))
+	suite.Equal("Missing required account_id", err.Error())
+	_, err = CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234"}`))
+	suite.Equal("Invalid hold amount 0", err.Error())
+	_, err = CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500,"currency":"XYZ"}`))
+	suite.Equal("Invalid currency code XYZ", err.Error())
+	_, err = CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500,"expires":"2017-08-15T12:00:00Z"}`))
+	suite.Equal("Hold expiry must be in the future", err.Error())
+}
+
+func (suite *HoldSuite) TestMarshalJSON() {
+	hold, _ := CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500,"currency":"AUD"}`))
+	data, _ := hold.MarshalJSON()
+	suite.NotContains(string(data), "expires")
+	copy := new(Hold)
+	suite.Nil(copy.UnmarshalJSON(data))
+	suite.Equal(hold, copy)
+}
+
+func (suite *HoldSuite) TestCapture() {
+	hold, _ := CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500,"currency":"AUD"}`))
+	suite.Equal("Cannot capture 6.00 AUD of hold "+hold.ID+", only 5.00 AUD is held", hold.Capture(600, "t1").Error())
+	suite.Nil(hold.Capture(200, "t1"))
+	suite.Equal(HoldActive, hold.Status)
+	suite.Nil(hold.Capture(300, "t2"))
+	suite.Equal(HoldCaptured, hold.Status)
+	suite.Equal([]string{"t1", "t2"}, hold.TransferIDs)
+	suite.Equal("Cannot capture hold "+hold.ID+" with status captured", hold.Capture(1, "t3").Error())
+}
+
+func (suite *HoldSuite) TestRelease() {
+	hold, _ := CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500}`))
+	hold.Capture(200, "t1")
+	released, err := hold.Release(HoldReleased)
+	suite.Nil(err)
+	suite.Equal(int64(300), released)
+	suite.Equal(HoldReleased, hold.Status)
+	_, err = hold.Release(HoldReleased)
+	suite.Equal("Cannot release hold "+hold.ID+" with status released", err.Error())
+}
+
+func (suite *HoldSuite) TestIsExpired() {
+	hold, _ := CreateHold(suite.src, []byte(`{"customer_id":"1","account_id":"1234","amount":500,"expires":"2017-08-16T12:00:00Z"}`))
+	suite.False(hold.IsExpired(time.Date(2017, 8, 16, 11, 59, 59, 0, time.UTC)))
+	suite.True(hold.IsExpired(time.Date(2017, 8, 16, 12, 0, 0, 0, time.UTC)))
+	hold.Release(HoldReleased)
+	suite.False(hold.IsExpired(time.Date(2017, 8, 16, 12, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// IdempotencyObjectType blockchain object type
+const IdempotencyObjectType = "IdempotencyRecord"
+
+// IdempotencyRecord stores the result of a request made with an idempotency key,
+// so that retries of the request return the original result
+type IdempotencyRecord struct {
+	Entity
+	Key         string          `json:"key"`
+	CustomerID  string          `json:"customer_id"`
+	RequestHash string          `json:"request_hash"`
+	Result      json.RawMessage `json:"result"`
+	Created     int64           `json:"created"` // unix timestamp
+}
+
+// CreateIdempotencyRecord a factory function for creating new IdempotencyRecord entities
+func CreateIdempotencyRecord(src Source, customerID string, key string, requestHash string, result []byte) (*IdempotencyRecord, error) {
+	if key == "" {
+		return nil, errors.New("Missing required idempotency key")
+	}
+	return &IdempotencyRecord{
+		Entity:      Entity{IdempotencyObjectType},
+		Key:         key,
+		CustomerID:  customerID,
+		RequestHash: requestHash,
+		Result:      result,
+		Created:     src.Now().Unix(),
+	}, nil
+}
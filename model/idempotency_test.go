@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IdempotencySuite struct {
+	suite.Suite
+}
+
+func (suite *IdempotencySuite) TestCreateIdempotencyRecord() {
+	now := time.Date(2017, 8, 15, 0, 0, 0, 0, time.UTC)
+	record, err := CreateIdempotencyRecord(NewSequenceSource("t1", now), "1", "abc", "hash", []byte(`{}`))
+	suite.Nil(err)
+	suite.Equal(IdempotencyObjectType, record.GetObjectType())
+	suite.Equal("abc", record.Key)
+	suite.Equal(now.Unix(), record.Created)
+}
+
+func (suite *IdempotencySuite) TestCreateIdempotencyRecordMissingKey() {
+	_, err := CreateIdempotencyRecord(NewSequenceSource("t1", time.Now()), "1", "", "hash", nil)
+	suite.Equal("Missing required idempotency key", err.Error())
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
+const (
+	// LimitPolicyObjectType blockchain object type
+	LimitPolicyObjectType = "LimitPolicy"
+	// LimitUsageObjectType blockchain object type
+	LimitUsageObjectType = "LimitUsage"
+)
+
+// LimitScope stores allowed values for whose transfers a limit is applied to.
+// Allowed values are "account", "customer"
+type LimitScope string
+
+const (
+	// AccountScope limits the transfers from each account
+	AccountScope LimitScope = "account"
+	// CustomerScope limits the transfers from all accounts of each customer
+	CustomerScope LimitScope = "customer"
+)
+
+// LimitPeriod stores allowed values for the time window a limit applies to.
+// Allowed values are "transaction", "daily", "monthly"
+type LimitPeriod string
+
+const (
+	// TransactionLimit limits each transfer on its own
+	TransactionLimit LimitPeriod = "transaction"
+	// DailyLimit limits the transfers of a calendar day (UTC)
+	DailyLimit LimitPeriod = "daily"
+	// MonthlyLimit limits the transfers of a calendar month (UTC)
+	MonthlyLimit LimitPeriod = "monthly"
+)
+
+// LimitRule caps the amount and / or number of transfers in a corridor. Amounts are in
+// minor units of the source currency, so amount limits require a from_currency.
+type LimitRule struct {
+	ID string `json:"id"`
+	Corridor
+	Scope     LimitScope  `json:"scope,omitempty"` // defaults to account
+	Period    LimitPeriod `json:"period"`
+	MaxAmount int64       `json:"max_amount,omitempty"` // 0 for no amount limit
+	MaxCount  int64       `json:"max_count,omitempty"`  // 0 for no count limit
+}
+
+// LimitPolicy holds the limit rules applied to transfers. All matching rules apply.
+type LimitPolicy struct {
+	Entity
+	Rules []*LimitRule `json:"rules"`
+}
+
+// LimitUsage holds the amount and number of transfers counted against a rule
+// for a customer or account in one time window
+type LimitUsage struct {
+	Entity
+	RuleID     string `json:"rule_id"`
+	CustomerID string `json:"customer_id"`
+	AccountID  string `json:"account_id,omitempty"` // empty for customer limits
+	Window     string `json:"window"`               // day or month of the transfers
+	Amount     int64  `json:"amount"`
+	Count      int64  `json:"count"`
+}
+
+// LimitUsageList stores a list of limit usages
+type LimitUsageList struct {
+	Usage []*LimitUsage `json:"usage"`
+}
+
+// CreateLimitPolicy Factory function creates a new LimitPolicy struct and returns a pointer to it
+func CreateLimitPolicy(policyBytes []byte) (*LimitPolicy, error) {
+	policy := new(LimitPolicy)
+	if err := json.Unmarshal(policyBytes, policy); err != nil {
+		return nil, err
+	}
+	policy.ObjectType = LimitPolicyObjectType
+	ids := make(map[string]bool)
+	for i, rule := range policy.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid limit rule %d. Error: %s", i+1, err)
+		}
+		if ids[rule.ID] {
+			return nil, fmt.Errorf("Duplicate limit rule id %s", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	return policy, nil
+}
+
+// Validate - checks that the limit rule is complete and consistent
+func (r *LimitRule) Validate() error {
+	if r.ID == "" {
+		return errors.New("Missing required id value")
+	}
+	for _, code := range []string{r.FromCurrency, r.ToCurrency} {
+		if code != "" && !currency.IsValid(code) {
+			return fmt.Errorf("Invalid currency code %s", code)
+		}
+	}
+	if r.Scope == "" {
+		r.Scope = AccountScope
+	}
+	if r.Scope != AccountScope && r.Scope != CustomerScope {
+		return fmt.Errorf("Invalid limit scope %s", r.Scope)
+	}
+	switch r.Period {
+	case TransactionLimit:
+		if r.MaxCount != 0 {
+			return errors.New("A max_count requires a daily or monthly period")
+		}
+	case DailyLimit, MonthlyLimit:
+	default:
+		return fmt.Errorf("Invalid limit period %s", r.Period)
+	}
+	if r.MaxAmount < 0 || r.MaxCount < 0 || (r.MaxAmount == 0 && r.MaxCount == 0) {
+		return fmt.Errorf("Invalid limits max_amount %d, max_count %d", r.MaxAmount, r.MaxCount)
+	}
+	if r.MaxAmount > 0 && r.FromCurrency == "" {
+		return errors.New("A max_amount requires a from_currency value")
+	}
+	return nil
+}
+
+// Window returns the time window of a transfer at the given time, empty for per transaction limits
+func (r *LimitRule) Window(now time.Time) string {
+	switch r.Period {
+	case DailyLimit:
+		return now.UTC().Format("2006-01-02")
+	case MonthlyLimit:
+		return now.UTC().Format("2006-01")
+	}
+	return ""
+}
+
+// NewUsage returns the empty usage of the rule by the given account in the window of the given time
+func (r *LimitRule) NewUsage(customerID string, accountID string, now time.Time) *LimitUsage {
+	usage := &LimitUsage{
+		Entity:     Entity{LimitUsageObjectType},
+		RuleID:     r.ID,
+		CustomerID: customerID,
+		Window:     r.Window(now),
+	}
+	if r.Scope == AccountScope {
+		usage.AccountID = accountID
+	}
+	return usage
+}
+
+// Check returns an error if a transfer of the given amount would exceed the rule's
+// limits, given the usage so far
+func (r *LimitRule) Check(usage *LimitUsage, amount int64) error {
+	if r.MaxAmount > 0 && usage.Amount+amount > r.MaxAmount {
+		return fmt.Errorf("Transfer exceeds the %s limit of %s of rule %s",
+			r.Period, currency.Format(r.MaxAmount, r.FromCurrency), r.ID)
+	}
+	if r.MaxCount > 0 && usage.Count+1 > r.MaxCount {
+		return fmt.Errorf("Transfer exceeds the %s limit of %d transfers of rule %s", r.Period, r.MaxCount, r.ID)
+	}
+	return nil
+}
+
+// Add counts a transfer of the given amount
+func (u *LimitUsage) Add(amount int64) {
+	u.Amount += amount
+	u.Count++
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LimitsSuite struct {
+	suite.Suite
+}
+
+func (suite *LimitsSuite) TestCreateLimitPolicy() {
+	policy, err := CreateLimitPolicy([]byte(`{"rules":[{"id":"aud-daily","from_currency":"AUD","period":"daily","max_amount":100000,"max_count":5}]}`))
+	suite.Nil(err)
+	suite.Equal(LimitPolicyObjectType, policy.ObjectType)
+	suite.Equal(AccountScope, policy.Rules[0].Scope)
+}
+
+func (suite *LimitsSuite) TestCreateLimitPolicyValidation() {
+	for policy, message := range map[string]string{
+		`{"rules":[{"period":"daily","max_count":5}]}`:                                                      "Invalid limit rule 1. Error: Missing required id value",
+		`{"rules":[{"id":"a","period":"weekly","max_count":5}]}`:                                            "Invalid limit rule 1. Error: Invalid limit period weekly",
+		`{"rules":[{"id":"a","scope":"bank","period":"daily","max_count":5}]}`:                              "Invalid limit rule 1. Error: Invalid limit scope bank",
+		`{"rules":[{"id":"a","period":"transaction","max_count":5}]}`:                                       "Invalid limit rule 1. Error: A max_count requires a daily or monthly period",
+		`{"rules":[{"id":"a","period":"daily"}]}`:                                                           "Invalid limit rule 1. Error: Invalid limits max_amount 0, max_count 0",
+		`{"rules":[{"id":"a","period":"daily","max_amount":100}]}`:                                          "Invalid limit rule 1. Error: A max_amount requires a from_currency value",
+		`{"rules":[{"id":"a","from_currency":"XYZ","period":"daily","max_amount":100}]}`:                    "Invalid limit rule 1. Error: Invalid currency code XYZ",
+		`{"rules":[{"id":"a","period":"daily","max_count":1},{"id":"a","period":"monthly","max_count":1}]}`: "Duplicate limit rule id a",
+	} {
+		_, err := CreateLimitPolicy([]byte(policy))
+		suite.Equal(message, err.Error(), policy)
+	}
+}
+
+func (suite *LimitsSuite) TestWindow() {
+	now := time.Date(2017, 8, 15, 23, 30, 0, 0, time.FixedZone("AEST", -10*3600))
+	suite.Equal("2017-08-16", (&LimitRule{Period: DailyLimit}).Window(now))
+	suite.Equal("2017-08", (&LimitRule{Period: MonthlyLimit}).Window(now))
+	suite.Equal("", (&LimitRule{Period: TransactionLimit}).Window(now))
+}
+
+func (suite *LimitsSuite) TestNewUsage() {
+	now := time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC)
+	usage := (&LimitRule{ID: "a", Scope: AccountScope, Period: DailyLimit}).NewUsage("1", "1234", now)
+	suite.Equal("1234", usage.AccountID)
+	usage = (&LimitRule{ID: "a", Scope: CustomerScope, Period: DailyLimit}).NewUsage("1", "1234", now)
+	suite.Equal("", usage.AccountID)
+	suite.Equal("2017-08-15", usage.Window)
+}
+
+func (suite *LimitsSuite) TestCheck() {
+	rule := &LimitRule{ID: "a", Corridor: Corridor{FromCurrency: "AUD"}, Period: DailyLimit, MaxAmount: 1000, MaxCount: 2}
+	usage := &LimitUsage{}
+	suite.Nil(rule.Check(usage, 1000))
+	usage.Add(600)
+	suite.Nil(rule.Check(usage, 400))
+	suite.Equal("Transfer exceeds the daily limit of 10.00 AUD of rule a", rule.Check(usage, 401).Error())
+	usage.Add(100)
+	suite.Equal("Transfer exceeds the daily limit of 2 transfers of rule a", rule.Check(usage, 1).Error())
+}
@@ -0,0 +1,262 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AccountPartyObjectType blockchain object type of the index of accounts by joint holder and signatory
+const AccountPartyObjectType = "AccountParty"
+
+// DefaultApprovalExpiryHours time given to approve a transfer unless the approval policy sets it
+const DefaultApprovalExpiryHours = 72
+
+// Permission stores allowed values for what a signatory may do with an account.
+// Allowed values are "view", "pay", "full"
+type Permission string
+
+const (
+	// ViewPermission allows querying the account, its balance and transactions
+	ViewPermission Permission = "view"
+	// PayPermission additionally allows transfers from the account up to the signatory's pay limit
+	PayPermission Permission = "pay"
+	// FullPermission allows everything the account holders may do, except changing the holders and signatories
+	FullPermission Permission = "full"
+)
+
+// permissionRank orders permissions, each includes the ones ranked below it
+var permissionRank = map[Permission]int{ViewPermission: 1, PayPermission: 2, FullPermission: 3}
+
+// Signatory a customer authorised to act on an account of another customer
+type Signatory struct {
+	CustomerID string     `json:"customer_id"`
+	Permission Permission `json:"permission"`
+	PayLimit   int64      `json:"pay_limit,omitempty"` // per transfer, in minor units of the account currency, pay permission only
+}
+
+// ApprovalPolicy requires transfers above the threshold to be approved by a number of
+// the account's approvers, its holders and signatories with full permission, within
+// a number of hours after the transfer was requested
+type ApprovalPolicy struct {
+	Threshold   int64 `json:"threshold"`    // in minor units of the account currency
+	Required    int   `json:"required"`     // number of distinct approvals
+	ExpiryHours int64 `json:"expiry_hours"` // defaults to DefaultApprovalExpiryHours
+}
+
+// AccountParty indexes an account under one of its joint holders or signatories
+type AccountParty struct {
+	Entity
+	PartyID    string `json:"party_id"`
+	CustomerID string `json:"customer_id"`
+	AccountID  string `json:"account_id"`
+}
+
+// CreateSignatory Factory function creates a new Signatory struct and returns a pointer to it
+func CreateSignatory(signatoryBytes []byte) (*Signatory, error) {
+	s := new(Signatory)
+	if err := json.Unmarshal(signatoryBytes, s); err != nil {
+		return nil, err
+	}
+	if s.CustomerID == "" {
+		return nil, errors.New("Missing required customer_id")
+	}
+	if _, ok := permissionRank[s.Permission]; !ok {
+		return nil, fmt.Errorf("Invalid permission %s", s.Permission)
+	}
+	if s.Permission == PayPermission && s.PayLimit <= 0 {
+		return nil, fmt.Errorf("Invalid pay_limit %d", s.PayLimit)
+	}
+	if s.Permission != PayPermission {
+		s.PayLimit = 0
+	}
+	return s, nil
+}
+
+// CreateApprovalPolicy Factory function creates a new ApprovalPolicy struct and returns a
+// pointer to it, or nil if the JSON removes the policy with a required value of 0
+func CreateApprovalPolicy(policyBytes []byte) (*ApprovalPolicy, error) {
+	p := new(ApprovalPolicy)
+	if err := json.Unmarshal(policyBytes, p); err != nil {
+		return nil, err
+	}
+	if p.Required == 0 {
+		return nil, nil
+	}
+	if p.Required < 2 {
+		return nil, fmt.Errorf("Invalid required approvals %d, must be at least 2", p.Required)
+	}
+	if p.Threshold < 0 {
+		return nil, fmt.Errorf("Invalid approval threshold %d", p.Threshold)
+	}
+	if p.ExpiryHours < 0 {
+		return nil, fmt.Errorf("Invalid approval expiry_hours %d", p.ExpiryHours)
+	}
+	if p.ExpiryHours == 0 {
+		p.ExpiryHours = DefaultApprovalExpiryHours
+	}
+	return p, nil
+}
+
+// Deadline returns the time by which a transfer requested at the given time must be approved
+func (p *ApprovalPolicy) Deadline(now time.Time) time.Time {
+	hours := p.ExpiryHours
+	if hours == 0 {
+		hours = DefaultApprovalExpiryHours
+	}
+	return now.Add(time.Duration(hours) * time.Hour)
+}
+
+// IsHolder returns true if the customer is the owner or a joint holder of the account
+func (a *Account) IsHolder(customerID string) bool {
+	if customerID == a.CustomerID {
+		return true
+	}
+	for _, holder := range a.JointHolders {
+		if holder == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionOf returns the permission of a customer on the account, full for its holders
+// and empty for customers that are not a party to the account
+func (a *Account) PermissionOf(customerID string) Permission {
+	if a.IsHolder(customerID) {
+		return FullPermission
+	}
+	if s := a.signatory(customerID); s != nil {
+		return s.Permission
+	}
+	return ""
+}
+
+// Allows returns true if the customer has at least the given permission on the account
+func (a *Account) Allows(customerID string, p Permission) bool {
+	return permissionRank[a.PermissionOf(customerID)] >= permissionRank[p]
+}
+
+// CanPay returns true if the customer may transfer the amount from the account
+func (a *Account) CanPay(customerID string, amount int64) bool {
+	if a.Allows(customerID, FullPermission) {
+		return true
+	}
+	s := a.signatory(customerID)
+	return s != nil && s.Permission == PayPermission && amount <= s.PayLimit
+}
+
+// Parties returns the IDs of the joint holders and signatories of the account
+func (a *Account) Parties() []string {
+	parties := append([]string{}, a.JointHolders...)
+	for _, s := range a.Signatories {
+		parties = append(parties, s.CustomerID)
+	}
+	return parties
+}
+
+// Approvers returns the IDs of the customers who may approve transfers from the account,
+// its holders and signatories with full permission
+func (a *Account) Approvers() []string {
+	approvers := append([]string{a.CustomerID}, a.JointHolders...)
+	for _, s := range a.Signatories {
+		if s.Permission == FullPermission {
+			approvers = append(approvers, s.CustomerID)
+		}
+	}
+	return approvers
+}
+
+// IsApprover returns true if the customer may approve transfers from the account
+func (a *Account) IsApprover(customerID string) bool {
+	for _, approver := range a.Approvers() {
+		if approver == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddJointHolder adds a customer as a joint holder of the account
+func (a *Account) AddJointHolder(customerID string) error {
+	if a.IsHolder(customerID) || a.signatory(customerID) != nil {
+		return fmt.Errorf("Customer %s is already a party to account %s", customerID, a.ID)
+	}
+	a.JointHolders = append(a.JointHolders, customerID)
+	return nil
+}
+
+// SetSignatory adds a signatory to the account or replaces the mandate of an existing signatory
+func (a *Account) SetSignatory(s *Signatory) error {
+	if a.IsHolder(s.CustomerID) {
+		return fmt.Errorf("Customer %s is a holder of account %s", s.CustomerID, a.ID)
+	}
+	signatories := []*Signatory{}
+	for _, existing := range a.Signatories {
+		if existing.CustomerID != s.CustomerID {
+			signatories = append(signatories, existing)
+		}
+	}
+	return a.changeParties(a.JointHolders, append(signatories, s))
+}
+
+// RemoveParty removes a joint holder or signatory from the account
+func (a *Account) RemoveParty(customerID string) error {
+	if customerID == a.CustomerID {
+		return fmt.Errorf("Cannot remove owner %s of account %s", customerID, a.ID)
+	}
+	holders := []string{}
+	for _, holder := range a.JointHolders {
+		if holder != customerID {
+			holders = append(holders, holder)
+		}
+	}
+	signatories := []*Signatory{}
+	for _, s := range a.Signatories {
+		if s.CustomerID != customerID {
+			signatories = append(signatories, s)
+		}
+	}
+	if len(holders) == len(a.JointHolders) && len(signatories) == len(a.Signatories) {
+		return fmt.Errorf("Customer %s is not a party to account %s", customerID, a.ID)
+	}
+	return a.changeParties(holders, signatories)
+}
+
+// SetApprovalPolicy sets the approval policy of the account, nil removes it
+func (a *Account) SetApprovalPolicy(p *ApprovalPolicy) error {
+	if p != nil && p.Required > len(a.Approvers()) {
+		return fmt.Errorf("Approval policy requires %d approvals, account %s has %d approvers", p.Required, a.ID, len(a.Approvers()))
+	}
+	a.Approval = p
+	return nil
+}
+
+// NeedsApproval returns true if a transfer of the amount from the account requires approval
+func (a *Account) NeedsApproval(amount int64) bool {
+	return a.Approval != nil && amount > a.Approval.Threshold
+}
+
+// changeParties replaces the joint holders and signatories, unless that would leave
+// fewer approvers than the approval policy requires
+func (a *Account) changeParties(holders []string, signatories []*Signatory) error {
+	changed := *a
+	changed.JointHolders = holders
+	changed.Signatories = signatories
+	if a.Approval != nil && a.Approval.Required > len(changed.Approvers()) {
+		return fmt.Errorf("Approval policy of account %s requires %d approvers", a.ID, a.Approval.Required)
+	}
+	a.JointHolders = holders
+	a.Signatories = signatories
+	return nil
+}
+
+func (a *Account) signatory(customerID string) *Signatory {
+	for _, s := range a.Signatories {
+		if s.CustomerID == customerID {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MandateSuite struct {
+	suite.Suite
+	account *Account
+}
+
+func (suite *MandateSuite) SetupTest() {
+	suite.account = &Account{Entity: Entity{AccountObjectType}, ID: "1234", CustomerID: "1", CurrencyCode: "AUD"}
+}
+
+func (suite *MandateSuite) TestCreateSignatory() {
+	s, err := CreateSignatory([]byte(`{"customer_id":"2","permission":"pay","pay_limit":5000}`))
+	suite.Nil(err)
+	suite.Equal(&Signatory{CustomerID: "2", Permission: PayPermission, PayLimit: 5000}, s)
+	s, err = CreateSignatory([]byte(`{"customer_id":"2","permission":"view","pay_limit":5000}`))
+	suite.Nil(err)
+	suite.Equal(int64(0), s.PayLimit, "Only the pay permission has a pay limit")
+
+	_, err = CreateSignatory([]byte(`{"permission":"view"}`))
+	suite.Equal("Missing required customer_id", err.Error())
+	_, err = CreateSignatory([]byte(`{"customer_id":"2","permission":"admin"}`))
+	suite.Equal("Invalid permission admin", err.Error())
+	_, err = CreateSignatory([]byte(`{"customer_id":"2","permission":"pay"}`))
+	suite.Equal("Invalid pay_limit 0", err.Error())
+}
+
+func (suite *MandateSuite) TestCreateApprovalPolicy() {
+	p, err := CreateApprovalPolicy([]byte(`{"threshold":100000,"required":2}`))
+	suite.Nil(err)
+	suite.Equal(&ApprovalPolicy{Threshold: 100000, Required: 2, ExpiryHours: DefaultApprovalExpiryHours}, p)
+	p, err = CreateApprovalPolicy([]byte(`{"threshold":100000,"required":2,"expiry_hours":24}`))
+	suite.Nil(err)
+	suite.Equal(time.Unix(1502798400+24*60*60, 0), p.Deadline(time.Unix(1502798400, 0)))
+	p, err = CreateApprovalPolicy([]byte(`{"required":0}`))
+	suite.Nil(err)
+	suite.Nil(p, "A required value of 0 removes the policy")
+
+	_, err = CreateApprovalPolicy([]byte(`{"threshold":100000,"required":1}`))
+	suite.Equal("Invalid required approvals 1, must be at least 2", err.Error())
+	_, err = CreateApprovalPolicy([]byte(`{"threshold":-1,"required":2}`))
+	suite.Equal("Invalid approval threshold -1", err.Error())
+	_, err = CreateApprovalPolicy([]byte(`{"required":2,"expiry_hours":-1}`))
+	suite.Equal("Invalid approval expiry_hours -1", err.Error())
+}
+
+func (suite *MandateSuite) TestPermissions() {
+	a := suite.account
+	suite.Nil(a.AddJointHolder("2"))
+	suite.Nil(a.SetSignatory(&Signatory{CustomerID: "3", Permission: ViewPermission}))
+	suite.Nil(a.SetSignatory(&Signatory{CustomerID: "4", Permission: PayPermission, PayLimit: 500}))
+	suite.Nil(a.SetSignatory(&Signatory{CustomerID: "5", Permission: FullPermission}))
+
+	suite.Equal(FullPermission, a.PermissionOf("1"))
+	suite.Equal(FullPermission, a.PermissionOf("2"))
+	suite.Equal(Permission(""), a.PermissionOf("6"))
+	suite.True(a.Allows("3", ViewPermission))
+	suite.False(a.Allows("3", PayPermission))
+	suite.True(a.Allows("4", ViewPermission))
+	suite.False(a.Allows("4", FullPermission))
+	suite.False(a.Allows("6", ViewPermission))
+
+	suite.True(a.CanPay("2", 1000000))
+	suite.True(a.CanPay("4", 500))
+	suite.False(a.CanPay("4", 501))
+	suite.False(a.CanPay("3", 1))
+	suite.True(a.CanPay("5", 1000000))
+
+	suite.Equal([]string{"2", "3", "4", "5"}, a.Parties())
+	suite.Equal([]string{"1", "2", "5"}, a.Approvers())
+	suite.True(a.IsApprover("5"))
+	suite.False(a.IsApprover("4"))
+}
+
+func (suite *MandateSuite) TestChangeParties() {
+	a := suite.account
+	suite.Nil(a.AddJointHolder("2"))
+	suite.Equal("Customer 2 is already a party to account 1234", a.AddJointHolder("2").Error())
+	suite.Equal("Customer 1 is already a party to account 1234", a.AddJointHolder("1").Error())
+	suite.Equal("Customer 2 is a holder of account 1234", a.SetSignatory(&Signatory{CustomerID: "2", Permission: ViewPermission}).Error())
+
+	suite.Nil(a.SetSignatory(&Signatory{CustomerID: "3", Permission: ViewPermission}))
+	suite.Nil(a.SetSignatory(&Signatory{CustomerID: "3", Permission: PayPermission, PayLimit: 100}))
+	suite.Len(a.Signatories, 1, "Setting a signatory again replaces the mandate")
+	suite.Equal(PayPermission, a.PermissionOf("3"))
+
+	suite.Equal("Cannot remove owner 1 of account 1234", a.RemoveParty("1").Error())
+	suite.Equal("Customer 4 is not a party to account 1234", a.RemoveParty("4").Error())
+	suite.Nil(a.RemoveParty("3"))
+	suite.Empty(a.Signatories)
+}
+
+func (suite *MandateSuite) TestApprovalPolicy() {
+	a := suite.account
+	policy := &ApprovalPolicy{Threshold: 1000, Required: 2}
+	suite.Equal("Approval policy requires 2 approvals, account 1234 has 1 approvers", a.SetApprovalPolicy(policy).Error())
+	suite.Nil(a.AddJointHolder("2"))
+	suite.Nil(a.SetApprovalPolicy(policy))
+	suite.False(a.NeedsApproval(1000))
+	suite.True(a.NeedsApproval(1001))
+
+	suite.Equal("Approval policy of account 1234 requires 2 approvers", a.RemoveParty("2").Error())
+	suite.True(a.IsHolder("2"), "Failed changes leave the parties unchanged")
+	suite.Nil(a.SetApprovalPolicy(nil))
+	suite.Nil(a.RemoveParty("2"))
+	suite.False(a.NeedsApproval(1001))
+}
+
+func (suite *MandateSuite) TestTransferApproval() {
+	now := time.Unix(1502798400, 0)
+	t := &Transfer{ID: "t1", Status: TransferPending}
+	suite.Equal("Transfer t1 is not pending approval", t.AddApproval("1", now).Error())
+
+	t.HoldForApproval(2, now.Add(time.Hour), "h1")
+	suite.Equal(TransferPendingApproval, t.Status)
+	suite.Equal("h1", t.HoldID)
+	suite.Nil(t.AddApproval("1", now))
+	suite.Equal("Transfer t1 has already been approved by customer 1", t.AddApproval("1", now).Error())
+	suite.Equal(TransferPendingApproval, t.Status)
+	suite.Nil(t.AddApproval("2", now))
+	suite.True(t.Approval.Complete())
+	suite.Equal(TransferPending, t.Status)
+	suite.Equal([]Approval{{"1", 1502798400}, {"2", 1502798400}}, t.Approval.Approvals)
+
+	t.HoldForApproval(2, now.Add(time.Hour), "h2")
+	suite.False(t.Approval.Expired(now.Add(time.Hour - time.Second)))
+	suite.True(t.Approval.Expired(now.Add(time.Hour)))
+	suite.Equal("Approval of transfer t1 has expired", t.AddApproval("1", now.Add(time.Hour)).Error())
+}
@@ -7,9 +7,23 @@ import (
 )
 
 func TestSuite(t *testing.T) {
+	suite.Run(t, new(DecimalSuite))
 	suite.Run(t, new(RatesSuite))
 	suite.Run(t, new(UserSuite))
 	suite.Run(t, new(AccountSuite))
 	suite.Run(t, new(TransactionSuite))
 	suite.Run(t, new(TransferSuite))
+	suite.Run(t, new(SourceSuite))
+	suite.Run(t, new(FeesSuite))
+	suite.Run(t, new(IdempotencySuite))
+	suite.Run(t, new(ReversalSuite))
+	suite.Run(t, new(HoldSuite))
+	suite.Run(t, new(EscrowSuite))
+	suite.Run(t, new(ScheduleSuite))
+	suite.Run(t, new(BatchSuite))
+	suite.Run(t, new(SplitSuite))
+	suite.Run(t, new(LimitsSuite))
+	suite.Run(t, new(WatchListSuite))
+	suite.Run(t, new(ReviewSuite))
+	suite.Run(t, new(MandateSuite))
 }
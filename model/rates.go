@@ -1,16 +1,171 @@
 package model
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
 // RatesObjectType blockchain object type
 const RatesObjectType = "Rates"
 
-// Rates represents exchange rates for a given base at a given date
+// LatestRatesObjectType blockchain object type of the pointer to the latest rates of a base
+const LatestRatesObjectType = "LatestRates"
+
+// RatesLookbackDays number of days before a date searched for the rates in effect on it,
+// when neither rates for the date itself nor the latest rates apply
+const RatesLookbackDays = 7
+
+// RatesConfigObjectType blockchain object type
+const RatesConfigObjectType = "RatesConfig"
+
+// RatesDateFormat layout of the date rates are published for
+const RatesDateFormat = "2006-01-02"
+
+// ConversionRateScale number of fractional digits recorded for derived (inverse and cross) rates
+const ConversionRateScale = 10
+
+// Rates represents exchange rates for a given base at a given date.
+// Currencies maps ISO 4217 currency codes to the number of units per unit of the base currency.
 type Rates struct {
 	Entity
-	Base       string `json:"base"`
-	Date       string `json:"date"`
-	Currencies struct {
-		HKD float64
-		NZD float64
-		SGD float64
-	} `json:"rates"`
+	Base       string             `json:"base"`
+	Date       string             `json:"date"`
+	Currencies map[string]Decimal `json:"rates"`
+}
+
+// LatestRates points to the date of the most recently published rates of a base currency
+type LatestRates struct {
+	Entity
+	Base string `json:"base"`
+	Date string `json:"date"`
+}
+
+// RatesConfig holds the settings applied when converting between currencies. Conversions
+// between two currencies without rates of their own use cross rates of the reference
+// currency; without a reference currency they fail.
+type RatesConfig struct {
+	Entity
+	ReferenceCurrency string `json:"reference_currency,omitempty"`
+}
+
+// RatesList holds a list of exchange rates
+type RatesList struct {
+	Rates []*Rates `json:"rates"`
+}
+
+// CreateRates Factory function creates a new Rates struct and returns a pointer to it
+func CreateRates(ratesBytes []byte) (*Rates, error) {
+	rates := new(Rates)
+	if err := json.Unmarshal(ratesBytes, rates); err != nil {
+		return nil, err
+	}
+	rates.ObjectType = RatesObjectType
+	if err := rates.Validate(); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// CreateRatesConfig Factory function creates a new RatesConfig struct and returns a pointer to it
+func CreateRatesConfig(configBytes []byte) (*RatesConfig, error) {
+	config := new(RatesConfig)
+	if err := json.Unmarshal(configBytes, config); err != nil {
+		return nil, err
+	}
+	config.ObjectType = RatesConfigObjectType
+	if config.ReferenceCurrency != "" && !currency.IsValid(config.ReferenceCurrency) {
+		return nil, fmt.Errorf("Invalid reference currency code %s", config.ReferenceCurrency)
+	}
+	return config, nil
+}
+
+// Validate - checks that the base, date and all rates are valid
+func (r *Rates) Validate() error {
+	if r.Base == "" {
+		return errors.New("Missing required base value")
+	}
+	if !currency.IsValid(r.Base) {
+		return fmt.Errorf("Invalid base currency code %s", r.Base)
+	}
+	if r.Date == "" {
+		return errors.New("Missing required date value")
+	}
+	if _, err := time.Parse(RatesDateFormat, r.Date); err != nil {
+		return fmt.Errorf("Invalid date value %s, expected format YYYY-MM-DD", r.Date)
+	}
+	if len(r.Currencies) == 0 {
+		return errors.New("Missing required rates value")
+	}
+	for code, rate := range r.Currencies {
+		if !currency.IsValid(code) || code == r.Base {
+			return fmt.Errorf("Invalid currency code %s", code)
+		}
+		if rate.Sign() <= 0 {
+			return fmt.Errorf("Invalid %s exchange rate %s", code, rate)
+		}
+	}
+	return nil
+}
+
+// Conversion holds the details of a currency conversion applied to a transfer
+type Conversion struct {
+	Rate                Decimal `json:"rate"`
+	SourceCurrency      string  `json:"source_currency"`
+	SourceAmount        int64   `json:"source_amount"`
+	DestinationCurrency string  `json:"destination_currency"`
+	DestinationAmount   int64   `json:"destination_amount"`
+}
+
+// Rate returns the exchange rate from the base currency into the given currency
+func (r *Rates) Rate(currencyCode string) (Decimal, error) {
+	if currencyCode == r.Base {
+		return MustParseDecimal("1"), nil
+	}
+	rate, ok := r.Currencies[currencyCode]
+	if !ok || rate.Sign() <= 0 {
+		return Decimal{}, fmt.Errorf("No %s exchange rate available for base %s on %s", currencyCode, r.Base, r.Date)
+	}
+	return rate, nil
+}
+
+// Convert converts an amount in minor units between two currencies. Either currency
+// may be the base (direct or inverse rate), otherwise the cross rate via the base is used.
+// The converted amount is rounded half away from zero to whole minor units of the
+// destination currency.
+func (r *Rates) Convert(amount int64, from string, to string) (*Conversion, error) {
+	fromCurrency, err := currency.Lookup(from)
+	if err != nil {
+		return nil, err
+	}
+	toCurrency, err := currency.Lookup(to)
+	if err != nil {
+		return nil, err
+	}
+	fromRate, err := r.Rate(from)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := r.Rate(to)
+	if err != nil {
+		return nil, err
+	}
+	rate := toRate
+	if from != r.Base {
+		rate = NewDecimalFromRat(new(big.Rat).Quo(toRate.Rat(), fromRate.Rat()), ConversionRateScale)
+	}
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), toRate.Rat())
+	converted.Quo(converted, fromRate.Rat())
+	converted.Mul(converted, big.NewRat(toCurrency.Factor(), fromCurrency.Factor()))
+	return &Conversion{
+		Rate:                rate,
+		SourceCurrency:      from,
+		SourceAmount:        amount,
+		DestinationCurrency: to,
+		DestinationAmount:   roundHalfAwayFromZero(converted).Int64(),
+	}, nil
 }
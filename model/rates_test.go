@@ -17,14 +17,10 @@ func (suite *RatesSuite) SetupTest() {
 		Entity: Entity{"Rates"},
 		Base:   "AUD",
 		Date:   "2017-08-14",
-		Currencies: struct {
-			HKD float64
-			NZD float64
-			SGD float64
-		}{
-			HKD: 6.1484,
-			NZD: 1.0793,
-			SGD: 1.0729,
+		Currencies: map[string]Decimal{
+			"HKD": MustParseDecimal("6.1484"),
+			"NZD": MustParseDecimal("1.0793"),
+			"SGD": MustParseDecimal("1.0729"),
 		},
 	}
 	suite.testRateBytes = []byte(`{"docType":"Rates","base":"AUD","date":"2017-08-14","rates":{"HKD":6.1484,"NZD":1.0793,"SGD":1.0729}}`)
@@ -46,3 +42,97 @@ func (suite *RatesSuite) TestMarshalRates() {
 	actual, _ := json.Marshal(suite.testRate)
 	suite.Equal(expected, actual)
 }
+
+func (suite *RatesSuite) TestRate() {
+	rate, err := suite.testRate.Rate("NZD")
+	suite.Nil(err)
+	suite.Equal("1.0793", rate.String())
+}
+
+func (suite *RatesSuite) TestRateOfBase() {
+	rate, err := suite.testRate.Rate("AUD")
+	suite.Nil(err)
+	suite.Equal("1", rate.String())
+}
+
+func (suite *RatesSuite) TestRateUnknownCurrency() {
+	_, err := suite.testRate.Rate("USD")
+	suite.Equal("No USD exchange rate available for base AUD on 2017-08-14", err.Error())
+}
+
+func (suite *RatesSuite) TestConvertDirect() {
+	c, err := suite.testRate.Convert(1000, "AUD", "NZD")
+	suite.Nil(err)
+	suite.Equal(&Conversion{MustParseDecimal("1.0793"), "AUD", 1000, "NZD", 1079}, c)
+}
+
+func (suite *RatesSuite) TestConvertInverse() {
+	c, err := suite.testRate.Convert(1079, "NZD", "AUD")
+	suite.Nil(err)
+	suite.Equal("0.9265264523", c.Rate.String())
+	suite.Equal(int64(1000), c.DestinationAmount) // 999.7220...
+}
+
+func (suite *RatesSuite) TestConvertCross() {
+	c, err := suite.testRate.Convert(10000, "NZD", "SGD")
+	suite.Nil(err)
+	suite.Equal("0.9940702307", c.Rate.String())
+	suite.Equal(int64(9941), c.DestinationAmount) // 9940.7023...
+}
+
+func (suite *RatesSuite) TestConvertRoundsHalfAwayFromZero() {
+	c, _ := suite.testRate.Convert(250, "AUD", "HKD") // 1537.1
+	suite.Equal(int64(1537), c.DestinationAmount)
+	c, _ = suite.testRate.Convert(50, "AUD", "SGD") // 53.645
+	suite.Equal(int64(54), c.DestinationAmount)
+	c, _ = suite.testRate.Convert(-50, "AUD", "SGD") // -53.645
+	suite.Equal(int64(-54), c.DestinationAmount)
+}
+
+func (suite *RatesSuite) TestCreateRates() {
+	rates, err := CreateRates([]byte(`{"base":"AUD","date":"2017-08-14","rates":{"HKD":6.1484,"NZD":1.0793,"SGD":1.0729}}`))
+	suite.Nil(err)
+	suite.Equal(&suite.testRate, rates)
+}
+
+func (suite *RatesSuite) TestCreateRatesMissingBase() {
+	_, err := CreateRates([]byte(`{"date":"2017-08-14","rates":{"NZD":1.0793}}`))
+	suite.Equal("Missing required base value", err.Error())
+}
+
+func (suite *RatesSuite) TestCreateRatesAnyCurrency() {
+	rates, err := CreateRates([]byte(`{"base":"AUD","date":"2017-08-14","rates":{"USD":0.7853,"EUR":0.6671}}`))
+	suite.Nil(err)
+	suite.Equal("0.7853", rates.Currencies["USD"].String())
+}
+
+func (suite *RatesSuite) TestCreateRatesUnknownCurrency() {
+	_, err := CreateRates([]byte(`{"base":"AUD","date":"2017-08-14","rates":{"XYZ":1.5}}`))
+	suite.Equal("Invalid currency code XYZ", err.Error())
+}
+
+func (suite *RatesSuite) TestCreateRatesInvalidRate() {
+	_, err := CreateRates([]byte(`{"base":"AUD","date":"2017-08-14","rates":{"NZD":0}}`))
+	suite.Equal("Invalid NZD exchange rate 0", err.Error())
+}
+
+func (suite *RatesSuite) TestCreateRatesInvalidDate() {
+	_, err := CreateRates([]byte(`{"base":"AUD","date":"14/08/2017","rates":{"NZD":1.0793}}`))
+	suite.Equal("Invalid date value 14/08/2017, expected format YYYY-MM-DD", err.Error())
+}
+
+func (suite *RatesSuite) TestConvertMinorUnits() {
+	rates := &Rates{Base: "AUD", Date: "2017-08-14", Currencies: map[string]Decimal{
+		"JPY": MustParseDecimal("86.52"),
+		"KWD": MustParseDecimal("0.2372"),
+	}}
+	c, err := rates.Convert(1050, "AUD", "JPY") // 10.50 AUD = 908.46 JPY
+	suite.Nil(err)
+	suite.Equal(int64(908), c.DestinationAmount)
+	c, err = rates.Convert(1050, "AUD", "KWD") // 10.50 AUD = 2.4906 KWD
+	suite.Nil(err)
+	suite.Equal(int64(2491), c.DestinationAmount)
+	c, err = rates.Convert(908, "JPY", "AUD") // 908 JPY = 10.4947 AUD
+	suite.Nil(err)
+	suite.Equal(int64(1049), c.DestinationAmount)
+}
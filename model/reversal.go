@@ -0,0 +1,88 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
+// ValidateReversal checks that amount (in minor units of the transfer currency)
+// of the transfer can be reversed
+func (t *Transfer) ValidateReversal(amount int64) error {
+	if t.OriginalID != "" {
+		return fmt.Errorf("Cannot reverse transfer %s, it is a reversal of transfer %s", t.ID, t.OriginalID)
+	}
+	if t.IsSplit() {
+		return fmt.Errorf("Cannot reverse split transfer %s", t.ID)
+	}
+	if t.Status != TransferCompleted && t.Status != TransferPartiallyReversed {
+		return fmt.Errorf("Cannot reverse transfer %s with status %s", t.ID, t.Status)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Invalid reversal amount %d", amount)
+	}
+	if remaining := t.Amount - t.Reversed; amount > remaining {
+		return fmt.Errorf("Cannot reverse %s of transfer %s, only %s can be reversed",
+			currency.Format(amount, t.CurrencyCode), t.ID, currency.Format(remaining, t.CurrencyCode))
+	}
+	return nil
+}
+
+// CreateReversal a factory function for the transfer reversing amount (in minor units
+// of the transfer currency) of the original transfer. credit is the credited leg of the
+// original transfer: the payee is debited the same share of the credited amount, in the
+// currency it was credited in, so converted transfers are reversed at the original rate.
+// Fees are not refunded. The conversion back into the original currency is returned
+// along with the reversal.
+func CreateReversal(src Source, original *Transfer, credit *Transaction, amount int64) (*Transfer, *Conversion, error) {
+	if credit == nil || credit.Status != Credited {
+		return nil, nil, errors.New("Missing credited transaction of the original transfer")
+	}
+	if err := original.ValidateReversal(amount); err != nil {
+		return nil, nil, err
+	}
+	reversal := &Transfer{
+		FromCustomerID: original.ToCustomerID,
+		FromAccountID:  original.ToAccountID,
+		ToCustomerID:   original.FromCustomerID,
+		ToAccountID:    original.FromAccountID,
+		CurrencyCode:   credit.CurrencyCode,
+		Description:    fmt.Sprintf("Reversal of transfer %s", original.ID),
+	}
+	reversal.Begin(src)
+	reversal.OriginalID = original.ID
+
+	// shares are taken of the cumulative reversed amount, so that the shares of
+	// partial reversals add up to the credited amount
+	reversal.Amount = share(credit.Amount, original.Reversed+amount, original.Amount) - share(credit.Amount, original.Reversed, original.Amount)
+	if credit.Conversion == nil {
+		return reversal, nil, nil
+	}
+	return reversal, &Conversion{
+		Rate:                NewDecimalFromRat(new(big.Rat).Inv(credit.Conversion.Rate.Rat()), ConversionRateScale),
+		SourceCurrency:      credit.CurrencyCode,
+		SourceAmount:        reversal.Amount,
+		DestinationCurrency: original.CurrencyCode,
+		DestinationAmount:   amount,
+	}, nil
+}
+
+// ApplyReversal records that amount (in minor units of the transfer currency) of the
+// transfer has been reversed by the given reversal transfer
+func (t *Transfer) ApplyReversal(reversal *Transfer, amount int64) {
+	t.Reversed += amount
+	t.Reversals = append(t.Reversals, reversal.ID)
+	if t.Reversed >= t.Amount {
+		t.Status = TransferReversed
+	} else {
+		t.Status = TransferPartiallyReversed
+	}
+}
+
+// share returns total * n / d rounded half away from zero
+func share(total int64, n int64, d int64) int64 {
+	r := new(big.Rat).Mul(big.NewRat(total, 1), big.NewRat(n, d))
+	return roundHalfAwayFromZero(r).Int64()
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReversalSuite struct {
+	suite.Suite
+	src      Source
+	original *Transfer
+	credit   *Transaction
+}
+
+func (suite *ReversalSuite) SetupTest() {
+	suite.src = NewSequenceSource("tx1", time.Unix(1502798400, 0))
+	suite.original = &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 1000, CurrencyCode: "AUD"}
+	suite.original.Begin(suite.src)
+	conversion := &Conversion{Rate: MustParseDecimal("1.0801"), SourceCurrency: "AUD", SourceAmount: 1000, DestinationCurrency: "NZD", DestinationAmount: 1080}
+	suite.credit, _ = CreateTransaction(suite.src, "2", "5678", suite.original, conversion, TxFailureCodeNone, Credited)
+	suite.original.AddLeg(suite.credit)
+	suite.original.Complete()
+}
+
+func (suite *ReversalSuite) TestValidateReversal() {
+	suite.Nil(suite.original.ValidateReversal(1000))
+	suite.Equal("Invalid reversal amount -1", suite.original.ValidateReversal(-1).Error())
+	suite.Equal("Cannot reverse 10.01 AUD of transfer "+suite.original.ID+", only 10.00 AUD can be reversed", suite.original.ValidateReversal(1001).Error())
+	suite.original.Fail(InsufficientFunds, "Insufficient funds available in account 1234")
+	suite.Equal("Cannot reverse transfer "+suite.original.ID+" with status failed", suite.original.ValidateReversal(1000).Error())
+}
+
+func (suite *ReversalSuite) TestCreateReversal() {
+	reversal, conversion, err := CreateReversal(suite.src, suite.original, suite.credit, 500)
+	suite.Nil(err)
+	suite.Equal(TransferPending, reversal.Status)
+	suite.Equal(suite.original.ID, reversal.OriginalID)
+	suite.Equal("2", reversal.FromCustomerID)
+	suite.Equal("5678", reversal.FromAccountID)
+	suite.Equal("1", reversal.ToCustomerID)
+	suite.Equal("1234", reversal.ToAccountID)
+	suite.Equal(int64(540), reversal.Amount)
+	suite.Equal("NZD", reversal.CurrencyCode)
+	suite.Equal(&Conversion{Rate: MustParseDecimal("0.9258402"), SourceCurrency: "NZD", SourceAmount: 540, DestinationCurrency: "AUD", DestinationAmount: 500}, conversion)
+}
+
+func (suite *ReversalSuite) TestCreateReversalSharesAddUp() {
+	var total int64
+	for _, amount := range []int64{333, 333, 334} {
+		reversal, _, err := CreateReversal(suite.src, suite.original, suite.credit, amount)
+		suite.Nil(err)
+		suite.original.ApplyReversal(reversal, amount)
+		total += reversal.Amount
+	}
+	suite.Equal(int64(1080), total)
+	suite.Equal(TransferReversed, suite.original.Status)
+	suite.Equal(3, len(suite.original.Reversals))
+}
+
+func (suite *ReversalSuite) TestApplyReversal() {
+	reversal, _, _ := CreateReversal(suite.src, suite.original, suite.credit, 100)
+	suite.original.ApplyReversal(reversal, 100)
+	suite.Equal(TransferPartiallyReversed, suite.original.Status)
+	suite.Equal(int64(100), suite.original.Reversed)
+	suite.Equal([]string{reversal.ID}, suite.original.Reversals)
+}
+
+func (suite *ReversalSuite) TestCannotReverseReversal() {
+	reversal, _, _ := CreateReversal(suite.src, suite.original, suite.credit, 100)
+	reversal.Complete()
+	suite.Equal("Cannot reverse transfer "+reversal.ID+", it is a reversal of transfer "+suite.original.ID, reversal.ValidateReversal(100).Error())
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
+const (
+	// ReviewPolicyObjectType blockchain object type
+	ReviewPolicyObjectType = "ReviewPolicy"
+	// PendingReviewObjectType blockchain object type of the index of transfers pending review
+	PendingReviewObjectType = "PendingReview"
+)
+
+// ReviewRuleType stores allowed values for the condition of a review rule.
+// Allowed values are "amount", "country", "new_account"
+type ReviewRuleType string
+
+const (
+	// AmountReview flags transfers of at least min_amount
+	AmountReview ReviewRuleType = "amount"
+	// CountryReview flags transfers from or to accounts in one of the high-risk countries
+	CountryReview ReviewRuleType = "country"
+	// NewAccountReview flags transfers from or to accounts opened less than max_account_age_days ago
+	NewAccountReview ReviewRuleType = "new_account"
+)
+
+// ReviewOutcome stores allowed values for the decision of a compliance review.
+// Allowed values are "approved", "rejected"
+type ReviewOutcome string
+
+const (
+	// ReviewApproved outcome of a review releasing the transfer
+	ReviewApproved ReviewOutcome = "approved"
+	// ReviewRejected outcome of a review rejecting the transfer
+	ReviewRejected ReviewOutcome = "rejected"
+)
+
+// ReviewRule flags transfers in a corridor for compliance review
+type ReviewRule struct {
+	ID string `json:"id"`
+	Corridor
+	Type          ReviewRuleType `json:"type"`
+	MinAmount     int64          `json:"min_amount,omitempty"`           // amount rules, in minor units of the from_currency
+	Countries     []string       `json:"countries,omitempty"`            // country rules
+	MaxAccountAge int64          `json:"max_account_age_days,omitempty"` // new account rules
+}
+
+// ReviewPolicy holds the rules flagging transfers for review. All matching rules apply.
+type ReviewPolicy struct {
+	Entity
+	Rules []*ReviewRule `json:"rules"`
+}
+
+// ReviewFlag records why a transfer was held for review
+type ReviewFlag struct {
+	RuleID string `json:"rule_id"`
+	Reason string `json:"reason"`
+}
+
+// ReviewDecision records the outcome of the review of a transfer
+type ReviewDecision struct {
+	Outcome  ReviewOutcome `json:"outcome"`
+	Reason   string        `json:"reason"`
+	Reviewed int64         `json:"reviewed"` // unix time
+}
+
+// PendingReview indexes a transfer pending review
+type PendingReview struct {
+	Entity
+	TransferID string `json:"transfer_id"`
+}
+
+// CreateReviewPolicy Factory function creates a new ReviewPolicy struct and returns a pointer to it
+func CreateReviewPolicy(policyBytes []byte) (*ReviewPolicy, error) {
+	policy := new(ReviewPolicy)
+	if err := json.Unmarshal(policyBytes, policy); err != nil {
+		return nil, err
+	}
+	policy.ObjectType = ReviewPolicyObjectType
+	ids := make(map[string]bool)
+	for i, rule := range policy.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid review rule %d. Error: %s", i+1, err)
+		}
+		if ids[rule.ID] {
+			return nil, fmt.Errorf("Duplicate review rule id %s", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	return policy, nil
+}
+
+// Validate - checks that the review rule is complete and consistent
+func (r *ReviewRule) Validate() error {
+	if r.ID == "" {
+		return errors.New("Missing required id value")
+	}
+	for _, code := range []string{r.FromCurrency, r.ToCurrency} {
+		if code != "" && !currency.IsValid(code) {
+			return fmt.Errorf("Invalid currency code %s", code)
+		}
+	}
+	switch r.Type {
+	case AmountReview:
+		if r.MinAmount <= 0 {
+			return fmt.Errorf("Invalid min_amount %d", r.MinAmount)
+		}
+		if r.FromCurrency == "" {
+			return errors.New("A min_amount requires a from_currency value")
+		}
+	case CountryReview:
+		if len(r.Countries) == 0 {
+			return errors.New("Missing required countries value")
+		}
+	case NewAccountReview:
+		if r.MaxAccountAge <= 0 {
+			return fmt.Errorf("Invalid max_account_age_days %d", r.MaxAccountAge)
+		}
+	default:
+		return fmt.Errorf("Invalid review rule type %s", r.Type)
+	}
+	return nil
+}
+
+// Flag returns the flag raised by the rule for a transfer of the given amount between
+// two accounts at the given time, or nil if the transfer does not need review
+func (r *ReviewRule) Flag(amount int64, now time.Time, from *Account, to *Account) *ReviewFlag {
+	switch r.Type {
+	case AmountReview:
+		if amount >= r.MinAmount {
+			return &ReviewFlag{r.ID, fmt.Sprintf("Amount %s reaches the review threshold of %s",
+				currency.Format(amount, r.FromCurrency), currency.Format(r.MinAmount, r.FromCurrency))}
+		}
+	case CountryReview:
+		for _, a := range []*Account{from, to} {
+			for _, country := range r.Countries {
+				if strings.EqualFold(a.CountryCode, country) {
+					return &ReviewFlag{r.ID, fmt.Sprintf("Account %s is in high-risk country %s", a.ID, a.CountryCode)}
+				}
+			}
+		}
+	case NewAccountReview:
+		for _, a := range []*Account{from, to} {
+			if age := now.Unix() - a.Created; age < r.MaxAccountAge*24*60*60 {
+				return &ReviewFlag{r.ID, fmt.Sprintf("Account %s was opened less than %d days ago", a.ID, r.MaxAccountAge)}
+			}
+		}
+	}
+	return nil
+}
+
+// Approve records the approval of a transfer pending review, which may then be executed
+func (t *Transfer) Approve(reason string, now time.Time) error {
+	return t.decide(ReviewApproved, reason, now)
+}
+
+// Reject records the rejection of a transfer pending review
+func (t *Transfer) Reject(reason string, now time.Time) error {
+	return t.decide(ReviewRejected, reason, now)
+}
+
+func (t *Transfer) decide(outcome ReviewOutcome, reason string, now time.Time) error {
+	if t.Status != TransferPendingReview {
+		return fmt.Errorf("Transfer %s is not pending review", t.ID)
+	}
+	if reason == "" {
+		return errors.New("Missing required review reason")
+	}
+	t.Review = &ReviewDecision{Outcome: outcome, Reason: reason, Reviewed: now.Unix()}
+	t.Status = TransferPending
+	return nil
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReviewSuite struct {
+	suite.Suite
+}
+
+func (suite *ReviewSuite) TestCreateReviewPolicyValidation() {
+	_, err := CreateReviewPolicy([]byte(`{"rules":[{"type":"amount","from_currency":"AUD","min_amount":100}]}`))
+	suite.Equal("Invalid review rule 1. Error: Missing required id value", err.Error())
+	_, err = CreateReviewPolicy([]byte(`{"rules":[{"id":"a","type":"amount","min_amount":100}]}`))
+	suite.Equal("Invalid review rule 1. Error: A min_amount requires a from_currency value", err.Error())
+	_, err = CreateReviewPolicy([]byte(`{"rules":[{"id":"a","type":"country"}]}`))
+	suite.Equal("Invalid review rule 1. Error: Missing required countries value", err.Error())
+	_, err = CreateReviewPolicy([]byte(`{"rules":[{"id":"a","type":"new_account"}]}`))
+	suite.Equal("Invalid review rule 1. Error: Invalid max_account_age_days 0", err.Error())
+	_, err = CreateReviewPolicy([]byte(`{"rules":[{"id":"a","type":"pep"}]}`))
+	suite.Equal("Invalid review rule 1. Error: Invalid review rule type pep", err.Error())
+	_, err = CreateReviewPolicy([]byte(`{"rules":[{"id":"a","type":"country","countries":["IR"]},{"id":"a","type":"country","countries":["KP"]}]}`))
+	suite.Equal("Duplicate review rule id a", err.Error())
+}
+
+func (suite *ReviewSuite) TestFlag() {
+	now := time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC)
+	from := &Account{ID: "1234", CountryCode: "AU", Created: now.AddDate(0, -1, 0).Unix()}
+	to := &Account{ID: "5678", CountryCode: "IR", Created: now.AddDate(0, 0, -2).Unix()}
+
+	amount := &ReviewRule{ID: "big", Type: AmountReview, Corridor: Corridor{FromCurrency: "AUD"}, MinAmount: 1000000}
+	suite.Nil(amount.Flag(999999, now, from, to))
+	suite.Equal(&ReviewFlag{"big", "Amount 10000.00 AUD reaches the review threshold of 10000.00 AUD"}, amount.Flag(1000000, now, from, to))
+
+	country := &ReviewRule{ID: "risk", Type: CountryReview, Countries: []string{"KP", "ir"}}
+	suite.Equal(&ReviewFlag{"risk", "Account 5678 is in high-risk country IR"}, country.Flag(100, now, from, to))
+	suite.Nil(country.Flag(100, now, from, from))
+
+	newAccount := &ReviewRule{ID: "new", Type: NewAccountReview, MaxAccountAge: 7}
+	suite.Equal(&ReviewFlag{"new", "Account 5678 was opened less than 7 days ago"}, newAccount.Flag(100, now, from, to))
+	suite.Nil(newAccount.Flag(100, now, from, from))
+}
+
+func (suite *ReviewSuite) TestDecide() {
+	now := time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC)
+	t := &Transfer{ID: "t1", Status: TransferCompleted}
+	suite.Equal("Transfer t1 is not pending review", t.Approve("ok", now).Error())
+	t.HoldForReview("h1")
+	suite.Equal(TransferPendingReview, t.Status)
+	suite.Equal("Missing required review reason", t.Reject("", now).Error())
+	suite.Nil(t.Reject("Sanctioned beneficiary", now))
+	suite.Equal(TransferPending, t.Status)
+	suite.Equal(&ReviewDecision{ReviewRejected, "Sanctioned beneficiary", now.Unix()}, t.Review)
+}
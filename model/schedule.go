@@ -0,0 +1,248 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ScheduledTransferObjectType blockchain object type
+const ScheduledTransferObjectType = "ScheduledTransfer"
+
+// ScheduledTransferDueObjectType blockchain object type of the index of active scheduled transfers by due time
+const ScheduledTransferDueObjectType = "ScheduledTransferDue"
+
+const (
+	// MaxScheduledRuns number of the most recent runs kept on a scheduled transfer
+	MaxScheduledRuns = 10
+	// MaxCatchUpRuns number of due transfers of a scheduled transfer made in one invocation,
+	// later ones are made by the following invocations
+	MaxCatchUpRuns = 5
+)
+
+// Frequency stores allowed values for the frequency of a scheduled transfer.
+// Allowed values are "once", "daily", "weekly", "monthly"
+type Frequency string
+
+// ScheduleStatus stores allowed values for a scheduled transfer's status.
+// Allowed values are "active", "completed", "cancelled"
+type ScheduleStatus string
+
+const (
+	// Once transfer executed once on its start date
+	Once Frequency = "once"
+	// Daily transfer executed every day
+	Daily Frequency = "daily"
+	// Weekly transfer executed every week
+	Weekly Frequency = "weekly"
+	// Monthly transfer executed every month on the day of month of its start date,
+	// or the last day of shorter months
+	Monthly Frequency = "monthly"
+	// ScheduleActive status of a scheduled transfer with transfers to come
+	ScheduleActive ScheduleStatus = "active"
+	// ScheduleCompleted status of a scheduled transfer after its last transfer
+	ScheduleCompleted ScheduleStatus = "completed"
+	// ScheduleCancelled status of a cancelled scheduled transfer
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// ScheduledTransfer is a standing instruction to make a future dated or recurring transfer
+type ScheduledTransfer struct {
+	Entity
+	ID        string         `json:"id"`
+	Transfer  Transfer       `json:"transfer"`
+	Frequency Frequency      `json:"frequency"`
+	Start     int64          `json:"start"`         // unix time of the first transfer
+	End       int64          `json:"end,omitempty"` // unix time after which no transfers are made
+	Count     int            `json:"count,omitempty"`
+	Status      ScheduleStatus `json:"status"`
+	Occurrences int            `json:"occurrences"`    // number of transfers made so far
+	Runs        []ScheduledRun `json:"runs,omitempty"` // outcomes of the most recent transfers
+	CreatedBy string         `json:"created_by,omitempty"` // customer who scheduled the transfer, empty for bank staff
+	Created   int64          `json:"created"`              // unix time
+}
+
+// ScheduledTransferDue indexes an active scheduled transfer under the due time of its next transfer
+type ScheduledTransferDue struct {
+	Entity
+	Due        int64  `json:"due"` // unix time
+	CustomerID string `json:"customer_id"`
+	ScheduleID string `json:"schedule_id"`
+}
+
+// ScheduledRun records the outcome of a transfer made for a scheduled transfer
+type ScheduledRun struct {
+	ScheduleID string         `json:"schedule_id"`
+	Due        int64          `json:"due"` // unix time
+	TransferID string         `json:"transfer_id,omitempty"`
+	Status     TransferStatus `json:"status"`
+	Message    string         `json:"message,omitempty"`
+}
+
+// ScheduledTransferList stores a list of scheduled transfers
+type ScheduledTransferList struct {
+	ScheduledTransfers []*ScheduledTransfer `json:"scheduled_transfers"`
+}
+
+// ScheduledRunList stores a list of scheduled transfer outcomes
+type ScheduledRunList struct {
+	Runs []*ScheduledRun `json:"runs"`
+}
+
+// UnmarshalJSON custom unmarshalling handles time conversion
+func (s *ScheduledTransfer) UnmarshalJSON(data []byte) error {
+	type ScheduledTransferData ScheduledTransfer
+	wrapper := &struct {
+		Start   string `json:"start"`
+		End     string `json:"end"`
+		Created string `json:"created"`
+		*ScheduledTransferData
+	}{
+		ScheduledTransferData: (*ScheduledTransferData)(s),
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return err
+	}
+	var err error
+	if s.Start, err = parseTime(wrapper.Start); err != nil {
+		return err
+	}
+	if s.End, err = parseTime(wrapper.End); err != nil {
+		return err
+	}
+	if s.Created, err = parseTime(wrapper.Created); err != nil {
+		return err
+	}
+	// scheduled transfers stored before runs were truncated kept all their runs
+	if s.Occurrences < len(s.Runs) {
+		s.Occurrences = len(s.Runs)
+	}
+	return nil
+}
+
+// MarshalJSON custom marshalling handles time conversion
+func (s *ScheduledTransfer) MarshalJSON() ([]byte, error) {
+	type ScheduledTransferData ScheduledTransfer
+	wrapper := &struct {
+		Start   string `json:"start"`
+		End     string `json:"end,omitempty"`
+		Created string `json:"created"`
+		*ScheduledTransferData
+	}{
+		Start:                 time.Unix(s.Start, 0).Format(time.RFC3339),
+		Created:               time.Unix(s.Created, 0).Format(time.RFC3339),
+		ScheduledTransferData: (*ScheduledTransferData)(s),
+	}
+	if s.End != 0 {
+		wrapper.End = time.Unix(s.End, 0).Format(time.RFC3339)
+	}
+	return json.Marshal(wrapper)
+}
+
+// CreateScheduledTransfer a factory function for creating new ScheduledTransfer entities
+func CreateScheduledTransfer(src Source, scheduleBytes []byte) (*ScheduledTransfer, error) {
+	s := new(ScheduledTransfer)
+	if err := json.Unmarshal(scheduleBytes, s); err != nil {
+		return nil, err
+	}
+	if err := s.Transfer.Validate(); err != nil {
+		return nil, err
+	}
+	if s.Frequency == "" {
+		s.Frequency = Once
+	}
+	switch s.Frequency {
+	case Once, Daily, Weekly, Monthly:
+	default:
+		return nil, fmt.Errorf("Invalid frequency %s", s.Frequency)
+	}
+	now := src.Now().Unix()
+	if s.Start == 0 {
+		return nil, errors.New("Missing required start value")
+	}
+	if s.Start < now {
+		return nil, errors.New("Schedule start must not be in the past")
+	}
+	if s.End != 0 && s.End < s.Start {
+		return nil, errors.New("Schedule end must not be before its start")
+	}
+	if s.Count < 0 {
+		return nil, fmt.Errorf("Invalid count %d", s.Count)
+	}
+	s.Entity = Entity{ScheduledTransferObjectType}
+	s.ID = src.NextID()
+	s.Status = ScheduleActive
+	s.Occurrences = 0
+	s.Runs = nil
+	s.CreatedBy = ""
+	s.Created = now
+	return s, nil
+}
+
+// CustomerID returns the customer paying the scheduled transfers
+func (s *ScheduledTransfer) CustomerID() string {
+	return s.Transfer.FromCustomerID
+}
+
+// occurrence returns the due time of the nth (zero based) transfer
+func (s *ScheduledTransfer) occurrence(n int) time.Time {
+	start := time.Unix(s.Start, 0).UTC()
+	switch s.Frequency {
+	case Daily:
+		return start.AddDate(0, 0, n)
+	case Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case Monthly:
+		// clamp to the last day of shorter months instead of overflowing into the next month
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > last {
+			day = last
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return start
+}
+
+// NextDue returns the due time of the next transfer, and false if there are no more transfers
+func (s *ScheduledTransfer) NextDue() (time.Time, bool) {
+	n := s.Occurrences
+	if s.Status != ScheduleActive || (s.Frequency == Once && n > 0) || (s.Count > 0 && n >= s.Count) {
+		return time.Time{}, false
+	}
+	due := s.occurrence(n)
+	if s.End != 0 && due.Unix() > s.End {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+// IsDue returns whether the next transfer is due at the given time
+func (s *ScheduledTransfer) IsDue(now time.Time) bool {
+	due, ok := s.NextDue()
+	return ok && !due.After(now)
+}
+
+// AddRun records the outcome of the next transfer, keeping the most recent runs only, and
+// completes the schedule after its last transfer
+func (s *ScheduledTransfer) AddRun(run *ScheduledRun) {
+	s.Occurrences++
+	s.Runs = append(s.Runs, *run)
+	if len(s.Runs) > MaxScheduledRuns {
+		s.Runs = append([]ScheduledRun(nil), s.Runs[len(s.Runs)-MaxScheduledRuns:]...)
+	}
+	if _, ok := s.NextDue(); !ok {
+		s.Status = ScheduleCompleted
+	}
+}
+
+// Cancel cancels an active scheduled transfer
+func (s *ScheduledTransfer) Cancel() error {
+	if s.Status != ScheduleActive {
+		return fmt.Errorf("Cannot cancel scheduled transfer %s with status %s", s.ID, s.Status)
+	}
+	s.Status = ScheduleCancelled
+	return nil
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const testInstruction = `"transfer":{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":100}`
+
+type ScheduleSuite struct {
+	suite.Suite
+	src Source
+}
+
+func (suite *ScheduleSuite) SetupTest() {
+	suite.src = NewSequenceSource("tx1", time.Date(2017, 8, 15, 12, 0, 0, 0, time.UTC))
+}
+
+func (suite *ScheduleSuite) create(schedule string) *ScheduledTransfer {
+	s, err := CreateScheduledTransfer(suite.src, []byte(`{`+testInstruction+`,`+schedule+`}`))
+	suite.Nil(err)
+	return s
+}
+
+func (suite *ScheduleSuite) TestCreateScheduledTransfer() {
+	s := suite.create(`"start":"2017-08-20T00:00:00Z","status":"cancelled"`)
+	suite.Equal(ScheduledTransferObjectType, s.GetObjectType())
+	suite.NotEmpty(s.ID)
+	suite.Equal(Once, s.Frequency)
+	suite.Equal(ScheduleActive, s.Status)
+	suite.Equal("1", s.CustomerID())
+}
+
+func (suite *ScheduleSuite) TestCreateScheduledTransferValidation() {
+	_, err := CreateScheduledTransfer(suite.src, []byte(`{"start":"2017-08-20T00:00:00Z"}`))
+	suite.Equal("Missing required from_customer value", err.Error())
+	_, err = CreateScheduledTransfer(suite.src, []byte(`{`+testInstruction+`}`))
+	suite.Equal("Missing required start value", err.Error())
+	_, err = CreateScheduledTransfer(suite.src, []byte(`{`+testInstruction+`,"start":"2017-08-14T00:00:00Z"}`))
+	suite.Equal("Schedule start must not be in the past", err.Error())
+	_, err = CreateScheduledTransfer(suite.src, []byte(`{`+testInstruction+`,"start":"2017-08-20T00:00:00Z","end":"2017-08-19T00:00:00Z"}`))
+	suite.Equal("Schedule end must not be before its start", err.Error())
+	_, err = CreateScheduledTransfer(suite.src, []byte(`{`+testInstruction+`,"start":"2017-08-20T00:00:00Z","frequency":"hourly"}`))
+	suite.Equal("Invalid frequency hourly", err.Error())
+}
+
+func (suite *ScheduleSuite) TestOnce() {
+	s := suite.create(`"start":"2017-08-20T00:00:00Z"`)
+	suite.False(s.IsDue(time.Date(2017, 8, 19, 23, 59, 59, 0, time.UTC)))
+	suite.True(s.IsDue(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC)))
+	s.AddRun(&ScheduledRun{Status: TransferCompleted})
+	suite.Equal(ScheduleCompleted, s.Status)
+	suite.False(s.IsDue(time.Date(2017, 9, 20, 0, 0, 0, 0, time.UTC)))
+}
+
+func (suite *ScheduleSuite) TestWeeklyWithCount() {
+	s := suite.create(`"start":"2017-08-20T00:00:00Z","frequency":"weekly","count":2`)
+	due, _ := s.NextDue()
+	suite.Equal(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC), due)
+	s.AddRun(&ScheduledRun{Status: TransferCompleted})
+	due, _ = s.NextDue()
+	suite.Equal(time.Date(2017, 8, 27, 0, 0, 0, 0, time.UTC), due)
+	s.AddRun(&ScheduledRun{Status: TransferFailed})
+	suite.Equal(ScheduleCompleted, s.Status)
+}
+
+func (suite *ScheduleSuite) TestDailyWithEnd() {
+	s := suite.create(`"start":"2017-08-20T00:00:00Z","frequency":"daily","end":"2017-08-21T12:00:00Z"`)
+	s.AddRun(&ScheduledRun{Status: TransferCompleted})
+	suite.Equal(ScheduleActive, s.Status)
+	s.AddRun(&ScheduledRun{Status: TransferCompleted})
+	suite.Equal(ScheduleCompleted, s.Status)
+}
+
+func (suite *ScheduleSuite) TestMonthlyClampsToEndOfMonth() {
+	s := suite.create(`"start":"2018-01-31T09:00:00Z","frequency":"monthly"`)
+	var dues []time.Time
+	for i := 0; i < 4; i++ {
+		due, _ := s.NextDue()
+		dues = append(dues, due)
+		s.AddRun(&ScheduledRun{Status: TransferCompleted})
+	}
+	suite.Equal([]time.Time{
+		time.Date(2018, 1, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2018, 2, 28, 9, 0, 0, 0, time.UTC),
+		time.Date(2018, 3, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2018, 4, 30, 9, 0, 0, 0, time.UTC),
+	}, dues)
+}
+
+func (suite *ScheduleSuite) TestKeepsRecentRuns() {
+	s := suite.create(`"start":"2017-08-20T00:00:00Z","frequency":"daily"`)
+	for i := 0; i < MaxScheduledRuns+5; i++ {
+		due, _ := s.NextDue()
+		s.AddRun(&ScheduledRun{Due: due.Unix(), Status: TransferCompleted})
+	}
+	suite.Equal(MaxScheduledRuns+5, s.Occurrences)
+	suite.Len(s.Runs, MaxScheduledRuns)
+	suite.Equal(time.Date(2017, 8, 25, 0, 0, 0, 0, time.UTC).Unix(), s.Runs[0].Due)
+	due, _ := s.NextDue()
+	suite.Equal(time.Date(2017, 9, 4, 0, 0, 0, 0, time.UTC), due)
+
+	// scheduled transfers stored without an occurrence count count their runs
+	legacy := new(ScheduledTransfer)
+	suite.Nil(json.Unmarshal([]byte(`{`+testInstruction+`,"start":"2017-08-20T00:00:00Z","created":"2017-08-15T00:00:00Z","frequency":"daily","status":"active","runs":[{"status":"completed"},{"status":"failed"}]}`), legacy))
+	suite.Equal(2, legacy.Occurrences)
+}
+
+func (suite *ScheduleSuite) TestCancel() {
+	s := suite.create(`"start":"2017-08-20T00:00:00Z","frequency":"daily"`)
+	suite.Nil(s.Cancel())
+	suite.False(s.IsDue(time.Date(2017, 8, 21, 0, 0, 0, 0, time.UTC)))
+	suite.Equal("Cannot cancel scheduled transfer "+s.ID+" with status cancelled", s.Cancel().Error())
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"crypto/md5"
+	"fmt"
+	"time"
+)
+
+// Source supplies the timestamps and identifiers of new entities. Chaincode
+// derives both from the transaction proposal, so that all endorsing peers
+// create identical entities for the same proposal.
+type Source interface {
+	// Now returns the time new entities are created at
+	Now() time.Time
+	// NextID returns a new identifier, unique within the source
+	NextID() string
+}
+
+// SequenceSource derives identifiers from a seed (e.g. a transaction ID) and a
+// counter, so the same seed always yields the same sequence of identifiers
+type SequenceSource struct {
+	seed    string
+	now     time.Time
+	counter int
+}
+
+// NewSequenceSource creates a new source for the given seed and time
+func NewSequenceSource(seed string, now time.Time) *SequenceSource {
+	return &SequenceSource{seed: seed, now: now}
+}
+
+// Now returns the time of the source
+func (s *SequenceSource) Now() time.Time {
+	return s.now
+}
+
+// NextID returns the next identifier in the sequence as a 32 character hex string
+func (s *SequenceSource) NextID() string {
+	s.counter++
+	return fmt.Sprintf("%x", newID([]byte(fmt.Sprintf("%s/%d", s.seed, s.counter))))
+}
+
+func newID(data []byte) []byte {
+	md5 := md5.New()
+	md5.Write(data)
+	return md5.Sum(nil)
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SourceSuite struct {
+	suite.Suite
+}
+
+func (suite *SourceSuite) TestNow() {
+	now := time.Date(2017, 8, 15, 0, 0, 0, 0, time.UTC)
+	suite.Equal(now, NewSequenceSource("t1", now).Now())
+}
+
+func (suite *SourceSuite) TestNextIDIsUnique() {
+	src := NewSequenceSource("t1", time.Time{})
+	id := src.NextID()
+	suite.Equal(32, len(id))
+	suite.NotEqual(id, src.NextID())
+}
+
+func (suite *SourceSuite) TestNextIDIsDeterministic() {
+	src1 := NewSequenceSource("t1", time.Time{})
+	src2 := NewSequenceSource("t1", time.Time{})
+	suite.Equal(src1.NextID(), src2.NextID())
+	suite.Equal(src1.NextID(), src2.NextID())
+}
+
+func (suite *SourceSuite) TestNextIDDependsOnSeed() {
+	suite.NotEqual(NewSequenceSource("t1", time.Time{}).NextID(), NewSequenceSource("t2", time.Time{}).NextID())
+}
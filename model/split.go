@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Payee struct contains one of the payees of a split transfer. A payee receives
+// either a fixed amount or a percentage of the amount not allocated to fixed amounts.
+type Payee struct {
+	CustomerID string   `json:"customer"`
+	AccountID  string   `json:"account"`
+	Amount     int64    `json:"amount,omitempty"`     // fixed amount in minor units of the transfer currency
+	Percentage *Decimal `json:"percentage,omitempty"` // percentage of the amount remaining after fixed amounts
+	Share      int64    `json:"share,omitempty"`      // allocated amount in minor units of the transfer currency
+}
+
+// IsSplit returns true if the transfer is split between several payees
+func (t *Transfer) IsSplit() bool {
+	return len(t.Payees) > 0
+}
+
+// validatePayees checks the payees of a split transfer
+func (t *Transfer) validatePayees() error {
+	if t.ToCustomerID != "" || t.ToAccountID != "" {
+		return errors.New("A split transfer cannot have to_customer or to_account values")
+	}
+	if t.Escrow != nil {
+		return errors.New("A split transfer cannot be escrowed")
+	}
+	seen := make(map[string]bool)
+	for i, p := range t.Payees {
+		if p.CustomerID == "" {
+			return fmt.Errorf("Missing required customer value of payee %d", i)
+		}
+		if p.AccountID == "" {
+			return fmt.Errorf("Missing required account value of payee %d", i)
+		}
+		if p.CustomerID == t.FromCustomerID && p.AccountID == t.FromAccountID {
+			return errors.New("Cannot transfer money into the same account")
+		}
+		key := p.CustomerID + "/" + p.AccountID
+		if seen[key] {
+			return fmt.Errorf("Duplicate payee account %s", p.AccountID)
+		}
+		seen[key] = true
+		if (p.Amount != 0) == (p.Percentage != nil) {
+			return fmt.Errorf("Payee %d must have either an amount or a percentage", i)
+		}
+		if p.Amount < 0 {
+			return fmt.Errorf("Invalid amount %d of payee %d", p.Amount, i)
+		}
+		if p.Percentage != nil && p.Percentage.Sign() <= 0 {
+			return fmt.Errorf("Invalid percentage %s of payee %d", p.Percentage, i)
+		}
+	}
+	_, err := t.allocate()
+	return err
+}
+
+// Split allocates the transfer amount to the payees, setting the share of each payee.
+// Fixed amounts are allocated first, the rest is divided by percentage. Minor units
+// left over after rounding down go to the payees with the largest fractional parts,
+// ties are broken in payee order, so the shares always add up to the transfer amount.
+func (t *Transfer) Split() error {
+	shares, err := t.allocate()
+	if err != nil {
+		return err
+	}
+	for i := range t.Payees {
+		t.Payees[i].Share = shares[i]
+	}
+	return nil
+}
+
+func (t *Transfer) allocate() ([]int64, error) {
+	shares := make([]int64, len(t.Payees))
+	remaining := t.Amount
+	total := new(big.Rat)
+	for i, p := range t.Payees {
+		if p.Percentage == nil {
+			shares[i] = p.Amount
+			remaining -= p.Amount
+		} else {
+			total.Add(total, p.Percentage.Rat())
+		}
+	}
+	if remaining < 0 {
+		return nil, fmt.Errorf("Payee amounts exceed the transfer amount %d", t.Amount)
+	}
+	if total.Sign() == 0 {
+		if remaining != 0 {
+			return nil, fmt.Errorf("Payee amounts do not add up to the transfer amount %d", t.Amount)
+		}
+		return shares, nil
+	}
+	if remaining == 0 {
+		return nil, errors.New("Payee amounts leave nothing to divide by percentage")
+	}
+	if total.Cmp(big.NewRat(100, 1)) != 0 {
+		return nil, fmt.Errorf("Payee percentages add up to %s, not 100", total.FloatString(2))
+	}
+
+	var fractions []fraction
+	allocated := int64(0)
+	for i, p := range t.Payees {
+		if p.Percentage == nil {
+			continue
+		}
+		exact := new(big.Rat).Mul(big.NewRat(remaining, 100), p.Percentage.Rat())
+		floor := new(big.Int).Quo(exact.Num(), exact.Denom())
+		shares[i] = floor.Int64()
+		allocated += shares[i]
+		fractions = append(fractions, fraction{i, new(big.Rat).Sub(exact, new(big.Rat).SetInt(floor))})
+	}
+	sort.Stable(byRemainder(fractions))
+	for j := int64(0); j < remaining-allocated; j++ {
+		shares[fractions[j].index]++
+	}
+	return shares, nil
+}
+
+// CreatePayeeTransaction a factory function for the transaction crediting the share of a
+// payee of a split transfer. A conversion of the share into the payee account currency may be given.
+func CreatePayeeTransaction(src Source, p Payee, t *Transfer, c *Conversion) (*Transaction, error) {
+	txn, err := CreateTransaction(src, p.CustomerID, p.AccountID, t, c, TxFailureCodeNone, Credited)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		txn.Amount = p.Share
+	}
+	return txn, nil
+}
+
+// fraction is the fractional part of the exact share of a payee
+type fraction struct {
+	index     int
+	remainder *big.Rat
+}
+
+// byRemainder sorts fractions by descending remainder
+type byRemainder []fraction
+
+func (f byRemainder) Len() int           { return len(f) }
+func (f byRemainder) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byRemainder) Less(i, j int) bool { return f[i].remainder.Cmp(f[j].remainder) > 0 }
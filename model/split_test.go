@@ -0,0 +1,82 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SplitSuite struct {
+	suite.Suite
+}
+
+func splitTransfer(amount int64, payees string) *Transfer {
+	t := &Transfer{FromCustomerID: "1", FromAccountID: "1234", Amount: amount, CurrencyCode: "AUD"}
+	json.Unmarshal([]byte(payees), &t.Payees)
+	return t
+}
+
+func shares(t *Transfer) []int64 {
+	var s []int64
+	for _, p := range t.Payees {
+		s = append(s, p.Share)
+	}
+	return s
+}
+
+func (suite *SplitSuite) TestSplitAmounts() {
+	t := splitTransfer(1000, `[{"customer":"2","account":"5678","amount":700},{"customer":"3","account":"9012","amount":300}]`)
+	suite.Nil(t.Validate())
+	suite.Nil(t.Split())
+	suite.Equal([]int64{700, 300}, shares(t))
+}
+
+func (suite *SplitSuite) TestSplitPercentages() {
+	// 1001 * 45% = 450.45, 1001 * 45% = 450.45, 1001 * 10% = 100.1: one cent left over
+	// goes to the first of the payees with the largest fraction
+	t := splitTransfer(1001, `[{"customer":"2","account":"5678","percentage":45},{"customer":"3","account":"9012","percentage":45},{"customer":"4","account":"3456","percentage":10}]`)
+	suite.Nil(t.Split())
+	suite.Equal([]int64{451, 450, 100}, shares(t))
+
+	// 100 / 3: the largest remainders win regardless of order
+	t = splitTransfer(100, `[{"customer":"2","account":"5678","percentage":33.33},{"customer":"3","account":"9012","percentage":33.34},{"customer":"4","account":"3456","percentage":33.33}]`)
+	suite.Nil(t.Split())
+	suite.Equal([]int64{33, 34, 33}, shares(t))
+}
+
+func (suite *SplitSuite) TestSplitMixed() {
+	// the fixed fee of 100 is taken first, the remaining 900 is divided 90/10
+	t := splitTransfer(1000, `[{"customer":"2","account":"5678","percentage":90},{"customer":"3","account":"9012","amount":100},{"customer":"4","account":"3456","percentage":10}]`)
+	suite.Nil(t.Split())
+	suite.Equal([]int64{810, 100, 90}, shares(t))
+}
+
+func (suite *SplitSuite) TestSplitValidation() {
+	t := splitTransfer(1000, `[{"customer":"2","account":"5678","amount":700},{"customer":"3","account":"9012","amount":200}]`)
+	suite.Equal("Payee amounts do not add up to the transfer amount 1000", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","account":"5678","amount":1100},{"customer":"3","account":"9012","percentage":100}]`)
+	suite.Equal("Payee amounts exceed the transfer amount 1000", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","account":"5678","amount":1000},{"customer":"3","account":"9012","percentage":100}]`)
+	suite.Equal("Payee amounts leave nothing to divide by percentage", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","account":"5678","percentage":60},{"customer":"3","account":"9012","percentage":30}]`)
+	suite.Equal("Payee percentages add up to 90.00, not 100", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","account":"5678","amount":500,"percentage":50},{"customer":"3","account":"9012","amount":500}]`)
+	suite.Equal("Payee 0 must have either an amount or a percentage", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","account":"5678","amount":500},{"customer":"2","account":"5678","amount":500}]`)
+	suite.Equal("Duplicate payee account 5678", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"1","account":"1234","amount":500},{"customer":"2","account":"5678","amount":500}]`)
+	suite.Equal("Cannot transfer money into the same account", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","amount":1000}]`)
+	suite.Equal("Missing required account value of payee 0", t.Validate().Error())
+	t = splitTransfer(1000, `[{"customer":"2","account":"5678","amount":1000}]`)
+	t.ToCustomerID = "2"
+	suite.Equal("A split transfer cannot have to_customer or to_account values", t.Validate().Error())
+}
+
+func (suite *SplitSuite) TestHashIgnoresShares() {
+	t1 := splitTransfer(1000, `[{"customer":"2","account":"5678","percentage":50},{"customer":"3","account":"9012","percentage":50}]`)
+	t2 := splitTransfer(1000, `[{"customer":"2","account":"5678","percentage":50},{"customer":"3","account":"9012","percentage":50}]`)
+	t2.Split()
+	suite.Equal(t1.Hash(), t2.Hash())
+	suite.Equal(int64(0), t1.Payees[0].Share)
+}
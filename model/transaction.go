@@ -1,9 +1,7 @@
 package model
 
 import (
-	"crypto/md5"
 	"encoding/json"
-	"fmt"
 	"time"
 )
 
@@ -14,20 +12,23 @@ const TransactionObjectType = "Transaction"
 type TxDetails struct {
 	CustomerID   string            `json:"customer_id"`
 	AccountID    string            `json:"account_id"`
-	Amount       int64             `json:"amount"` // amount in cents
+	TransferID   string            `json:"transfer_id,omitempty"`
+	Amount       int64             `json:"amount"` // amount in minor units of the currency
 	Fee          int64             `json:"fee"`
 	CurrencyCode string            `json:"currency"`
 	Created      int64             `json:"created"` // unix time
 	Description  string            `json:"description"`
+	Conversion   *Conversion       `json:"conversion,omitempty"` // currency conversion applied, if any
 	Params       map[string]string `json:"params,omitempty"`
 }
 
 // TxFailureCode stores allowed values for transaction failures
-// Allowed values are "insufficient_funds", "account_closed"
+// Allowed values are "insufficient_funds", "overdraft_exceeded", "limit_exceeded", "sanctions_hit", "review_rejected", "account_closed", "rates_unavailable", "invalid_transfer"
 type TxFailureCode string
 
 // TxStatus stores allowed values for a transaction's status.
-// Allowed values are "debited", "credited", "failed"
+// Allowed values are "debited", "credited", "fee_collected", "failed", "escrow_deposited",
+// "escrow_released", "escrow_refunded", "refunded"
 type TxStatus string
 
 const (
@@ -35,14 +36,40 @@ const (
 	TxFailureCodeNone TxFailureCode = ""
 	// InsufficientFunds transaction failure code
 	InsufficientFunds TxFailureCode = "insufficient_funds"
+	// OverdraftExceeded failure code of a debit from an account with an overdraft facility beyond its limit
+	OverdraftExceeded TxFailureCode = "overdraft_exceeded"
+	// LimitExceeded failure code of a transfer breaching a transfer limit
+	LimitExceeded TxFailureCode = "limit_exceeded"
+	// SanctionsHit failure code of a transfer blocked because a party matched the watch-list
+	SanctionsHit TxFailureCode = "sanctions_hit"
+	// RejectedInReview failure code of a transfer rejected in compliance review
+	RejectedInReview TxFailureCode = "review_rejected"
+	// CustomerIneligible failure code of a transfer by or to a customer that is not active or not KYC verified
+	CustomerIneligible TxFailureCode = "customer_ineligible"
+	// ApprovalExpired failure code of a transfer not approved by the required number of approvers before its deadline
+	ApprovalExpired TxFailureCode = "approval_expired"
 	// AccountClosed transaction faiure code
 	AccountClosed TxFailureCode = "account_closed"
+	// RatesUnavailable transaction failure code
+	RatesUnavailable TxFailureCode = "rates_unavailable"
+	// InvalidTransfer failure code of a transfer rejected for other reasons, e.g. missing details or unknown accounts
+	InvalidTransfer TxFailureCode = "invalid_transfer"
 	// Debited transaction status
 	Debited TxStatus = "debited"
 	// Credited transaction status
 	Credited TxStatus = "credited"
+	// FeeCollected transaction status of a fee credited to the fee collection account
+	FeeCollected TxStatus = "fee_collected"
 	// Failed transaction status
 	Failed TxStatus = "failed"
+	// EscrowDeposited transaction status of escrowed funds credited to the escrow account
+	EscrowDeposited TxStatus = "escrow_deposited"
+	// EscrowReleased transaction status of escrowed funds debited from the escrow account to the payee
+	EscrowReleased TxStatus = "escrow_released"
+	// EscrowRefunded transaction status of escrowed funds debited from the escrow account to the payer
+	EscrowRefunded TxStatus = "escrow_refunded"
+	// Refunded transaction status of escrowed funds credited back to the payer
+	Refunded TxStatus = "refunded"
 )
 
 // Transaction data struct represents a money transfer (payer and payee sides)
@@ -86,28 +113,44 @@ func (t *Transaction) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// CreateTransaction a factory function for creating new Transaction entities
-func CreateTransaction(customerID string, accountID string, t *Transfer, code TxFailureCode, status TxStatus) (*Transaction, error) {
-	txn := &Transaction{Entity: Entity{TransactionObjectType}, FailureCode: code, Status: status}
+// CreateTransaction a factory function for creating new Transaction entities.
+// Credited transactions of a converted transfer are recorded in the destination currency.
+func CreateTransaction(src Source, customerID string, accountID string, t *Transfer, c *Conversion, code TxFailureCode, status TxStatus) (*Transaction, error) {
+	txn := &Transaction{Entity: Entity{TransactionObjectType}, ID: src.NextID(), FailureCode: code, Status: status}
 	txn.TxDetails = TxDetails{
 		CustomerID:   customerID,
 		AccountID:    accountID,
-		Created:      time.Now().Unix(),
+		TransferID:   t.ID,
+		Created:      src.Now().Unix(),
 		Amount:       t.Amount,
 		Fee:          t.Fee,
 		CurrencyCode: t.CurrencyCode,
 		Description:  t.Description,
+		Conversion:   c,
 		Params:       t.Params,
 	}
-	transferData, _ := json.Marshal(txn)
-	txn.ID = fmt.Sprintf("%x", newID(transferData))
+	if c != nil && status == Credited {
+		txn.Amount = c.DestinationAmount
+		txn.CurrencyCode = c.DestinationCurrency
+	}
 	return txn, nil
 }
 
-func newID(data []byte) []byte {
-	md5 := md5.New()
-	md5.Write(data)
-	return md5.Sum(nil)
+// CreateFeeTransaction a factory function for the transaction crediting the fee of a transfer
+// to the fee collection account. A conversion of the fee into the collection account currency
+// may be given.
+func CreateFeeTransaction(src Source, customerID string, accountID string, t *Transfer, c *Conversion) (*Transaction, error) {
+	txn, err := CreateTransaction(src, customerID, accountID, t, c, TxFailureCodeNone, FeeCollected)
+	if err != nil {
+		return nil, err
+	}
+	txn.Amount = t.Fee
+	txn.Fee = 0
+	if c != nil {
+		txn.Amount = c.DestinationAmount
+		txn.CurrencyCode = c.DestinationCurrency
+	}
+	return txn, nil
 }
 
 // TransactionList stores a list of transactions
@@ -43,9 +43,32 @@ func (suite *TransactionSuite) TestMarshalTransactionCreated() {
 }
 
 func (suite *TransactionSuite) TestCreateTransaction() {
-	tPtr := &Transfer{"1", "1234", "2", "5678", 100, 0, "AUD", "", map[string]string(nil)}
-	txn, _ := CreateTransaction("1", "1234", tPtr, "", Credited)
+	tPtr := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
+	now := time.Date(2017, 8, 15, 0, 0, 0, 0, time.UTC)
+	txn, _ := CreateTransaction(NewSequenceSource("t1", now), "1", "1234", tPtr, nil, "", Credited)
 	suite.Equal(32, len(txn.ID))
+	suite.Equal(now.Unix(), txn.Created)
+}
+
+func (suite *TransactionSuite) TestCreateTransactionUniqueIDs() {
+	tPtr := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
+	src := NewSequenceSource("t1", time.Now())
+	txn1, _ := CreateTransaction(src, "1", "1234", tPtr, nil, "", Credited)
+	txn2, _ := CreateTransaction(src, "1", "1234", tPtr, nil, "", Credited)
+	suite.NotEqual(txn1.ID, txn2.ID)
+}
+
+func (suite *TransactionSuite) TestCreateTransactionWithConversion() {
+	tPtr := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 1000, CurrencyCode: "AUD"}
+	c := &Conversion{MustParseDecimal("1.0793"), "AUD", 1000, "NZD", 1079}
+	src := NewSequenceSource("t1", time.Now())
+	debit, _ := CreateTransaction(src, "1", "1234", tPtr, c, "", Debited)
+	suite.Equal(int64(1000), debit.Amount)
+	suite.Equal("AUD", debit.CurrencyCode)
+	credit, _ := CreateTransaction(src, "2", "5678", tPtr, c, "", Credited)
+	suite.Equal(int64(1079), credit.Amount)
+	suite.Equal("NZD", credit.CurrencyCode)
+	suite.Equal(c, credit.Conversion)
 }
 
 func (suite *TransactionSuite) TestTransactionListSort() {
@@ -66,3 +89,17 @@ func (suite *TransactionSuite) TestTransactionListSort() {
 
 	suite.Equal("2", transactionList.Transactions[0].ID)
 }
+
+func (suite *TransactionSuite) TestCreateFeeTransaction() {
+	tPtr := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 1000, Fee: 25, CurrencyCode: "AUD"}
+	src := NewSequenceSource("t1", time.Now())
+	txn, _ := CreateFeeTransaction(src, "bank", "fees", tPtr, nil)
+	suite.Equal(int64(25), txn.Amount)
+	suite.Equal(int64(0), txn.Fee)
+	suite.Equal("AUD", txn.CurrencyCode)
+	suite.Equal(FeeCollected, txn.Status)
+	c := &Conversion{MustParseDecimal("1.0793"), "AUD", 25, "NZD", 27}
+	txn, _ = CreateFeeTransaction(src, "bank", "fees", tPtr, c)
+	suite.Equal(int64(27), txn.Amount)
+	suite.Equal("NZD", txn.CurrencyCode)
+}
@@ -1,21 +1,138 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/currency"
+)
+
+// TransferObjectType blockchain object type
+const TransferObjectType = "Transfer"
+
+// TransferStatus stores allowed values for a transfer's status.
+// Allowed values are "pending", "pending_review", "pending_approval", "completed", "failed", "reversed", "partially_reversed", "refunded"
+type TransferStatus string
+
+const (
+	// TransferPending status of a transfer that has not been executed yet
+	TransferPending TransferStatus = "pending"
+	// TransferPendingReview status of a transfer held for compliance review
+	TransferPendingReview TransferStatus = "pending_review"
+	// TransferPendingApproval status of a transfer awaiting approval by the approvers of the payer account
+	TransferPendingApproval TransferStatus = "pending_approval"
+	// TransferCompleted status of an executed transfer
+	TransferCompleted TransferStatus = "completed"
+	// TransferFailed status of a rejected transfer
+	TransferFailed TransferStatus = "failed"
+	// TransferReversed status of a transfer that has been reversed in full
+	TransferReversed TransferStatus = "reversed"
+	// TransferPartiallyReversed status of a transfer that has been reversed in part
+	TransferPartiallyReversed TransferStatus = "partially_reversed"
+	// TransferRefunded status of an escrowed transfer refunded to the payer
+	TransferRefunded TransferStatus = "refunded"
 )
 
-// Transfer struct contains information about a money transfer
+// Transfer struct contains information about a money transfer. The transfer
+// details are supplied by the client, the ID, status and legs are maintained by the chaincode.
 type Transfer struct {
+	Entity
+	ID             string            `json:"id,omitempty"`
 	FromCustomerID string            `json:"from_customer"`
 	FromAccountID  string            `json:"from_account"`
 	ToCustomerID   string            `json:"to_customer"`
 	ToAccountID    string            `json:"to_account"`
-	Amount         int64             `json:"amount"` // amount in cents
+	Amount         int64             `json:"amount"` // amount in minor units of the currency
 	Fee            int64             `json:"fee"`
 	CurrencyCode   string            `json:"currency"`
 	Description    string            `json:"description"`
 	Params         map[string]string `json:"params,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"` // client supplied key identifying retries of the same transfer
+	InitiatedBy    string            `json:"initiated_by,omitempty"`    // customer making the transfer, empty for bank staff
+	Escrow         *Escrow           `json:"escrow,omitempty"`          // release condition of a conditional transfer
+	Payees         []Payee           `json:"payees,omitempty"`          // payees of a split transfer, instead of to_customer and to_account
+	Status         TransferStatus    `json:"status,omitempty"`
+	FailureCode    TxFailureCode     `json:"failure_code,omitempty"`
+	FailureReason  string            `json:"failure_reason,omitempty"`
+	Legs           []TransferLeg     `json:"legs,omitempty"`
+	Screening      *ScreeningHit     `json:"screening,omitempty"`         // watch-list match of a blocked or reviewed transfer
+	Flags          []ReviewFlag      `json:"review_flags,omitempty"`      // review rules flagging the transfer
+	Review         *ReviewDecision   `json:"review,omitempty"`            // outcome of the compliance review
+	Approval       *TransferApproval `json:"approval,omitempty"`          // approvals of a transfer requiring approval
+	HoldID         string            `json:"hold_id,omitempty"`           // hold reserving the funds of a transfer pending review or approval
+	Created        int64             `json:"created,omitempty"`           // unix time
+	OriginalID     string            `json:"original_transfer,omitempty"` // transfer reversed by this transfer
+	Reversed       int64             `json:"reversed,omitempty"`          // amount reversed so far in minor units of the currency
+	Reversals      []string          `json:"reversals,omitempty"`         // IDs of the reversal transfers
+}
+
+// TransferList stores a list of transfers
+type TransferList struct {
+	Transfers []*Transfer `json:"transfers"`
+}
+
+// TransferLeg links a transfer to one of its account transactions
+type TransferLeg struct {
+	TransactionID string   `json:"transaction_id"`
+	CustomerID    string   `json:"customer_id"`
+	AccountID     string   `json:"account_id"`
+	Status        TxStatus `json:"status"`
+}
+
+// Begin assigns an ID to a new transfer and marks it pending. Any ID, initiator, status or
+// legs supplied by the client are discarded.
+func (t *Transfer) Begin(src Source) {
+	t.Entity = Entity{TransferObjectType}
+	t.ID = src.NextID()
+	t.InitiatedBy = ""
+	t.Status = TransferPending
+	t.FailureCode = TxFailureCodeNone
+	t.FailureReason = ""
+	t.Legs = nil
+	t.Screening = nil
+	t.Flags = nil
+	t.Review = nil
+	t.Approval = nil
+	t.HoldID = ""
+	t.Created = src.Now().Unix()
+	t.OriginalID = ""
+	t.Reversed = 0
+	t.Reversals = nil
+}
+
+// AddLeg links a transaction to the transfer
+func (t *Transfer) AddLeg(txn *Transaction) {
+	t.Legs = append(t.Legs, TransferLeg{
+		TransactionID: txn.ID,
+		CustomerID:    txn.CustomerID,
+		AccountID:     txn.AccountID,
+		Status:        txn.Status,
+	})
+}
+
+// Complete marks the transfer completed
+func (t *Transfer) Complete() {
+	t.Status = TransferCompleted
+}
+
+// Refund marks the transfer refunded
+func (t *Transfer) Refund() {
+	t.Status = TransferRefunded
+}
+
+// HoldForReview marks the transfer pending review, with its funds reserved by the given hold
+func (t *Transfer) HoldForReview(holdID string) {
+	t.Status = TransferPendingReview
+	t.HoldID = holdID
+}
+
+// Fail marks the transfer failed with the given failure code and reason
+func (t *Transfer) Fail(code TxFailureCode, reason string) {
+	t.Status = TransferFailed
+	t.FailureCode = code
+	t.FailureReason = reason
 }
 
 // Validate - checks that required are present in the transfer object
@@ -26,18 +143,61 @@ func (t *Transfer) Validate() error {
 	if t.FromAccountID == "" {
 		return errors.New("Missing required from_account value")
 	}
-	if t.ToCustomerID == "" {
-		return errors.New("Missing required to_customer value")
-	}
-	if t.ToAccountID == "" {
-		return errors.New("Missing required to_account value")
-	}
 	if t.Amount <= 0 {
 		return fmt.Errorf("Invalid transfer amount %d", t.Amount)
 	}
+	if t.IsSplit() {
+		if err := t.validatePayees(); err != nil {
+			return err
+		}
+	} else {
+		if t.ToCustomerID == "" {
+			return errors.New("Missing required to_customer value")
+		}
+		if t.ToAccountID == "" {
+			return errors.New("Missing required to_account value")
+		}
+		if t.FromCustomerID == t.ToCustomerID && t.FromAccountID == t.ToAccountID {
+			return errors.New("Cannot transfer money into the same account")
+		}
+	}
 	if t.CurrencyCode == "" {
 		return errors.New("Missing required currency value")
 	}
-	// TODO: check valid currency codes
+	if !currency.IsValid(t.CurrencyCode) {
+		return fmt.Errorf("Invalid currency code %s", t.CurrencyCode)
+	}
+	if t.Escrow != nil {
+		return t.Escrow.Validate()
+	}
 	return nil
 }
+
+// Hash returns a hash of the transfer details, used to detect whether a retried
+// transfer is identical to the original request. The fields maintained by the
+// chaincode are not part of the hash.
+func (t *Transfer) Hash() string {
+	details := *t
+	details.Entity = Entity{}
+	details.ID = ""
+	details.Status = ""
+	details.FailureCode = TxFailureCodeNone
+	details.FailureReason = ""
+	details.Legs = nil
+	details.Screening = nil
+	details.Flags = nil
+	details.Review = nil
+	details.Approval = nil
+	details.HoldID = ""
+	details.Created = 0
+	details.OriginalID = ""
+	details.Reversed = 0
+	details.Reversals = nil
+	details.Payees = nil
+	for _, p := range t.Payees {
+		p.Share = 0
+		details.Payees = append(details.Payees, p)
+	}
+	data, _ := json.Marshal(&details)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
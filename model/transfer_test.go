@@ -1,49 +1,103 @@
 package model
 
-import "github.com/stretchr/testify/suite"
+import (
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
 
 type TransferSuite struct {
 	suite.Suite
 }
 
 func (suite *TransferSuite) TestValidateHappyPath() {
-	transfer := &Transfer{"1", "1234", "2", "5678", 100, 0, "AUD", "", map[string]string(nil)}
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
 	err := transfer.Validate()
 	suite.Nil(err)
 }
 
 func (suite *TransferSuite) TestMissingFromCustomer() {
-	transfer := &Transfer{"", "1234", "2", "5678", 100, 0, "AUD", "", map[string]string(nil)}
+	transfer := &Transfer{FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
 	err := transfer.Validate()
 	suite.NotNil(err)
 }
 
 func (suite *TransactionSuite) TestValidateMissingFromAccount() {
-	transfer := &Transfer{"1", "", "2", "5678", 100, 0, "AUD", "", map[string]string(nil)}
+	transfer := &Transfer{FromCustomerID: "1", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
 	err := transfer.Validate()
 	suite.NotNil(err)
 }
 
 func (suite *TransferSuite) TestMissingToCustomer() {
-	transfer := &Transfer{"1", "1234", "", "5678", 100, 0, "AUD", "", map[string]string(nil)}
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
 	err := transfer.Validate()
 	suite.NotNil(err)
 }
 
 func (suite *TransactionSuite) TestValidateMissingToAccount() {
-	transfer := &Transfer{"1", "1234", "2", "", 100, 0, "AUD", "", map[string]string(nil)}
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", Amount: 100, CurrencyCode: "AUD"}
 	err := transfer.Validate()
 	suite.NotNil(err)
 }
 
 func (suite *TransactionSuite) TestValidateIncorrectAmount() {
-	transfer := &Transfer{"1", "1234", "2", "5678", 0, 0, "AUD", "", map[string]string(nil)}
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", CurrencyCode: "AUD"}
 	err := transfer.Validate()
 	suite.NotNil(err)
 }
 
 func (suite *TransactionSuite) TestValidateMissingCurrency() {
-	transfer := &Transfer{"1", "1234", "2", "5678", 100, 0, "", "", map[string]string(nil)}
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100}
 	err := transfer.Validate()
 	suite.NotNil(err)
 }
+
+func (suite *TransferSuite) TestValidateInvalidCurrency() {
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "XYZ"}
+	err := transfer.Validate()
+	suite.Equal("Invalid currency code XYZ", err.Error())
+}
+
+func (suite *TransferSuite) TestValidateSameAccount() {
+	transfer := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "1", ToAccountID: "1234", Amount: 100, CurrencyCode: "AUD"}
+	err := transfer.Validate()
+	suite.Equal("Cannot transfer money into the same account", err.Error())
+}
+
+func (suite *TransferSuite) TestHash() {
+	t1 := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD", IdempotencyKey: "abc"}
+	t2 := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD", IdempotencyKey: "abc"}
+	suite.Equal(t1.Hash(), t2.Hash())
+	t2.Amount = 200
+	suite.NotEqual(t1.Hash(), t2.Hash())
+}
+
+func (suite *TransferSuite) TestHashIgnoresLifecycle() {
+	t1 := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
+	t2 := &Transfer{FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
+	t2.Begin(NewSequenceSource("tx1", time.Unix(1502798400, 0)))
+	t2.Complete()
+	suite.Equal(t1.Hash(), t2.Hash())
+}
+
+func (suite *TransferSuite) TestLifecycle() {
+	src := NewSequenceSource("tx1", time.Unix(1502798400, 0))
+	transfer := &Transfer{ID: "client", Status: TransferCompleted, FromCustomerID: "1", FromAccountID: "1234", ToCustomerID: "2", ToAccountID: "5678", Amount: 100, CurrencyCode: "AUD"}
+	transfer.Begin(src)
+	suite.Equal(TransferObjectType, transfer.GetObjectType())
+	suite.NotEqual("client", transfer.ID)
+	suite.Equal(TransferPending, transfer.Status)
+	suite.Equal(int64(1502798400), transfer.Created)
+
+	txn, _ := CreateTransaction(src, "1", "1234", transfer, nil, TxFailureCodeNone, Debited)
+	suite.Equal(transfer.ID, txn.TransferID)
+	transfer.AddLeg(txn)
+	suite.Equal([]TransferLeg{{TransactionID: txn.ID, CustomerID: "1", AccountID: "1234", Status: Debited}}, transfer.Legs)
+
+	transfer.Complete()
+	suite.Equal(TransferCompleted, transfer.Status)
+	transfer.Fail(InsufficientFunds, "Insufficient funds available in account 1234")
+	suite.Equal(TransferFailed, transfer.Status)
+	suite.Equal(InsufficientFunds, transfer.FailureCode)
+	suite.Equal("Insufficient funds available in account 1234", transfer.FailureReason)
+}
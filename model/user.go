@@ -1,11 +1,249 @@
 package model
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
 // UserObjectType blockchain object type
 const UserObjectType = "User"
 
-// User participant
+// KYCLevel stores allowed values for the level of identity verification of a customer.
+// Allowed values are "none", "basic", "full"
+type KYCLevel string
+
+const (
+	// KYCNone the customer's identity has not been verified, or the verification has expired
+	KYCNone KYCLevel = "none"
+	// KYCBasic the customer's identity has been verified with a single document
+	KYCBasic KYCLevel = "basic"
+	// KYCFull the customer's identity and address have been verified
+	KYCFull KYCLevel = "full"
+)
+
+// KYCTier the capabilities of customers verified to a KYC level. All active customers may receive transfers.
+type KYCTier struct {
+	Open            bool // may open accounts
+	MaxAccounts     int  // open accounts, 0 for no limit
+	Topup           bool // accounts may be topped up
+	Send            bool // may send transfers to accounts in the same country
+	SendCrossBorder bool // may send transfers to accounts in other countries
+}
+
+// KYCTiers capabilities by KYC level
+var KYCTiers = map[KYCLevel]KYCTier{
+	KYCNone:  {},
+	KYCBasic: {Open: true, MaxAccounts: 3, Topup: true, Send: true},
+	KYCFull:  {Open: true, Topup: true, Send: true, SendCrossBorder: true},
+}
+
+// KYCResult records the outcome of the identity verification of a customer
+type KYCResult struct {
+	Level        KYCLevel `json:"level"`
+	DocumentType string   `json:"document_type,omitempty"` // e.g. passport
+	Verifier     string   `json:"verifier"`
+	Expires      int64    `json:"expires,omitempty"` // unix time, the customer is back at level none from then on
+	Recorded     int64    `json:"recorded"`          // unix time
+}
+
+// CustomerStatus stores allowed values for the status of a customer.
+// Allowed values are "active", "deactivated"
+type CustomerStatus string
+
+const (
+	// CustomerActive customers may open accounts and transfer money
+	CustomerActive CustomerStatus = "active"
+	// CustomerDeactivated customers may no longer open accounts or transfer money
+	CustomerDeactivated CustomerStatus = "deactivated"
+)
+
+// User participant, a customer of the bank
 type User struct {
 	Entity
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	CountryCode string            `json:"country,omitempty"`  // country of residence
+	KYC         *KYCResult        `json:"kyc,omitempty"`      // latest KYC result, set by verifiers only
+	Contacts    map[string]string `json:"contacts,omitempty"` // references to contact details held off-ledger, by channel e.g. email
+	Status      CustomerStatus    `json:"status,omitempty"`
+	Created     int64             `json:"created,omitempty"` // unix time
+	Updated     int64             `json:"updated,omitempty"` // unix time
+}
+
+// CreateUser Factory function creates a new active User struct and returns a pointer to it
+func CreateUser(src Source, userBytes []byte) (*User, error) {
+	user := new(User)
+	if err := json.Unmarshal(userBytes, user); err != nil {
+		return nil, err
+	}
+	if user.ID == "" {
+		return nil, errors.New("Missing required id")
+	}
+	user.KYC = nil
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	user.Entity = Entity{UserObjectType}
+	user.Status = CustomerActive
+	user.Created = src.Now().Unix()
+	user.Updated = user.Created
+	return user, nil
+}
+
+// Validate - checks that the customer details are complete
+func (u *User) Validate() error {
+	if u.Name == "" {
+		return errors.New("Missing required name")
+	}
+	if len(u.CountryCode) != 2 {
+		return fmt.Errorf("Invalid country of residence %s", u.CountryCode)
+	}
+	return nil
+}
+
+// Update changes the name, country of residence and / or contact references
+// of an active customer to the values given in the JSON update. Contact references are
+// replaced as a whole.
+func (u *User) Update(updateBytes []byte, now time.Time) error {
+	if u.Status != CustomerActive {
+		return fmt.Errorf("Customer %s is not active", u.ID)
+	}
+	update := new(struct {
+		ID          string            `json:"id"`
+		Name        *string           `json:"name"`
+		CountryCode *string           `json:"country"`
+		Contacts    map[string]string `json:"contacts"`
+	})
+	if err := json.Unmarshal(updateBytes, update); err != nil {
+		return err
+	}
+	if update.ID != "" && update.ID != u.ID {
+		return errors.New("Customer id cannot be changed")
+	}
+	updated := *u
+	if update.Name != nil {
+		updated.Name = *update.Name
+	}
+	if update.CountryCode != nil {
+		updated.CountryCode = *update.CountryCode
+	}
+	if update.Contacts != nil {
+		updated.Contacts = update.Contacts
+	}
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	updated.Updated = now.Unix()
+	*u = updated
+	return nil
+}
+
+// Deactivate deactivates an active customer
+func (u *User) Deactivate(now time.Time) error {
+	if u.Status != CustomerActive {
+		return fmt.Errorf("Customer %s is not active", u.ID)
+	}
+	u.Status = CustomerDeactivated
+	u.Updated = now.Unix()
+	return nil
+}
+
+// CreateKYCResult Factory function creates a new KYCResult struct and returns a pointer to it.
+// The verifier is the caller recording the result, any verifier in the JSON is ignored.
+// Results at level basic or full require a document type and a future expiry.
+func CreateKYCResult(src Source, verifier string, resultBytes []byte) (*KYCResult, error) {
+	result := new(KYCResult)
+	if err := json.Unmarshal(resultBytes, result); err != nil {
+		return nil, err
+	}
+	result.Verifier = verifier
+	if _, ok := KYCTiers[result.Level]; !ok {
+		return nil, fmt.Errorf("Invalid KYC level %s", result.Level)
+	}
+	if result.Verifier == "" {
+		return nil, errors.New("Missing required verifier")
+	}
+	now := src.Now().Unix()
+	if result.Level != KYCNone {
+		if result.DocumentType == "" {
+			return nil, errors.New("Missing required document_type")
+		}
+		if result.Expires <= now {
+			return nil, errors.New("KYC result expiry must be in the future")
+		}
+	}
+	result.Recorded = now
+	return result, nil
+}
+
+// RecordKYC stores the result of the identity verification of an active customer
+func (u *User) RecordKYC(result *KYCResult) error {
+	if u.Status != CustomerActive {
+		return fmt.Errorf("Customer %s is not active", u.ID)
+	}
+	u.KYC = result
+	u.Updated = result.Recorded
+	return nil
+}
+
+// KYCLevel returns the level the customer is verified to at the given time
+func (u *User) KYCLevel(now time.Time) KYCLevel {
+	if u.KYC == nil || (u.KYC.Expires != 0 && u.KYC.Expires <= now.Unix()) {
+		return KYCNone
+	}
+	return u.KYC.Level
+}
+
+// CanOpenAccount returns an error unless the customer is active and KYC verified, and its KYC level allows
+// another account in addition to the given number of open accounts
+func (u *User) CanOpenAccount(openAccounts int, now time.Time) error {
+	if err := u.CanReceive(); err != nil {
+		return err
+	}
+	level := u.KYCLevel(now)
+	if !KYCTiers[level].Open {
+		return fmt.Errorf("Customer %s at KYC level %s cannot open accounts", u.ID, level)
+	}
+	if max := KYCTiers[level].MaxAccounts; max > 0 && openAccounts >= max {
+		return fmt.Errorf("Customer %s at KYC level %s may hold at most %d open accounts", u.ID, level, max)
+	}
+	return nil
+}
+
+// CanTopup returns an error unless the customer is active and its KYC level allows top-ups
+func (u *User) CanTopup(now time.Time) error {
+	if err := u.CanReceive(); err != nil {
+		return err
+	}
+	if level := u.KYCLevel(now); !KYCTiers[level].Topup {
+		return fmt.Errorf("Customer %s at KYC level %s cannot top up accounts", u.ID, level)
+	}
+	return nil
+}
+
+// CanSend returns an error unless the customer is active and its KYC level allows sending
+// transfers, to other countries if crossBorder is true
+func (u *User) CanSend(crossBorder bool, now time.Time) error {
+	if err := u.CanReceive(); err != nil {
+		return err
+	}
+	level := u.KYCLevel(now)
+	tier := KYCTiers[level]
+	if !tier.Send {
+		return fmt.Errorf("Customer %s at KYC level %s cannot send transfers", u.ID, level)
+	}
+	if crossBorder && !tier.SendCrossBorder {
+		return fmt.Errorf("Customer %s at KYC level %s cannot send cross-border transfers", u.ID, level)
+	}
+	return nil
+}
+
+// CanReceive returns an error unless the customer is active
+func (u *User) CanReceive() error {
+	if u.Status != CustomerActive {
+		return fmt.Errorf("Customer %s is not active", u.ID)
+	}
+	return nil
 }
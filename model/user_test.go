@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -13,7 +14,7 @@ type UserSuite struct {
 }
 
 func (suite *UserSuite) SetupTest() {
-	suite.testUser = User{Entity{"User"}, "1234", "John Smith"}
+	suite.testUser = User{Entity: Entity{"User"}, ID: "1234", Name: "John Smith"}
 	suite.testUserBytes = []byte(`{"docType":"User","id":"1234","name":"John Smith"}`)
 }
 
@@ -34,3 +35,94 @@ func (suite *UserSuite) TestMarshalUser() {
 	actual, _ := json.Marshal(suite.testUser)
 	suite.Equal(expected, actual)
 }
+
+func (suite *UserSuite) TestCreateUser() {
+	src := NewSequenceSource("t1", time.Unix(1502755200, 0))
+	user, err := CreateUser(src, []byte(`{"id":"1234","name":"John Smith","country":"AU","contacts":{"email":"crm:5678"},"status":"deactivated","kyc":{"level":"full"}}`))
+	suite.Nil(err)
+	suite.Equal(UserObjectType, user.ObjectType)
+	suite.Nil(user.KYC, "KYC results are only recorded by verifiers")
+	suite.Equal(CustomerActive, user.Status)
+	suite.Equal(int64(1502755200), user.Created)
+	suite.Equal("crm:5678", user.Contacts["email"])
+}
+
+func (suite *UserSuite) TestCreateUserValidation() {
+	src := NewSequenceSource("t1", time.Unix(1502755200, 0))
+	_, err := CreateUser(src, []byte(`{"name":"John Smith","country":"AU"}`))
+	suite.Equal("Missing required id", err.Error())
+	_, err = CreateUser(src, []byte(`{"id":"1234","country":"AU"}`))
+	suite.Equal("Missing required name", err.Error())
+	_, err = CreateUser(src, []byte(`{"id":"1234","name":"John Smith","country":"AUS"}`))
+	suite.Equal("Invalid country of residence AUS", err.Error())
+}
+
+func (suite *UserSuite) TestUpdateUser() {
+	user := &User{ID: "1234", Name: "John Smith", CountryCode: "AU", Status: CustomerActive}
+	now := time.Unix(1502755200, 0)
+	suite.Nil(user.Update([]byte(`{"country":"NZ","kyc":{"level":"full"}}`), now))
+	suite.Equal("John Smith", user.Name)
+	suite.Equal("NZ", user.CountryCode)
+	suite.Nil(user.KYC)
+	suite.Equal(now.Unix(), user.Updated)
+
+	suite.Equal("Customer id cannot be changed", user.Update([]byte(`{"id":"5678"}`), now).Error())
+	suite.Equal("Missing required name", user.Update([]byte(`{"name":""}`), now).Error())
+	suite.Equal("John Smith", user.Name, "Invalid updates are not applied")
+}
+
+func (suite *UserSuite) TestCreateKYCResult() {
+	src := NewSequenceSource("t1", time.Unix(1502755200, 0))
+	result, err := CreateKYCResult(src, "acme", []byte(`{"level":"full","document_type":"passport","verifier":"other","expires":1534291200,"recorded":1}`))
+	suite.Nil(err)
+	suite.Equal(KYCResult{KYCFull, "passport", "acme", 1534291200, 1502755200}, *result)
+	_, err = CreateKYCResult(src, "acme", []byte(`{"level":"none"}`))
+	suite.Nil(err)
+
+	_, err = CreateKYCResult(src, "acme", []byte(`{"level":"gold"}`))
+	suite.Equal("Invalid KYC level gold", err.Error())
+	_, err = CreateKYCResult(src, "", []byte(`{"level":"basic","document_type":"passport","expires":1534291200}`))
+	suite.Equal("Missing required verifier", err.Error())
+	_, err = CreateKYCResult(src, "acme", []byte(`{"level":"basic","expires":1534291200}`))
+	suite.Equal("Missing required document_type", err.Error())
+	_, err = CreateKYCResult(src, "acme", []byte(`{"level":"basic","document_type":"passport","expires":1502755200}`))
+	suite.Equal("KYC result expiry must be in the future", err.Error())
+}
+
+func (suite *UserSuite) TestKYCLevel() {
+	now := time.Unix(1502755200, 0)
+	user := &User{ID: "1234", Status: CustomerActive}
+	suite.Equal(KYCNone, user.KYCLevel(now))
+	suite.Nil(user.RecordKYC(&KYCResult{Level: KYCBasic, Expires: now.Unix() + 60, Recorded: now.Unix()}))
+	suite.Equal(KYCBasic, user.KYCLevel(now))
+	suite.Equal(KYCNone, user.KYCLevel(now.Add(time.Minute)), "Expired results fall back to level none")
+}
+
+func (suite *UserSuite) TestCustomerCapabilities() {
+	now := time.Unix(1502755200, 0)
+	expires := now.Unix() + 3600
+	user := &User{ID: "1234", Status: CustomerActive}
+	suite.Equal("Customer 1234 at KYC level none cannot open accounts", user.CanOpenAccount(0, now).Error())
+	suite.Equal("Customer 1234 at KYC level none cannot top up accounts", user.CanTopup(now).Error())
+	suite.Equal("Customer 1234 at KYC level none cannot send transfers", user.CanSend(false, now).Error())
+	suite.Nil(user.CanReceive())
+
+	user.KYC = &KYCResult{Level: KYCBasic, Expires: expires}
+	suite.Nil(user.CanOpenAccount(2, now))
+	suite.NotNil(user.CanOpenAccount(3, now))
+	suite.Nil(user.CanTopup(now))
+	suite.Nil(user.CanSend(false, now))
+	suite.Equal("Customer 1234 at KYC level basic cannot send cross-border transfers", user.CanSend(true, now).Error())
+
+	user.KYC = &KYCResult{Level: KYCFull, Expires: expires}
+	suite.Nil(user.CanOpenAccount(10, now))
+	suite.Nil(user.CanSend(true, now))
+
+	suite.Nil(user.Deactivate(now))
+	suite.Equal(CustomerDeactivated, user.Status)
+	suite.Equal("Customer 1234 is not active", user.CanReceive().Error())
+	suite.Equal("Customer 1234 is not active", user.CanSend(false, now).Error())
+	suite.Equal("Customer 1234 is not active", user.RecordKYC(&KYCResult{Level: KYCFull}).Error())
+	suite.Equal("Customer 1234 is not active", user.Deactivate(now).Error())
+	suite.Equal("Customer 1234 is not active", user.Update([]byte(`{"name":"Jack"}`), now).Error())
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// WatchListObjectType blockchain object type
+const WatchListObjectType = "WatchList"
+
+// DefaultMatchThreshold similarity score from which a name matches a watch-list name
+const DefaultMatchThreshold = "0.85"
+
+// ScreeningAction stores allowed values for what happens to a transfer matching the watch-list.
+// Allowed values are "block", "review"
+type ScreeningAction string
+
+const (
+	// BlockAction rejects matching transfers with the sanctions_hit failure code
+	BlockAction ScreeningAction = "block"
+	// ReviewAction holds matching transfers pending review by a compliance officer
+	ReviewAction ScreeningAction = "review"
+)
+
+// WatchListEntry a sanctioned or watched party. Any of the name, customer ID and
+// country may be given, each is matched on its own.
+type WatchListEntry struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	CustomerID  string `json:"customer_id,omitempty"`
+	CountryCode string `json:"country,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// WatchList holds the parties transfers and new accounts are screened against
+type WatchList struct {
+	Entity
+	Threshold *Decimal          `json:"threshold,omitempty"` // name similarity from 0 to 1, defaults to 0.85
+	Action    ScreeningAction   `json:"action,omitempty"`    // defaults to block
+	Entries   []*WatchListEntry `json:"entries"`
+}
+
+// Party the details of an account holder screened against the watch-list
+type Party struct {
+	Name        string
+	CustomerID  string
+	CountryCode string
+}
+
+// ScreeningHit records the watch-list entry a party matched
+type ScreeningHit struct {
+	EntryID    string   `json:"entry_id"`
+	CustomerID string   `json:"customer_id"`
+	AccountID  string   `json:"account_id,omitempty"`
+	Field      string   `json:"field"` // name, customer_id or country
+	Value      string   `json:"value"`
+	Score      *Decimal `json:"score,omitempty"` // similarity of a name match
+}
+
+// CreateWatchList Factory function creates a new WatchList struct and returns a pointer to it
+func CreateWatchList(listBytes []byte) (*WatchList, error) {
+	list := new(WatchList)
+	if err := json.Unmarshal(listBytes, list); err != nil {
+		return nil, err
+	}
+	list.ObjectType = WatchListObjectType
+	if list.Threshold == nil {
+		threshold := MustParseDecimal(DefaultMatchThreshold)
+		list.Threshold = &threshold
+	}
+	if list.Threshold.Sign() <= 0 || list.Threshold.Rat().Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, fmt.Errorf("Invalid threshold %s, must be greater than 0 and at most 1", list.Threshold)
+	}
+	if list.Action == "" {
+		list.Action = BlockAction
+	}
+	if list.Action != BlockAction && list.Action != ReviewAction {
+		return nil, fmt.Errorf("Invalid screening action %s", list.Action)
+	}
+	ids := make(map[string]bool)
+	for i, entry := range list.Entries {
+		if entry.ID == "" {
+			return nil, fmt.Errorf("Missing required id of entry %d", i+1)
+		}
+		if ids[entry.ID] {
+			return nil, fmt.Errorf("Duplicate entry id %s", entry.ID)
+		}
+		ids[entry.ID] = true
+		if normalizeName(entry.Name) == "" && entry.CustomerID == "" && entry.CountryCode == "" {
+			return nil, errors.New("Entry " + entry.ID + " must have a name, customer_id or country")
+		}
+	}
+	return list, nil
+}
+
+// Screen returns the first watch-list entry the party matches, or nil. Customer IDs and
+// countries must match exactly, names match if their similarity reaches the threshold.
+func (l *WatchList) Screen(p Party) *ScreeningHit {
+	name := normalizeName(p.Name)
+	for _, entry := range l.Entries {
+		if entry.CustomerID != "" && entry.CustomerID == p.CustomerID {
+			return &ScreeningHit{EntryID: entry.ID, CustomerID: p.CustomerID, Field: "customer_id", Value: p.CustomerID}
+		}
+		if entry.CountryCode != "" && strings.EqualFold(entry.CountryCode, p.CountryCode) {
+			return &ScreeningHit{EntryID: entry.ID, CustomerID: p.CustomerID, Field: "country", Value: p.CountryCode}
+		}
+		if entry.Name == "" || name == "" {
+			continue
+		}
+		if score := similarity(normalizeName(entry.Name), name); score.Cmp(l.Threshold.Rat()) >= 0 {
+			decimal := NewDecimalFromRat(score, 4)
+			return &ScreeningHit{EntryID: entry.ID, CustomerID: p.CustomerID, Field: "name", Value: p.Name, Score: &decimal}
+		}
+	}
+	return nil
+}
+
+// normalizeName lower-cases a name, drops punctuation and sorts its words,
+// so that "SMITH, John" and "john smith" are the same name
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// similarity returns 1 minus the edit distance of two names relative to the longer name
+func similarity(a string, b string) *big.Rat {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return big.NewRat(1, 1)
+	}
+	return big.NewRat(int64(longest-levenshtein(ra, rb)), int64(longest))
+}
+
+// levenshtein returns the number of single rune insertions, deletions and substitutions turning a into b
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,64 @@
+package model
+
+import "github.com/stretchr/testify/suite"
+
+type WatchListSuite struct {
+	suite.Suite
+}
+
+const testWatchList = `{"entries":[` +
+	`{"id":"w1","name":"Ivan Petrovich Sidorov","reason":"sanctioned"},` +
+	`{"id":"w2","customer_id":"666"},` +
+	`{"id":"w3","country":"KP"}]}`
+
+func (suite *WatchListSuite) TestCreateWatchList() {
+	list, err := CreateWatchList([]byte(testWatchList))
+	suite.Nil(err)
+	suite.Equal(WatchListObjectType, list.ObjectType)
+	suite.Equal(BlockAction, list.Action)
+	suite.Equal("0.85", list.Threshold.String())
+}
+
+func (suite *WatchListSuite) TestCreateWatchListValidation() {
+	_, err := CreateWatchList([]byte(`{"threshold":1.5,"entries":[]}`))
+	suite.Equal("Invalid threshold 1.5, must be greater than 0 and at most 1", err.Error())
+	_, err = CreateWatchList([]byte(`{"action":"ignore","entries":[]}`))
+	suite.Equal("Invalid screening action ignore", err.Error())
+	_, err = CreateWatchList([]byte(`{"entries":[{"name":"John"}]}`))
+	suite.Equal("Missing required id of entry 1", err.Error())
+	_, err = CreateWatchList([]byte(`{"entries":[{"id":"a","name":"John"},{"id":"a","name":"Jane"}]}`))
+	suite.Equal("Duplicate entry id a", err.Error())
+	_, err = CreateWatchList([]byte(`{"entries":[{"id":"a","name":" - "}]}`))
+	suite.Equal("Entry a must have a name, customer_id or country", err.Error())
+}
+
+func (suite *WatchListSuite) TestScreenIDAndCountry() {
+	list, _ := CreateWatchList([]byte(testWatchList))
+	hit := list.Screen(Party{Name: "Jane Doe", CustomerID: "666", CountryCode: "AU"})
+	suite.Equal("w2", hit.EntryID)
+	suite.Equal("customer_id", hit.Field)
+	hit = list.Screen(Party{Name: "Jane Doe", CustomerID: "1", CountryCode: "kp"})
+	suite.Equal("w3", hit.EntryID)
+	suite.Nil(list.Screen(Party{Name: "Jane Doe", CustomerID: "1", CountryCode: "AU"}))
+}
+
+func (suite *WatchListSuite) TestScreenNames() {
+	list, _ := CreateWatchList([]byte(testWatchList))
+	// case, punctuation and word order are ignored
+	hit := list.Screen(Party{Name: "SIDOROV, Ivan Petrovich"})
+	suite.Equal("w1", hit.EntryID)
+	suite.Equal("1", hit.Score.String())
+	// a misspelling within the threshold still matches
+	hit = list.Screen(Party{Name: "Ivan Petrovic Sidorow"})
+	suite.Equal("w1", hit.EntryID)
+	suite.Equal("0.9091", hit.Score.String())
+	suite.Nil(list.Screen(Party{Name: "Ivan Sidorov"}))
+
+	strict, _ := CreateWatchList([]byte(`{"threshold":0.95,"entries":[{"id":"w1","name":"Ivan Petrovich Sidorov"}]}`))
+	suite.Nil(strict.Screen(Party{Name: "Ivan Petrovic Sidorow"}))
+}
+
+func (suite *WatchListSuite) TestNormalizeName() {
+	suite.Equal("john o smith", normalizeName("  Smith, John O. "))
+	suite.Equal("", normalizeName("--"))
+}
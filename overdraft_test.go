@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// setOverdraftLimit sets the overdraft limit of an account as a bank operator
+func (suite *ChaincodeSuite) setOverdraftLimit(customerID string, accountID string, limit string) {
+	suite.asRole(BankOperatorRole)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "UpdateOverdraftLimit", []string{customerID, accountID, limit})
+	suite.Nil(err)
+}
+
+func (suite *ChaincodeSuite) TestUpdateOverdraftLimitValidation() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	_, err := suite.stub.MockInvoke("t1", "UpdateOverdraftLimit", []string{"1", "1234"})
+	suite.Equal("Missing required input arguments", err.Error())
+	suite.asRole(BankOperatorRole)
+	_, err = suite.stub.MockInvoke("t1", "UpdateOverdraftLimit", []string{"1", "1234", "abc"})
+	suite.Equal("Error parsing overdraft limit value abc", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "UpdateOverdraftLimit", []string{"1", "1234", "-100"})
+	suite.Equal("Invalid overdraft limit -100", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestUpdateOverdraftLimitRequiresBankOperator() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.asRole(ComplianceRole)
+	_, err := suite.stub.MockInvoke("t1", "UpdateOverdraftLimit", []string{"1", "1234", "500"})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+	suite.asCustomer("1")
+	_, err = suite.stub.MockInvoke("t1", "UpdateOverdraftLimit", []string{"1", "1234", "500"})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(0), suite.getAccount("1", "1234").Overdraft)
+}
+
+func (suite *ChaincodeSuite) TestUpdateOverdraftLimit() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.setOverdraftLimit("1", "1234", "500")
+	suite.Equal(int64(500), suite.getAccount("1", "1234").Overdraft)
+	balance := suite.getBalance("1", "1234")
+	suite.Equal(int64(500), balance.Overdraft)
+	suite.Equal(int64(1500), balance.Available)
+}
+
+func (suite *ChaincodeSuite) TestTransferIntoOverdraft() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.setOverdraftLimit("1", "1234", "500")
+
+	// the fee is covered by the overdraft facility too
+	t := suite.transferMoney("1", "1234", "2", "5678", "AUD", 1400)
+	suite.Equal(model.TransferCompleted, t.Status)
+	suite.Equal(int64(-450), suite.getAccount("1", "1234").Balance)
+
+	transfer := `{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":1}`
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	suite.Equal("Insufficient funds available in account 1234", suite.failureReason(res))
+	suite.Equal(model.OverdraftExceeded, suite.getFailedTransaction("1", "1234").FailureCode)
+	suite.Equal(int64(-450), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestHoldWithinOverdraft() {
+	suite.openAccount("1", "1234", "AU", "AUD", 100)
+	suite.setOverdraftLimit("1", "1234", "500")
+	_, err := suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":600}`)
+	suite.Nil(err)
+	_, err = suite.placeHold(`{"customer_id":"1","account_id":"1234","amount":1}`)
+	suite.Equal("Insufficient funds available in account 1234", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestLowerOverdraftLimitBelowUsage() {
+	suite.openAccount("1", "1234", "AU", "AUD", 0)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.setOverdraftLimit("1", "1234", "500")
+	suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+
+	// the account stays overdrawn but cannot be debited any further
+	suite.setOverdraftLimit("1", "1234", "100")
+	account := suite.getAccount("1", "1234")
+	suite.Equal(int64(-400), account.Balance)
+	suite.Equal(int64(-300), account.Available())
+	suite.Equal(int64(-300), suite.getBalance("1", "1234").Available)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// AddJointHolder adds an active customer as joint holder of an account. Joint holders have
+// the same permissions on the account as its owner. Args are the customer ID, account ID and
+// the customer ID of the new holder. Only bank operators can add joint holders.
+func (cc *Chaincode) AddJointHolder(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering AddJointHolder with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required customer ID, account ID and / or joint holder ID")
+	}
+
+	state := newTxState(stub)
+	account, err := cc.getPartyAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.checkParty(state, account, args[2]); err != nil {
+		return nil, err
+	}
+	if err := account.AddJointHolder(args[2]); err != nil {
+		return nil, err
+	}
+	return cc.commitParties(state, account, args[2], true)
+}
+
+// SetSignatory authorises a customer to act on an account, or changes the mandate of an
+// existing signatory. Args are the customer ID, account ID and the signatory JSON with the
+// customer ID, permission and, for the pay permission, the pay limit per transfer. Once the
+// account has an approval policy, only bank operators can set signatories.
+func (cc *Chaincode) SetSignatory(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetSignatory with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required customer ID, account ID and / or signatory JSON")
+	}
+
+	signatory, err := model.CreateSignatory([]byte(args[2]))
+	if err != nil {
+		logger.Errorf("Error when creating signatory. Error: %s", err)
+		return nil, fmt.Errorf("Error creating signatory. Error: %s", err)
+	}
+	state := newTxState(stub)
+	account, err := cc.getPartyAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.checkParty(state, account, signatory.CustomerID); err != nil {
+		return nil, err
+	}
+	if err := account.SetSignatory(signatory); err != nil {
+		return nil, err
+	}
+	return cc.commitParties(state, account, signatory.CustomerID, true)
+}
+
+// RemoveAccountParty removes a joint holder or signatory from an account. Args are the
+// customer ID, account ID and the customer ID of the party to remove. Once the account has
+// an approval policy, only bank operators can remove parties.
+func (cc *Chaincode) RemoveAccountParty(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering RemoveAccountParty with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required customer ID, account ID and / or party ID")
+	}
+
+	state := newTxState(stub)
+	account, err := cc.getPartyAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := account.RemoveParty(args[2]); err != nil {
+		return nil, err
+	}
+	return cc.commitParties(state, account, args[2], false)
+}
+
+// SetApprovalPolicy requires transfers from an account above a threshold to be approved
+// by a number of its approvers before they are executed. Args are the customer ID, account
+// ID and the policy JSON with the threshold and the number of required approvals, a
+// required value of 0 removes the policy. Once set, only bank operators can change it.
+func (cc *Chaincode) SetApprovalPolicy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetApprovalPolicy with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required customer ID, account ID and / or approval policy JSON")
+	}
+
+	policy, err := model.CreateApprovalPolicy([]byte(args[2]))
+	if err != nil {
+		logger.Errorf("Error when creating approval policy. Error: %s", err)
+		return nil, fmt.Errorf("Error creating approval policy. Error: %s", err)
+	}
+	state := newTxState(stub)
+	account, err := cc.getPartyAccount(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := account.SetApprovalPolicy(policy); err != nil {
+		return nil, err
+	}
+	accountData, err := cc.putAccount(state, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return accountData, nil
+}
+
+// getPartyAccount reads an account whose parties may be changed. The parties and approval
+// policy of an account with an approval policy can only be changed by bank operators, so
+// that a single holder cannot lower the number of approvals the policy requires.
+func (cc *Chaincode) getPartyAccount(state *txState, customerID, accountID string) (*model.Account, error) {
+	account, err := cc.getAccount(state, customerID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Cannot change parties of closed account %s", account.ID)
+	}
+	if account.Approval != nil {
+		id, err := cc.caller(state.stub)
+		if err != nil {
+			return nil, err
+		}
+		if !id.hasRole(BankOperatorRole) {
+			return nil, fmt.Errorf("Account %s has an approval policy, its parties and policy can only be changed by a bank operator", account.ID)
+		}
+	}
+	return account, nil
+}
+
+// checkParty checks that a customer joining an account exists, is active and does not
+// match the watch-list
+func (cc *Chaincode) checkParty(state *txState, account *model.Account, customerID string) error {
+	customer, err := cc.getCustomer(state, customerID)
+	if err != nil {
+		return err
+	}
+	if err := customer.CanReceive(); err != nil {
+		return err
+	}
+	return cc.screenParty(state, account, customer)
+}
+
+// commitParties writes an account with changed parties and adds or removes the party index
+// entry listing the account under the given party
+func (cc *Chaincode) commitParties(state *txState, account *model.Account, partyID string, add bool) ([]byte, error) {
+	key, err := cc.createCompositeKey(model.AccountPartyObjectType, []string{partyID, account.CustomerID, account.ID})
+	if err != nil {
+		return nil, err
+	}
+	if add {
+		party := &model.AccountParty{
+			Entity:     model.Entity{ObjectType: model.AccountPartyObjectType},
+			PartyID:    partyID,
+			CustomerID: account.CustomerID,
+			AccountID:  account.ID,
+		}
+		if _, err := state.putObject(key, party); err != nil {
+			return nil, err
+		}
+	} else {
+		state.delState(key)
+	}
+	accountData, err := cc.putAccount(state, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return accountData, nil
+}
+
+// getPartyAccounts returns the accounts of other customers a customer is a joint holder or signatory of
+func (cc *Chaincode) getPartyAccounts(stub shim.ChaincodeStubInterface, customerID string) ([]*model.Account, error) {
+	keysIter, err := cc.partialCompositeKeyQuery(stub, model.AccountPartyObjectType, []string{customerID})
+	if err != nil {
+		return nil, err
+	}
+	defer keysIter.Close()
+
+	state := newTxState(stub)
+	var accounts []*model.Account
+	for keysIter.HasNext() {
+		_, partyBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		party := new(model.AccountParty)
+		if err := bytesToStruct(partyBytes, party); err != nil {
+			return nil, err
+		}
+		account, err := cc.getAccount(state, party.CustomerID, party.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
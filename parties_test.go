@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// getAccountList returns the accounts listed for a customer
+func (suite *ChaincodeSuite) getAccountList(customerID string) []*model.Account {
+	res, err := suite.stub.MockInvoke("t0", "GetAccountList", []string{customerID})
+	suite.Nil(err)
+	list := new(model.AccountList)
+	json.Unmarshal(res, list)
+	return list.Accounts
+}
+
+func (suite *ChaincodeSuite) TestJointHolder() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("10", "5678", "AU", "AUD", 0)
+
+	_, err := suite.stub.MockInvoke("t1", "AddJointHolder", []string{"1", "1234"})
+	suite.Equal("Missing required customer ID, account ID and / or joint holder ID", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "AddJointHolder", []string{"1", "1234", "42"})
+	suite.Equal("Customer 42 not found", err.Error())
+	res, err := suite.stub.MockInvoke("t1", "AddJointHolder", []string{"1", "1234", "2"})
+	suite.Nil(err)
+	account := new(model.Account)
+	json.Unmarshal(res, account)
+	suite.Equal([]string{"2"}, account.JointHolders)
+	suite.Len(suite.getAccountList("2"), 1, "Joint accounts are listed for all holders")
+	suite.Equal("1234", suite.getAccountList("2")[0].ID)
+
+	suite.asCustomer("2")
+	suite.Equal(int64(1000), suite.getBalance("1", "1234").Available)
+	suite.transferMoney("1", "1234", "10", "5678", "AUD", 400)
+	_, err = suite.stub.MockInvoke("t2", "AddJointHolder", []string{"1", "1234", "10"})
+	suite.Equal("Caller is not authorised, role bank_operator is required", err.Error())
+
+	suite.asCustomer("10")
+	_, err = suite.stub.MockInvoke("t3", "GetAccount", []string{"1", "1234"})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+
+	suite.asCustomer("1")
+	_, err = suite.stub.MockInvoke("t4", "RemoveAccountParty", []string{"1", "1234", "2"})
+	suite.Nil(err)
+	suite.asCustomer("2")
+	suite.Empty(suite.getAccountList("2"))
+	suite.Equal("Caller is not authorised to act for customer 1", suite.tryTransfer("1", "1234", "10", "5678", 100).Error())
+}
+
+func (suite *ChaincodeSuite) TestPartiesMustBeEligibleToPay() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("10", "5678", "AU", "AUD", 0)
+	suite.openAccount("11", "9012", "NZ", "AUD", 0)
+	suite.registerCustomer("3")
+	suite.stub.MockInvoke("t1", "AddJointHolder", []string{"1", "1234", "2"})
+	suite.asCustomer("1")
+	suite.stub.MockInvoke("t2", "SetSignatory", []string{"1", "1234", `{"customer_id":"3","permission":"full"}`})
+
+	// a signatory verified to level basic cannot send cross-border transfers from the owner's account
+	suite.asRole(BankOperatorRole)
+	suite.recordKYC("3", model.KYCBasic)
+	suite.asCustomer("3")
+	suite.Nil(suite.tryTransfer("1", "1234", "10", "5678", 100))
+	err := suite.tryTransfer("1", "1234", "11", "9012", 100)
+	suite.Equal("Customer 3 at KYC level basic cannot send cross-border transfers", err.Error())
+	suite.Equal(model.CustomerIneligible, suite.getFailedTransaction("1", "1234").FailureCode)
+
+	// a deactivated joint holder can no longer pay
+	suite.asRole(BankOperatorRole)
+	suite.stub.MockInvoke("t3", "DeactivateCustomer", []string{"2"})
+	suite.asCustomer("2")
+	err = suite.tryTransfer("1", "1234", "10", "5678", 100)
+	suite.Equal("Customer 2 is not active", err.Error())
+	suite.asCustomer("1")
+	suite.Nil(suite.tryTransfer("1", "1234", "10", "5678", 100), "The owner is still eligible")
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(800), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestSignatories() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("10", "5678", "AU", "AUD", 0)
+
+	suite.asCustomer("1")
+	_, err := suite.stub.MockInvoke("t1", "SetSignatory", []string{"1", "1234", `{"customer_id":"2","permission":"owner"}`})
+	suite.Equal("Error creating signatory. Error: Invalid permission owner", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "SetSignatory", []string{"1", "1234", `{"customer_id":"2","permission":"view"}`})
+	suite.Nil(err)
+	_, err = suite.stub.MockInvoke("t2", "SetSignatory", []string{"1", "1234", `{"customer_id":"10","permission":"pay","pay_limit":300}`})
+	suite.Nil(err)
+
+	suite.asCustomer("10")
+	suite.Len(suite.getAccountList("10"), 2, "Accounts are listed for their signatories")
+	suite.asCustomer("2")
+	_, err = suite.stub.MockInvoke("t3", "GetTransactionList", []string{"1", "1234"})
+	suite.Nil(err, "View permission allows queries")
+	suite.Equal("Caller is not authorised to act for customer 1", suite.tryTransfer("1", "1234", "10", "5678", 100).Error())
+	_, err = suite.stub.MockInvoke("t4", "SetSignatory", []string{"1", "1234", `{"customer_id":"2","permission":"full"}`})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error(), "Signatories cannot change mandates")
+
+	suite.asCustomer("10")
+	suite.Nil(suite.tryTransfer("1", "1234", "10", "5678", 300))
+	suite.Equal("Caller is not authorised to act for customer 1", suite.tryTransfer("1", "1234", "10", "5678", 301).Error())
+	_, err = suite.stub.MockInvoke("t5", "CloseAccount", []string{"1", "1234"})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(700), suite.getAccount("1", "1234").Balance)
+	_, err = suite.stub.MockInvoke("t6", "RemoveAccountParty", []string{"1", "1234", "1"})
+	suite.Equal("Cannot remove owner 1 of account 1234", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestApprovalPolicy() {
+	suite.openAccount("1", "1234", "AU", "AUD", 10000)
+	suite.openAccount("10", "5678", "AU", "AUD", 0)
+
+	_, err := suite.stub.MockInvoke("t1", "SetApprovalPolicy", []string{"1", "1234", `{"threshold":1000,"required":2}`})
+	suite.Equal("Approval policy requires 2 approvals, account 1234 has 1 approvers", err.Error())
+	_, err = suite.stub.MockInvoke("t2", "AddJointHolder", []string{"1", "1234", "2"})
+	suite.Nil(err)
+	_, err = suite.stub.MockInvoke("t3", "SetApprovalPolicy", []string{"1", "1234", `{"threshold":1000,"required":2}`})
+	suite.Nil(err)
+
+	suite.asCustomer("1")
+	suite.Equal(model.TransferCompleted, suite.transferMoney("1", "1234", "10", "5678", "AUD", 1000).Status)
+	t := suite.transferMoney("1", "1234", "10", "5678", "AUD", 5000)
+	suite.Equal(model.TransferPendingApproval, t.Status)
+	suite.Equal(2, t.Approval.Required)
+	suite.Equal([]model.Approval{{CustomerID: "1", Approved: suite.now.Unix()}}, t.Approval.Approvals, "The initiating holder approves")
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 9000, Held: 5000, Available: 4000}, suite.getBalance("1", "1234"))
+
+	_, err = suite.stub.MockInvoke("t4", "ApproveTransfer", []string{t.ID})
+	suite.Equal("Transfer "+t.ID+" has already been approved by customer 1", err.Error())
+	suite.asCustomer("10")
+	_, err = suite.stub.MockInvoke("t5", "ApproveTransfer", []string{t.ID})
+	suite.Equal("Caller is not authorised to approve transfer "+t.ID, err.Error())
+	suite.asRole(ComplianceRole)
+	_, err = suite.stub.MockInvoke("t6", "ApproveTransfer", []string{t.ID, "ok"})
+	suite.Equal("Caller is not authorised to approve transfer "+t.ID, err.Error())
+
+	suite.asCustomer("2")
+	res, err := suite.stub.MockInvoke("t7", "ApproveTransfer", []string{t.ID})
+	suite.Nil(err)
+	json.Unmarshal(res, t)
+	suite.Equal(model.TransferCompleted, t.Status)
+	suite.Len(t.Approval.Approvals, 2)
+	suite.Equal(&model.AccountBalance{CustomerID: "1", AccountID: "1234", CurrencyCode: "AUD", Balance: 4000, Held: 0, Available: 4000}, suite.getBalance("1", "1234"))
+	suite.asRole(BankOperatorRole)
+	suite.Equal(int64(6000), suite.getAccount("10", "5678").Balance)
+}
+
+func (suite *ChaincodeSuite) TestSingleHolderCannotLowerQuorum() {
+	suite.openAccount("1", "1234", "AU", "AUD", 10000)
+	suite.checkInvoke("AddJointHolder", []string{"1", "1234", "2"})
+
+	suite.asCustomer("1")
+	_, err := suite.stub.MockInvoke("t1", "SetApprovalPolicy", []string{"1", "1234", `{"threshold":1000,"required":2}`})
+	suite.Nil(err, "Holders can set up an approval policy")
+
+	message := "Account 1234 has an approval policy, its parties and policy can only be changed by a bank operator"
+	suite.asCustomer("2")
+	_, err = suite.stub.MockInvoke("t2", "SetApprovalPolicy", []string{"1", "1234", `{"required":0}`})
+	suite.Equal(message, err.Error())
+	_, err = suite.stub.MockInvoke("t3", "RemoveAccountParty", []string{"1", "1234", "2"})
+	suite.Equal(message, err.Error())
+	_, err = suite.stub.MockInvoke("t4", "SetSignatory", []string{"1", "1234", `{"customer_id":"10","permission":"full"}`})
+	suite.Equal(message, err.Error())
+	suite.asCustomer("1")
+	_, err = suite.stub.MockInvoke("t5", "RemoveAccountParty", []string{"1", "1234", "2"})
+	suite.Equal(message, err.Error())
+
+	suite.asRole(BankOperatorRole)
+	account := suite.getAccount("1", "1234")
+	suite.Equal(&model.ApprovalPolicy{Threshold: 1000, Required: 2, ExpiryHours: model.DefaultApprovalExpiryHours}, account.Approval)
+	suite.Equal([]string{"2"}, account.JointHolders)
+	suite.Empty(account.Signatories)
+	_, err = suite.stub.MockInvoke("t6", "SetApprovalPolicy", []string{"1", "1234", `{"required":0}`})
+	suite.Nil(err, "Bank operators can change the policy")
+}
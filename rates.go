@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// PublishRates stores the exchange rates for a base currency and date.
+// Rates can only be published once per base and date, never for a date after
+// the transaction date and never for a date before the latest published rates
+// of the same base.
+func (cc *Chaincode) PublishRates(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering PublishRates with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required rates data JSON")
+	}
+
+	rates, err := model.CreateRates([]byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when creating exchange rates. Error: %s", err)
+		return nil, fmt.Errorf("Error creating exchange rates. Error: %s", err)
+	}
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	if today := src.Now().UTC().Format(model.RatesDateFormat); rates.Date > today {
+		return nil, fmt.Errorf("Cannot publish exchange rates for base %s on %s after the transaction date %s", rates.Base, rates.Date, today)
+	}
+	latest, err := cc.getLatestRates(stub, rates.Base)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Date == rates.Date {
+		return nil, fmt.Errorf("Exchange rates for base %s on %s already published", rates.Base, rates.Date)
+	}
+	if latest != nil && latest.Date > rates.Date {
+		return nil, fmt.Errorf("Cannot publish exchange rates for base %s on %s before latest rates published on %s", rates.Base, rates.Date, latest.Date)
+	}
+	key, _ := cc.createCompositeKey(rates.GetObjectType(), []string{rates.Base, rates.Date})
+	ratesData, err := json.Marshal(rates)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling exchange rates. Error: %s", err)
+	}
+	if err := stub.PutState(key, ratesData); err != nil {
+		return nil, fmt.Errorf("Error storing exchange rates. Error: %s", err)
+	}
+	if err := cc.putLatestRates(stub, rates.Base, rates.Date); err != nil {
+		return nil, err
+	}
+	return ratesData, nil
+}
+
+// GetRates query the exchange rates of a base currency in effect on the given date
+func (cc *Chaincode) GetRates(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetRates with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required base currency and / or date")
+	}
+	rates, err := cc.getRates(stub, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rates)
+}
+
+// GetLatestRates query the most recently published exchange rates of a base currency
+func (cc *Chaincode) GetLatestRates(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetLatestRates with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required base currency")
+	}
+	rates, err := cc.getLatestRates(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if rates == nil {
+		return nil, fmt.Errorf("No exchange rates available for base %s", args[0])
+	}
+	return json.Marshal(rates)
+}
+
+// GetRatesHistory query the exchange rates of a base currency published between two dates (inclusive)
+func (cc *Chaincode) GetRatesHistory(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetRatesHistory with args %v", args)
+
+	if len(args) != 3 {
+		return nil, errors.New("Missing required base currency, from date and / or to date")
+	}
+	ratesList := model.RatesList{}
+	rates, err := cc.queryRates(stub, args[0], args[1], args[2])
+	if err != nil {
+		logger.Errorf("Failed to get exchange rates history. Error: %s", err)
+		return nil, err
+	}
+	ratesList.Rates = rates
+	jsonList, _ := json.Marshal(ratesList)
+	logger.Debugf("Returning exchange rates history: %s", jsonList)
+	return jsonList, nil
+}
+
+// SetRatesConfig stores the settings applied when converting between currencies, replacing
+// any previous settings
+func (cc *Chaincode) SetRatesConfig(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetRatesConfig with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required rates config JSON")
+	}
+	config, err := model.CreateRatesConfig([]byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when creating rates config. Error: %s", err)
+		return nil, fmt.Errorf("Error creating rates config. Error: %s", err)
+	}
+	key, err := cc.createCompositeKey(model.RatesConfigObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	configData, err := state.putObject(key, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return configData, nil
+}
+
+// GetRatesConfig query the current rates config
+func (cc *Chaincode) GetRatesConfig(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetRatesConfig with args %v", args)
+
+	config, err := cc.getRatesConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(config)
+}
+
+// convert converts an amount between two currencies using the rates in effect on the given date.
+// Rates published for the source currency are preferred, then rates for the destination
+// currency (inverse rate) and finally, if one is configured, rates for the reference
+// currency (cross rate).
+func (cc *Chaincode) convert(stub shim.ChaincodeStubInterface, amount int64, from string, to string, date string) (*model.Conversion, error) {
+	config, err := cc.getRatesConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	bases := []string{from, to}
+	if ref := config.ReferenceCurrency; ref != "" && ref != from && ref != to {
+		bases = append(bases, ref)
+	}
+	var lastErr error
+	for _, base := range bases {
+		rates, err := cc.getRates(stub, base, date)
+		if err == nil {
+			var conversion *model.Conversion
+			if conversion, err = rates.Convert(amount, from, to); err == nil {
+				return conversion, nil
+			}
+		}
+		if lastErr == nil {
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+// getRatesConfig returns the current rates config, without a reference currency if none has been set
+func (cc *Chaincode) getRatesConfig(stub shim.ChaincodeStubInterface) (*model.RatesConfig, error) {
+	key, err := cc.createCompositeKey(model.RatesConfigObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	config := &model.RatesConfig{Entity: model.Entity{ObjectType: model.RatesConfigObjectType}}
+	if _, err := newTxState(stub).getObject(key, config); err != nil {
+		logger.Errorf("Failed to get rates config. Error: %s", err)
+		return nil, err
+	}
+	return config, nil
+}
+
+// getRates returns the latest exchange rates for the given base published on or before the
+// given date. Rates published for the date itself are read directly, otherwise the latest
+// rates apply if they were published before the date. Failing both, the rates published in
+// the RatesLookbackDays before the date are searched.
+func (cc *Chaincode) getRates(stub shim.ChaincodeStubInterface, base string, date string) (*model.Rates, error) {
+	rates, err := cc.getRatesOn(stub, base, date)
+	if err != nil || rates != nil {
+		return rates, err
+	}
+	latest, err := cc.getLatestRates(stub, base)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Date < date {
+		return latest, nil
+	}
+	if day, err := time.Parse(model.RatesDateFormat, date); err == nil && latest != nil {
+		from := day.AddDate(0, 0, -model.RatesLookbackDays).Format(model.RatesDateFormat)
+		ratesList, err := cc.queryRates(stub, base, from, date)
+		if err != nil {
+			return nil, err
+		}
+		if len(ratesList) > 0 {
+			return ratesList[len(ratesList)-1], nil
+		}
+	}
+	return nil, fmt.Errorf("No exchange rates available for base %s on %s", base, date)
+}
+
+// getLatestRates returns the most recently published exchange rates for the given base, or nil if there are none
+func (cc *Chaincode) getLatestRates(stub shim.ChaincodeStubInterface, base string) (*model.Rates, error) {
+	key, _ := cc.createCompositeKey(model.LatestRatesObjectType, []string{base})
+	pointerBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching latest exchange rates: %s", err)
+	}
+	if pointerBytes == nil {
+		return nil, nil
+	}
+	pointer := new(model.LatestRates)
+	if err := bytesToStruct(pointerBytes, pointer); err != nil {
+		return nil, err
+	}
+	return cc.getRatesOn(stub, base, pointer.Date)
+}
+
+// putLatestRates points the latest rates of the given base to the rates published for the given date
+func (cc *Chaincode) putLatestRates(stub shim.ChaincodeStubInterface, base string, date string) error {
+	pointer := &model.LatestRates{Entity: model.Entity{ObjectType: model.LatestRatesObjectType}, Base: base, Date: date}
+	key, _ := cc.createCompositeKey(pointer.GetObjectType(), []string{base})
+	pointerData, err := json.Marshal(pointer)
+	if err != nil {
+		return fmt.Errorf("Error marshalling latest exchange rates. Error: %s", err)
+	}
+	if err := stub.PutState(key, pointerData); err != nil {
+		return fmt.Errorf("Error storing latest exchange rates. Error: %s", err)
+	}
+	return nil
+}
+
+// getRatesOn returns the exchange rates for the given base published for exactly the given date,
+// or nil if there are none
+func (cc *Chaincode) getRatesOn(stub shim.ChaincodeStubInterface, base string, date string) (*model.Rates, error) {
+	key, _ := cc.createCompositeKey(model.RatesObjectType, []string{base, date})
+	ratesBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching exchange rates: %s", err)
+	}
+	if ratesBytes == nil {
+		return nil, nil
+	}
+	rates := new(model.Rates)
+	if err := bytesToStruct(ratesBytes, rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// queryRates returns the exchange rates for the given base ordered by date.
+// Empty from / to dates leave the date range open.
+func (cc *Chaincode) queryRates(stub shim.ChaincodeStubInterface, base string, from string, to string) ([]*model.Rates, error) {
+	startKey, _ := cc.createCompositeKey(model.RatesObjectType, []string{base})
+	endKey := startKey
+	if from != "" {
+		startKey, _ = cc.createCompositeKey(model.RatesObjectType, []string{base, from})
+	}
+	if to != "" {
+		endKey, _ = cc.createCompositeKey(model.RatesObjectType, []string{base, to})
+	}
+	keysIter, err := stub.RangeQueryState(startKey, endKey+string(utf8.MaxRune))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching exchange rates: %s", err)
+	}
+	ratesList := []*model.Rates{}
+	for keysIter.HasNext() {
+		_, ratesBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching exchange rates: %s", err)
+		}
+		rates := new(model.Rates)
+		if err := bytesToStruct(ratesBytes, rates); err != nil {
+			return nil, err
+		}
+		ratesList = append(ratesList, rates)
+	}
+	return ratesList, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// publishRates publishes exchange rates on the date they are for
+func (suite *ChaincodeSuite) publishRates(txID string, rates string) {
+	r := new(struct {
+		Date string `json:"date"`
+	})
+	json.Unmarshal([]byte(rates), r)
+	defer func(now time.Time) { suite.now = now }(suite.now)
+	suite.now, _ = time.Parse(model.RatesDateFormat, r.Date)
+	_, err := suite.stub.MockInvoke(txID, "PublishRates", []string{rates})
+	suite.Nil(err)
+}
+
+func (suite *ChaincodeSuite) publishTestRates() {
+	suite.publishRates("t1", `{"base":"AUD","date":"2017-08-14","rates":{"HKD":6.1484,"NZD":1.0793,"SGD":1.0729}}`)
+	suite.publishRates("t2", `{"base":"AUD","date":"2017-08-15","rates":{"HKD":6.1502,"NZD":1.0801,"SGD":1.0733}}`)
+	suite.publishRates("t3", `{"base":"AUD","date":"2017-08-17","rates":{"HKD":6.1399,"NZD":1.0788,"SGD":1.0712}}`)
+}
+
+func (suite *ChaincodeSuite) TestPublishRatesValidation() {
+	_, err := suite.stub.MockInvoke("t1234", "PublishRates", []string{})
+	suite.Equal("Missing required rates data JSON", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestPublishRates() {
+	testRates := `{"docType":"Rates","base":"AUD","date":"2017-08-14","rates":{"HKD":6.1484,"NZD":1.0793,"SGD":1.0729}}`
+	rates, err := suite.stub.MockInvoke("t1", "PublishRates", []string{testRates})
+	suite.Nil(err)
+	suite.Equal(testRates, string(rates))
+	key, _ := suite.cc.createCompositeKey(model.RatesObjectType, []string{"AUD", "2017-08-14"})
+	suite.checkState(key, testRates)
+	key, _ = suite.cc.createCompositeKey(model.LatestRatesObjectType, []string{"AUD"})
+	suite.checkState(key, `{"docType":"LatestRates","base":"AUD","date":"2017-08-14"}`)
+}
+
+func (suite *ChaincodeSuite) TestPublishRatesDuplicate() {
+	suite.publishTestRates()
+	suite.now = time.Date(2017, 8, 17, 12, 0, 0, 0, time.UTC)
+	_, err := suite.stub.MockInvoke("t4", "PublishRates", []string{`{"base":"AUD","date":"2017-08-17","rates":{"NZD":1.0790}}`})
+	suite.Equal("Exchange rates for base AUD on 2017-08-17 already published", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestPublishRatesBackDated() {
+	suite.publishTestRates()
+	suite.now = time.Date(2017, 8, 17, 12, 0, 0, 0, time.UTC)
+	_, err := suite.stub.MockInvoke("t4", "PublishRates", []string{`{"base":"AUD","date":"2017-08-16","rates":{"NZD":1.0790}}`})
+	suite.Equal("Cannot publish exchange rates for base AUD on 2017-08-16 before latest rates published on 2017-08-17", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestPublishRatesFutureDated() {
+	_, err := suite.stub.MockInvoke("t1", "PublishRates", []string{`{"base":"AUD","date":"2071-08-16","rates":{"NZD":1.0790}}`})
+	suite.Equal("Cannot publish exchange rates for base AUD on 2071-08-16 after the transaction date 2017-08-15", err.Error())
+	_, err = suite.stub.MockInvoke("t2", "PublishRates", []string{`{"base":"AUD","date":"2017-08-15","rates":{"NZD":1.0790}}`})
+	suite.Nil(err, "Rates can be published for the transaction date")
+}
+
+func (suite *ChaincodeSuite) TestGetRates() {
+	suite.publishTestRates()
+	ratesBytes, err := suite.stub.MockInvoke("t4", "GetRates", []string{"AUD", "2017-08-15"})
+	suite.Nil(err)
+	rates := new(model.Rates)
+	json.Unmarshal(ratesBytes, rates)
+	suite.Equal("2017-08-15", rates.Date)
+}
+
+func (suite *ChaincodeSuite) TestGetRatesUsesPreviousDate() {
+	suite.publishTestRates()
+	ratesBytes, err := suite.stub.MockInvoke("t4", "GetRates", []string{"AUD", "2017-08-16"})
+	suite.Nil(err)
+	rates := new(model.Rates)
+	json.Unmarshal(ratesBytes, rates)
+	suite.Equal("2017-08-15", rates.Date)
+}
+
+func (suite *ChaincodeSuite) TestGetRatesAfterLatest() {
+	suite.publishTestRates()
+	ratesBytes, err := suite.stub.MockInvoke("t4", "GetRates", []string{"AUD", "2018-01-31"})
+	suite.Nil(err)
+	rates := new(model.Rates)
+	json.Unmarshal(ratesBytes, rates)
+	suite.Equal("2017-08-17", rates.Date)
+}
+
+func (suite *ChaincodeSuite) TestGetRatesLooksBackAWeek() {
+	suite.publishRates("t1", `{"base":"AUD","date":"2017-08-01","rates":{"NZD":1.0793}}`)
+	suite.publishRates("t2", `{"base":"AUD","date":"2017-08-08","rates":{"NZD":1.0801}}`)
+	suite.publishRates("t3", `{"base":"AUD","date":"2017-08-20","rates":{"NZD":1.0788}}`)
+
+	ratesBytes, err := suite.stub.MockInvoke("t4", "GetRates", []string{"AUD", "2017-08-15"})
+	suite.Nil(err)
+	rates := new(model.Rates)
+	json.Unmarshal(ratesBytes, rates)
+	suite.Equal("2017-08-08", rates.Date)
+	_, err = suite.stub.MockInvoke("t5", "GetRates", []string{"AUD", "2017-08-16"})
+	suite.Equal("No exchange rates available for base AUD on 2017-08-16", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestGetRatesNotAvailable() {
+	suite.publishTestRates()
+	_, err := suite.stub.MockInvoke("t4", "GetRates", []string{"AUD", "2017-08-13"})
+	suite.Equal("No exchange rates available for base AUD on 2017-08-13", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestGetLatestRates() {
+	suite.publishTestRates()
+	ratesBytes, err := suite.stub.MockInvoke("t4", "GetLatestRates", []string{"AUD"})
+	suite.Nil(err)
+	rates := new(model.Rates)
+	json.Unmarshal(ratesBytes, rates)
+	suite.Equal("2017-08-17", rates.Date)
+}
+
+func (suite *ChaincodeSuite) TestGetLatestRatesNotAvailable() {
+	_, err := suite.stub.MockInvoke("t1", "GetLatestRates", []string{"NZD"})
+	suite.Equal("No exchange rates available for base NZD", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestGetRatesHistory() {
+	suite.publishTestRates()
+	history, err := suite.stub.MockInvoke("t4", "GetRatesHistory", []string{"AUD", "2017-08-15", "2017-08-17"})
+	suite.Nil(err)
+	ratesList := new(model.RatesList)
+	json.Unmarshal(history, ratesList)
+	suite.Equal(2, len(ratesList.Rates))
+	suite.Equal("2017-08-15", ratesList.Rates[0].Date)
+	suite.Equal("2017-08-17", ratesList.Rates[1].Date)
+}
+
+func (suite *ChaincodeSuite) TestRatesConfigValidation() {
+	_, err := suite.stub.MockInvoke("t1", "SetRatesConfig", []string{})
+	suite.Equal("Missing required rates config JSON", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "SetRatesConfig", []string{`{"reference_currency":"XYZ"}`})
+	suite.Equal("Error creating rates config. Error: Invalid reference currency code XYZ", err.Error())
+	config, err := suite.stub.MockInvoke("t1", "GetRatesConfig", []string{})
+	suite.Nil(err)
+	suite.Equal(`{"docType":"RatesConfig"}`, string(config))
+}
+
+func (suite *ChaincodeSuite) TestCrossRateRequiresReferenceCurrency() {
+	suite.publishTestRates()
+	suite.openAccount("1", "1234", "NZ", "NZD", 1000)
+	suite.openAccount("2", "5678", "SG", "SGD", 0)
+
+	transfer := `{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"NZD","amount":1000}`
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	failed := new(model.Transfer)
+	json.Unmarshal(res, failed)
+	suite.Equal(model.RatesUnavailable, failed.FailureCode)
+	suite.Equal("No exchange rates available for base NZD on 2017-08-15", failed.FailureReason)
+
+	config, err := suite.stub.MockInvoke(suite.nextTxID(), "SetRatesConfig", []string{`{"reference_currency":"AUD"}`})
+	suite.Nil(err)
+	suite.Equal(`{"docType":"RatesConfig","reference_currency":"AUD"}`, string(config))
+	suite.transferMoney("1", "1234", "2", "5678", "NZD", 1000)
+	suite.Equal(int64(994), suite.getAccount("2", "5678").Balance)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/mschimk1/passport-chaincode/currency"
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// ReverseTransfer refunds a completed transfer in full or, if an amount is given, in part.
+// The reversal is recorded as a transfer of its own, linked to the original transfer.
+// A rejected reversal is returned as a failed transfer, not as an error, so its failure record is kept.
+func (cc *Chaincode) ReverseTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ReverseTransfer with args %v", args)
+
+	if len(args) < 1 || len(args) > 2 {
+		return nil, errors.New("Missing required transfer ID")
+	}
+
+	state := newTxState(stub)
+	original, err := cc.getTransfer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	amount := original.Amount - original.Reversed
+	if len(args) == 2 {
+		if amount, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("Error parsing amount value %s", args[1])
+		}
+	}
+	if err := original.ValidateReversal(amount); err != nil {
+		return nil, err
+	}
+	credit, err := cc.getCreditLeg(state, original)
+	if err != nil {
+		return nil, err
+	}
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	reversal, conversion, err := model.CreateReversal(src, original, credit, amount)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("Reversing %s of transfer %s", currency.Format(amount, original.CurrencyCode), original.ID)
+
+	if err := cc.reverse(state, src, original, reversal, conversion, amount); err != nil {
+		return cc.failTransfer(stub, src, reversal, err)
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(reversal)
+}
+
+// reverse stages the account updates and transactions of a reversal and updates the original transfer
+func (cc *Chaincode) reverse(state *txState, src model.Source, original *model.Transfer, reversal *model.Transfer, conversion *model.Conversion, amount int64) error {
+	payee, err := cc.getAccount(state, reversal.FromCustomerID, reversal.FromAccountID)
+	if err != nil {
+		return err
+	}
+	payer, err := cc.getAccount(state, reversal.ToCustomerID, reversal.ToAccountID)
+	if err != nil {
+		return err
+	}
+	if payee.Closed {
+		return newTransferError(model.AccountClosed, payee, conversion, "Cannot reverse transfer %s, account %s is closed", original.ID, payee.ID)
+	}
+	if payer.Closed {
+		return newTransferError(model.AccountClosed, payer, conversion, "Cannot reverse transfer %s, account %s is closed", original.ID, payer.ID)
+	}
+	if err := cc.expireHolds(state, src, payee); err != nil {
+		return err
+	}
+	if payee.Available()-reversal.Amount < 0 {
+		return newTransferError(payee.FundsFailureCode(), payee, conversion, "Insufficient funds available in account %s to reverse transfer %s", payee.ID, original.ID)
+	}
+
+	if err := cc.debitAccount(state, payee, reversal.Amount); err != nil {
+		return err
+	}
+	debit, err := cc.recordTransaction(state, src, payee.CustomerID, payee.ID, reversal, conversion, "", model.Debited)
+	if err != nil {
+		return err
+	}
+	reversal.AddLeg(debit)
+	if err := cc.creditAccount(state, payer, amount); err != nil {
+		return err
+	}
+	credit, err := cc.recordTransaction(state, src, payer.CustomerID, payer.ID, reversal, conversion, "", model.Credited)
+	if err != nil {
+		return err
+	}
+	reversal.AddLeg(credit)
+	reversal.Complete()
+	if err := cc.putTransfer(state, reversal); err != nil {
+		return err
+	}
+	original.ApplyReversal(reversal, amount)
+	return cc.putTransfer(state, original)
+}
+
+// getCreditLeg reads the transaction crediting the payee of a transfer
+func (cc *Chaincode) getCreditLeg(state *txState, t *model.Transfer) (*model.Transaction, error) {
+	for _, leg := range t.Legs {
+		if leg.Status != model.Credited {
+			continue
+		}
+		key, err := cc.createCompositeKey(model.TransactionObjectType, []string{leg.CustomerID, leg.AccountID, leg.TransactionID})
+		if err != nil {
+			return nil, err
+		}
+		txn := new(model.Transaction)
+		found, err := state.getObject(key, txn)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return txn, nil
+		}
+	}
+	return nil, fmt.Errorf("Credited transaction of transfer %s not found", t.ID)
+}
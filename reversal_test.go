@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// nextTxID returns a unique transaction ID, transfers and transactions derive their IDs from it
+func (suite *ChaincodeSuite) nextTxID() string {
+	suite.txCount++
+	return fmt.Sprintf("tx%d", suite.txCount)
+}
+
+// transferMoney makes a transfer between two accounts and returns it
+func (suite *ChaincodeSuite) transferMoney(fromCustomerID string, fromAccountID string, toCustomerID string, toAccountID string, currencyCode string, amount int64) *model.Transfer {
+	t := &model.Transfer{FromCustomerID: fromCustomerID, FromAccountID: fromAccountID, ToCustomerID: toCustomerID, ToAccountID: toAccountID, CurrencyCode: currencyCode, Amount: amount}
+	data, _ := json.Marshal(t)
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{string(data)})
+	suite.Nil(err)
+	json.Unmarshal(res, t)
+	return t
+}
+
+// getLeg returns the transaction of a transfer leg
+func (suite *ChaincodeSuite) getLeg(leg model.TransferLeg) *model.Transaction {
+	res, err := suite.stub.MockInvoke("t0", "GetTransaction", []string{leg.CustomerID, leg.AccountID, leg.TransactionID})
+	suite.Nil(err)
+	txn := new(model.Transaction)
+	json.Unmarshal(res, txn)
+	return txn
+}
+
+// getFailedTransaction returns the failed transaction of an account
+func (suite *ChaincodeSuite) getFailedTransaction(customerID string, accountID string) *model.Transaction {
+	for _, txn := range suite.getTransactions(customerID, accountID) {
+		if txn.Status == model.Failed {
+			return txn
+		}
+	}
+	suite.Fail("No failed transaction in account " + accountID)
+	return nil
+}
+
+// reverseTransfer reverses a transfer and returns the reversal
+func (suite *ChaincodeSuite) reverseTransfer(args ...string) (*model.Transfer, error) {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "ReverseTransfer", args)
+	if err != nil {
+		return nil, err
+	}
+	reversal := new(model.Transfer)
+	json.Unmarshal(res, reversal)
+	return reversal, nil
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferValidation() {
+	_, err := suite.reverseTransfer()
+	suite.Equal("Missing required transfer ID", err.Error())
+	_, err = suite.reverseTransfer("unknown")
+	suite.Equal("Transfer with ID unknown not found", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferInFull() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	original := suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+
+	reversal, err := suite.reverseTransfer(original.ID)
+	suite.Nil(err)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(0), suite.getAccount("2", "5678").Balance)
+
+	suite.Equal(model.TransferCompleted, reversal.Status)
+	suite.Equal(original.ID, reversal.OriginalID)
+	suite.Equal("2", reversal.FromCustomerID)
+	suite.Equal("1", reversal.ToCustomerID)
+	suite.Equal(int64(400), reversal.Amount)
+	suite.Equal(2, len(reversal.Legs))
+	debit := suite.getLeg(reversal.Legs[0])
+	suite.Equal(reversal.ID, debit.TransferID)
+	suite.Equal(model.Debited, debit.Status)
+	suite.Equal("5678", debit.AccountID)
+	credit := suite.getLeg(reversal.Legs[1])
+	suite.Equal(model.Credited, credit.Status)
+	suite.Equal("1234", credit.AccountID)
+
+	original = suite.getTransfer(original.ID)
+	suite.Equal(model.TransferReversed, original.Status)
+	suite.Equal(int64(400), original.Reversed)
+	suite.Equal([]string{reversal.ID}, original.Reversals)
+
+	_, err = suite.reverseTransfer(original.ID)
+	suite.Equal("Cannot reverse transfer "+original.ID+" with status reversed", err.Error())
+	_, err = suite.reverseTransfer(reversal.ID)
+	suite.Equal("Cannot reverse transfer "+reversal.ID+", it is a reversal of transfer "+original.ID, err.Error())
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferPartially() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	original := suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+
+	_, err := suite.reverseTransfer(original.ID, "100")
+	suite.Nil(err)
+	suite.Equal(int64(700), suite.getAccount("1", "1234").Balance)
+	suite.Equal(model.TransferPartiallyReversed, suite.getTransfer(original.ID).Status)
+
+	_, err = suite.reverseTransfer(original.ID, "301")
+	suite.Equal("Cannot reverse 3.01 AUD of transfer "+original.ID+", only 3.00 AUD can be reversed", err.Error())
+	_, err = suite.reverseTransfer(original.ID, "0")
+	suite.Equal("Invalid reversal amount 0", err.Error())
+	_, err = suite.reverseTransfer(original.ID, "abc")
+	suite.Equal("Error parsing amount value abc", err.Error())
+
+	_, err = suite.reverseTransfer(original.ID)
+	suite.Nil(err)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+	original = suite.getTransfer(original.ID)
+	suite.Equal(model.TransferReversed, original.Status)
+	suite.Equal(2, len(original.Reversals))
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferCrossCurrency() {
+	suite.publishTestRates()
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "NZ", "NZD", 0)
+	original := suite.transferMoney("1", "1234", "2", "5678", "AUD", 1000)
+	suite.Equal(int64(1080), suite.getAccount("2", "5678").Balance)
+
+	reversal, err := suite.reverseTransfer(original.ID, "333")
+	suite.Nil(err)
+	suite.Equal(int64(360), reversal.Amount)
+	suite.Equal("NZD", reversal.CurrencyCode)
+	credit := suite.getLeg(reversal.Legs[1])
+	suite.Equal(&model.Conversion{Rate: model.MustParseDecimal("0.9258402"), SourceCurrency: "NZD", SourceAmount: 360, DestinationCurrency: "AUD", DestinationAmount: 333}, credit.Conversion)
+	suite.Equal(int64(333), credit.Amount)
+
+	// the shares of the partial reversals add up to the credited amount
+	reversal, err = suite.reverseTransfer(original.ID)
+	suite.Nil(err)
+	suite.Equal(int64(720), reversal.Amount)
+	suite.Equal(int64(0), suite.getAccount("2", "5678").Balance)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferClosedPayeeAccount() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	original := suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+	suite.stub.MockInvoke("t1", "CloseAccount", []string{"2", "5678"})
+
+	res, err := suite.reverseTransfer(original.ID)
+	suite.Nil(err)
+	suite.Equal("Cannot reverse transfer "+original.ID+", account 5678 is closed", res.FailureReason)
+	suite.Equal(int64(600), suite.getAccount("1", "1234").Balance)
+	suite.Equal(model.TransferCompleted, suite.getTransfer(original.ID).Status)
+
+	failed := suite.getFailedTransaction("2", "5678")
+	suite.Equal(model.AccountClosed, failed.FailureCode)
+	reversal := suite.getTransfer(failed.TransferID)
+	suite.Equal(model.TransferFailed, reversal.Status)
+	suite.Equal(original.ID, reversal.OriginalID)
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferUnderfundedPayeeAccount() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.openAccount("3", "9012", "AU", "AUD", 0)
+	original := suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+	suite.transferMoney("2", "5678", "3", "9012", "AUD", 300)
+
+	res, err := suite.reverseTransfer(original.ID)
+	suite.Nil(err)
+	suite.Equal("Insufficient funds available in account 5678 to reverse transfer "+original.ID, res.FailureReason)
+	suite.Equal(model.InsufficientFunds, suite.getFailedTransaction("2", "5678").FailureCode)
+
+	_, err = suite.reverseTransfer(original.ID, "100")
+	suite.Nil(err)
+	suite.Equal(int64(0), suite.getAccount("2", "5678").Balance)
+}
+
+func (suite *ChaincodeSuite) TestReverseTransferKeepsFee() {
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	original := suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+
+	_, err := suite.reverseTransfer(original.ID)
+	suite.Nil(err)
+	suite.Equal(int64(950), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(50), suite.getAccount("bank", "fees").Balance)
+}
+
+func (suite *ChaincodeSuite) TestReverseFailedTransfer() {
+	suite.openAccount("1", "1234", "AU", "AUD", 100)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "TransferMoney", []string{`{"from_customer":"1", "from_account":"1234", "to_customer":"2", "to_account":"5678", "currency":"AUD", "amount":400}`})
+	failed := suite.getTransactions("1", "1234")[0]
+
+	_, err := suite.reverseTransfer(failed.TransferID)
+	suite.Equal("Cannot reverse transfer "+failed.TransferID+" with status failed", err.Error())
+}
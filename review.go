@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// SetReviewRules stores the rules flagging transfers for compliance review, replacing
+// any previous rules. Only compliance officers can set review rules.
+func (cc *Chaincode) SetReviewRules(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetReviewRules with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required review rules JSON")
+	}
+	policy, err := model.CreateReviewPolicy([]byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when creating review rules. Error: %s", err)
+		return nil, fmt.Errorf("Error creating review rules. Error: %s", err)
+	}
+	key, err := cc.createCompositeKey(model.ReviewPolicyObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	policyData, err := state.putObject(key, policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return policyData, nil
+}
+
+// GetReviewRules query the current review rules
+func (cc *Chaincode) GetReviewRules(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetReviewRules with args %v", args)
+
+	key, err := cc.createCompositeKey(model.ReviewPolicyObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	policyBytes, err := stub.GetState(key)
+	if err != nil {
+		logger.Errorf("Failed to get review rules. Error: %s", err)
+		return nil, err
+	}
+	if policyBytes == nil {
+		return nil, errors.New("No review rules available")
+	}
+	return policyBytes, nil
+}
+
+// ApproveTransfer approves a transfer pending review or approval. Args are the transfer ID
+// and, for a transfer pending review, the reason for the decision.
+//
+// A transfer pending review is approved by a compliance officer and executed. It is not
+// screened again, other checks such as funds and limits apply as usual; if they fail the
+// transfer fails.
+//
+// A transfer pending approval is approved by one of the approvers of the payer account,
+// each approver counts once. The transfer is executed as soon as the number of approvals
+// required by the account's approval policy is reached. A transfer whose approval deadline
+// has passed fails with the approval_expired failure code instead and is returned as such.
+//
+// Transfers rejected when they are executed are returned with status failed, not as an
+// error, as for TransferMoney.
+func (cc *Chaincode) ApproveTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ApproveTransfer with args %v", args)
+
+	if len(args) == 0 || len(args) > 2 {
+		return nil, errors.New("Missing required transfer ID and / or review reason")
+	}
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	t, err := cc.getTransfer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if t.Status == model.TransferPendingApproval {
+		if t.Approval.Expired(src.Now()) {
+			if err := cc.expireApproval(state, src, t); err != nil {
+				return nil, err
+			}
+			if err := state.commit(); err != nil {
+				return nil, err
+			}
+			return json.Marshal(t)
+		}
+		if err := cc.addApproval(state, src, t); err != nil {
+			return nil, err
+		}
+		if t.Status == model.TransferPendingApproval {
+			if err := cc.putTransfer(state, t); err != nil {
+				return nil, err
+			}
+			if err := state.commit(); err != nil {
+				return nil, err
+			}
+			return json.Marshal(t)
+		}
+	} else {
+		if err := cc.requireRole(stub, ComplianceRole); err != nil {
+			return nil, err
+		}
+		reason := ""
+		if len(args) == 2 {
+			reason = args[1]
+		}
+		if err := t.Approve(reason, src.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if err := cc.endPending(state, t); err != nil {
+		return nil, err
+	}
+	if err := cc.transfer(state, src, t); err != nil {
+		return cc.failPending(stub, src, t, err)
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// RejectTransfer rejects a transfer pending review, releasing its funds. Args are the
+// transfer ID and the reason for the decision. The transfer fails with the
+// review_rejected failure code.
+func (cc *Chaincode) RejectTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering RejectTransfer with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required transfer ID and / or review reason")
+	}
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	t, err := cc.getTransfer(state, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Reject(args[1], src.Now()); err != nil {
+		return nil, err
+	}
+	if err := cc.endPending(state, t); err != nil {
+		return nil, err
+	}
+	payer, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	rejection := newTransferError(model.RejectedInReview, payer, nil, "Transfer %s rejected in review", t.ID)
+	if err := cc.stageFailure(state, src, t, rejection); err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// ListPendingReviews query the transfers pending review, restricted to compliance officers
+func (cc *Chaincode) ListPendingReviews(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ListPendingReviews with args %v", args)
+
+	keysIter, err := cc.partialCompositeKeyQuery(stub, model.PendingReviewObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer keysIter.Close()
+
+	state := newTxState(stub)
+	list := model.TransferList{Transfers: []*model.Transfer{}}
+	for keysIter.HasNext() {
+		_, pendingBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		pending := new(model.PendingReview)
+		if err := bytesToStruct(pendingBytes, pending); err != nil {
+			return nil, err
+		}
+		t, err := cc.getTransfer(state, pending.TransferID)
+		if err != nil {
+			return nil, err
+		}
+		list.Transfers = append(list.Transfers, t)
+	}
+	return json.Marshal(&list)
+}
+
+// holdForReview checks a new transfer against the watch-list and the review rules. A
+// flagged transfer is stored pending review with its amount plus fee reserved by a
+// hold. Returns true if the transfer has been held for review.
+func (cc *Chaincode) holdForReview(state *txState, src model.Source, t *model.Transfer, from *model.Account, credits []*payeeCredit) (bool, error) {
+	hit, err := cc.screenTransfer(state, t, from, credits)
+	if err != nil {
+		return false, err
+	}
+	flags, err := cc.reviewFlags(state, src, t, from, credits)
+	if err != nil {
+		return false, err
+	}
+	if hit == nil && len(flags) == 0 {
+		return false, nil
+	}
+
+	hold, err := cc.reserveFunds(state, src, t, from, "review")
+	if err != nil {
+		return false, err
+	}
+	t.Screening = hit
+	t.Flags = flags
+	t.HoldForReview(hold.ID)
+	key, err := cc.createCompositeKey(model.PendingReviewObjectType, []string{t.ID})
+	if err != nil {
+		return false, err
+	}
+	if _, err := state.putObject(key, &model.PendingReview{Entity: model.Entity{ObjectType: model.PendingReviewObjectType}, TransferID: t.ID}); err != nil {
+		return false, err
+	}
+	return true, cc.putTransfer(state, t)
+}
+
+// reviewFlags returns the flags raised by the review rules for a transfer. A rule
+// applies if the corridor to any payee matches, it raises at most one flag.
+func (cc *Chaincode) reviewFlags(state *txState, src model.Source, t *model.Transfer, from *model.Account, credits []*payeeCredit) ([]model.ReviewFlag, error) {
+	key, err := cc.createCompositeKey(model.ReviewPolicyObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(model.ReviewPolicy)
+	if found, err := state.getObject(key, policy); err != nil || !found {
+		return nil, err
+	}
+	var flags []model.ReviewFlag
+	for _, rule := range policy.Rules {
+		for _, c := range credits {
+			if ok, _ := rule.Corridor.Matches(accountCorridor(from, c.account)); !ok {
+				continue
+			}
+			if flag := rule.Flag(t.Amount, src.Now(), from, c.account); flag != nil {
+				flags = append(flags, *flag)
+				break
+			}
+		}
+	}
+	return flags, nil
+}
+
+// endPending releases the hold of a transfer pending review or approval and removes it
+// from the review and approval queues
+func (cc *Chaincode) endPending(state *txState, t *model.Transfer) error {
+	account, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return err
+	}
+	hold, err := cc.getHold(state, t.FromCustomerID, t.FromAccountID, t.HoldID)
+	if err != nil {
+		return err
+	}
+	if err := cc.releaseHold(state, account, hold, model.HoldReleased); err != nil {
+		return err
+	}
+	if _, err := cc.putAccount(state, account); err != nil {
+		return err
+	}
+	if _, err := cc.putHold(state, hold); err != nil {
+		return err
+	}
+	if t.Review != nil {
+		key, err := cc.createCompositeKey(model.PendingReviewObjectType, []string{t.ID})
+		if err != nil {
+			return err
+		}
+		state.delState(key)
+	}
+	if t.Approval != nil {
+		key, err := cc.createCompositeKey(model.PendingApprovalObjectType, []string{t.ID})
+		if err != nil {
+			return err
+		}
+		state.delState(key)
+	}
+	return nil
+}
+
+// reserveFunds places a hold reserving the amount plus fee of a transfer pending review or
+// approval, the reason
+func (cc *Chaincode) reserveFunds(state *txState, src model.Source, t *model.Transfer, from *model.Account, reason string) (*model.Hold, error) {
+	if err := cc.expireHolds(state, src, from); err != nil {
+		return nil, err
+	}
+	if from.Available()-(t.Amount+t.Fee) < 0 {
+		return nil, newTransferError(from.FundsFailureCode(), from, nil, "Insufficient funds available in account %s", from.ID)
+	}
+	hold := model.CreatePendingHold(src, t, reason)
+	from.Held += hold.Amount
+	if _, err := cc.putAccount(state, from); err != nil {
+		return nil, err
+	}
+	if _, err := cc.putHold(state, hold); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// failPending records the failure of an approved transfer rejected with a TransferError,
+// releasing its hold and removing it from the review and approval queues, and returns the
+// failed transfer. Other errors are returned as they are.
+func (cc *Chaincode) failPending(stub shim.ChaincodeStubInterface, src model.Source, t *model.Transfer, err error) ([]byte, error) {
+	if _, ok := err.(*TransferError); !ok {
+		return nil, err
+	}
+	logger.Infof("Transfer %s failed. Error: %s", t.ID, err)
+	state := newTxState(stub)
+	if err := cc.endPending(state, t); err != nil {
+		return nil, err
+	}
+	if err := cc.stageFailure(state, src, t, err); err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
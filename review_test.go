@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// setReviewRules sets the review rules as a compliance officer
+func (suite *ChaincodeSuite) setReviewRules(rules string) {
+	suite.asRole(ComplianceRole)
+	defer suite.asRole(BankOperatorRole)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "SetReviewRules", []string{rules})
+	suite.Nil(err)
+}
+
+// review approves or rejects a transfer as a compliance officer
+func (suite *ChaincodeSuite) review(function string, transferID string, reason string) (*model.Transfer, error) {
+	suite.asRole(ComplianceRole)
+	defer suite.asRole(BankOperatorRole)
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), function, []string{transferID, reason})
+	if err != nil {
+		return nil, err
+	}
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	return t, nil
+}
+
+// listPendingReviews returns the transfers pending review
+func (suite *ChaincodeSuite) listPendingReviews() []*model.Transfer {
+	suite.asRole(ComplianceRole)
+	defer suite.asRole(BankOperatorRole)
+	res, err := suite.stub.MockInvoke("t0", "ListPendingReviews", []string{})
+	suite.Nil(err)
+	list := new(model.TransferList)
+	json.Unmarshal(res, list)
+	return list.Transfers
+}
+
+const testReviewRules = `{"rules":[` +
+	`{"id":"large","type":"amount","from_currency":"AUD","min_amount":50000},` +
+	`{"id":"high-risk","type":"country","countries":["IR","KP"]}]}`
+
+func (suite *ChaincodeSuite) setupReview() *model.Transfer {
+	suite.openAccount("1", "1234", "AU", "AUD", 100000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.setReviewRules(testReviewRules)
+	return suite.transferMoney("1", "1234", "2", "5678", "AUD", 60000)
+}
+
+func (suite *ChaincodeSuite) TestReviewRulesValidation() {
+	_, err := suite.stub.MockInvoke("t1", "SetReviewRules", []string{testReviewRules})
+	suite.Equal("Caller is not authorised, role compliance_officer is required", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "ApproveTransfer", []string{"t1", "ok"})
+	suite.Equal("Caller is not authorised, one of roles compliance_officer, customer is required", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "ListPendingReviews", []string{})
+	suite.Equal("Caller is not authorised, role compliance_officer is required", err.Error())
+	suite.asRole(ComplianceRole)
+	_, err = suite.stub.MockInvoke("t1", "SetReviewRules", []string{})
+	suite.Equal("Missing required review rules JSON", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "GetReviewRules", []string{})
+	suite.Equal("No review rules available", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestTransferFlaggedForReview() {
+	t := suite.setupReview()
+	suite.Equal(model.TransferPendingReview, t.Status)
+	suite.Equal([]model.ReviewFlag{{RuleID: "large", Reason: "Amount 600.00 AUD reaches the review threshold of 500.00 AUD"}}, t.Flags)
+	suite.Nil(t.Screening)
+	suite.Equal(int64(60000), suite.getBalance("1", "1234").Held)
+
+	// transfers not matching any rule are executed immediately
+	suite.Equal(model.TransferCompleted, suite.transferMoney("1", "1234", "2", "5678", "AUD", 100).Status)
+
+	pending := suite.listPendingReviews()
+	suite.Equal(1, len(pending))
+	suite.Equal(t.ID, pending[0].ID)
+}
+
+func (suite *ChaincodeSuite) TestApproveTransfer() {
+	t := suite.setupReview()
+	_, err := suite.review("ApproveTransfer", t.ID, "")
+	suite.Equal("Missing required review reason", err.Error())
+
+	approved, err := suite.review("ApproveTransfer", t.ID, "Invoice verified")
+	suite.Nil(err)
+	suite.Equal(model.TransferCompleted, approved.Status)
+	suite.Equal(model.ReviewApproved, approved.Review.Outcome)
+	suite.Equal("Invoice verified", approved.Review.Reason)
+	suite.Equal(2, len(approved.Legs))
+
+	balance := suite.getBalance("1", "1234")
+	suite.Equal(int64(40000), balance.Balance)
+	suite.Equal(int64(0), balance.Held)
+	suite.Equal(int64(60000), suite.getAccount("2", "5678").Balance)
+	suite.Equal(0, len(suite.listPendingReviews()))
+
+	_, err = suite.review("ApproveTransfer", t.ID, "again")
+	suite.Equal("Transfer "+t.ID+" is not pending review", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestRejectTransfer() {
+	t := suite.setupReview()
+	rejected, err := suite.review("RejectTransfer", t.ID, "Source of funds not explained")
+	suite.Nil(err)
+	suite.Equal(model.TransferFailed, rejected.Status)
+	suite.Equal(model.RejectedInReview, rejected.FailureCode)
+	suite.Equal(model.ReviewRejected, rejected.Review.Outcome)
+
+	balance := suite.getBalance("1", "1234")
+	suite.Equal(int64(100000), balance.Balance)
+	suite.Equal(int64(0), balance.Held)
+	suite.Equal(model.RejectedInReview, suite.getFailedTransaction("1", "1234").FailureCode)
+	suite.Equal(0, len(suite.listPendingReviews()))
+	suite.Equal(model.TransferFailed, suite.getTransfer(t.ID).Status)
+}
+
+func (suite *ChaincodeSuite) TestApprovedTransferFailing() {
+	t := suite.setupReview()
+	suite.stub.MockInvoke("t1", "CloseAccount", []string{"2", "5678"})
+
+	res, err := suite.review("ApproveTransfer", t.ID, "Invoice verified")
+	suite.Nil(err)
+	suite.Equal("Cannot transfer money into closed account 5678", res.FailureReason)
+	failed := suite.getTransfer(t.ID)
+	suite.Equal(model.TransferFailed, failed.Status)
+	suite.Equal(model.AccountClosed, failed.FailureCode)
+	suite.Equal(model.ReviewApproved, failed.Review.Outcome)
+	suite.Equal(int64(0), suite.getBalance("1", "1234").Held)
+	suite.Equal(0, len(suite.listPendingReviews()))
+}
+
+func (suite *ChaincodeSuite) TestHighRiskCountryReview() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "IR", "AUD", 0)
+	suite.setReviewRules(testReviewRules)
+	t := suite.transferMoney("1", "1234", "2", "5678", "AUD", 100)
+	suite.Equal(model.TransferPendingReview, t.Status)
+	suite.Equal("high-risk", t.Flags[0].RuleID)
+}
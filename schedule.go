@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// ScheduleTransfer stores an instruction to make a future dated or recurring transfer.
+// The transfers are made by ExecuteDueTransfers, as long as the customer scheduling them
+// keeps full permission on the paying account.
+func (cc *Chaincode) ScheduleTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ScheduleTransfer with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required scheduled transfer JSON")
+	}
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := model.CreateScheduledTransfer(src, []byte(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating scheduled transfer. Error: %s", err)
+	}
+	state := newTxState(stub)
+	t := &schedule.Transfer
+	account, err := cc.getAccount(state, t.FromCustomerID, t.FromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Closed {
+		return nil, fmt.Errorf("Cannot schedule transfer from closed account %s", account.ID)
+	}
+	if t.CurrencyCode != account.CurrencyCode {
+		return nil, fmt.Errorf("Transfer currency %s does not match currency %s of account %s", t.CurrencyCode, account.CurrencyCode, account.ID)
+	}
+	id, err := cc.caller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if id.Role == CustomerRole {
+		schedule.CreatedBy = id.CustomerID
+	}
+	scheduleData, err := cc.putScheduledTransfer(state, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return scheduleData, nil
+}
+
+// CancelScheduledTransfer cancels the transfers still to come of a scheduled transfer
+func (cc *Chaincode) CancelScheduledTransfer(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering CancelScheduledTransfer with args %v", args)
+
+	if len(args) != 2 {
+		return nil, errors.New("Missing required customer ID and / or scheduled transfer ID")
+	}
+
+	state := newTxState(stub)
+	schedule, err := cc.getScheduledTransfer(state, args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.unindexScheduledTransfer(state, schedule); err != nil {
+		return nil, err
+	}
+	if err := schedule.Cancel(); err != nil {
+		return nil, err
+	}
+	scheduleData, err := cc.putScheduledTransfer(state, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return scheduleData, nil
+}
+
+// getScheduledTransfer reads a scheduled transfer of a customer
+func (cc *Chaincode) getScheduledTransfer(state *txState, customerID string, scheduleID string) (*model.ScheduledTransfer, error) {
+	key, err := cc.createCompositeKey(model.ScheduledTransferObjectType, []string{customerID, scheduleID})
+	if err != nil {
+		return nil, err
+	}
+	schedule := new(model.ScheduledTransfer)
+	found, err := state.getObject(key, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Scheduled transfer with ID %s not found", scheduleID)
+	}
+	return schedule, nil
+}
+
+// ListScheduledTransfers returns the scheduled transfers of a customer
+func (cc *Chaincode) ListScheduledTransfers(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ListScheduledTransfers with args %v", args)
+
+	if len(args) != 1 {
+		return nil, errors.New("Missing required customer ID")
+	}
+
+	schedules, err := cc.getScheduledTransfers(stub, []string{args[0]})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&model.ScheduledTransferList{ScheduledTransfers: schedules})
+}
+
+// ExecuteDueTransfers makes all scheduled transfers due at the transaction timestamp,
+// including any missed earlier ones, through the normal transfer path. At most
+// MaxCatchUpRuns transfers of a scheduled transfer are made in one invocation, the
+// rest stay due for the next invocation. Each transfer
+// succeeds or fails on its own; a failed transfer is recorded like a failed TransferMoney
+// and is not retried. The outcomes are recorded on the scheduled transfers and returned.
+// Only active scheduled transfers that are due are read, through their due time index.
+func (cc *Chaincode) ExecuteDueTransfers(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering ExecuteDueTransfers with args %v", args)
+
+	src, err := cc.newSource(stub)
+	if err != nil {
+		return nil, err
+	}
+	dues, err := cc.getDueScheduledTransfers(stub, src.Now())
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	runs := &model.ScheduledRunList{Runs: []*model.ScheduledRun{}}
+	for _, due := range dues {
+		schedule, err := cc.getScheduledTransfer(state, due.CustomerID, due.ScheduleID)
+		if err != nil {
+			return nil, err
+		}
+		if err := cc.unindexScheduledTransfer(state, schedule); err != nil {
+			return nil, err
+		}
+		for n := 0; n < model.MaxCatchUpRuns && schedule.IsDue(src.Now()); n++ {
+			run, err := cc.executeScheduledTransfer(state, src, schedule)
+			if err != nil {
+				return nil, err
+			}
+			schedule.AddRun(run)
+			runs.Runs = append(runs.Runs, run)
+		}
+		if _, err := cc.putScheduledTransfer(state, schedule); err != nil {
+			return nil, err
+		}
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(runs)
+}
+
+// executeScheduledTransfer makes the next transfer of a scheduled transfer. The transfer is
+// staged in a child state, so a rejected transfer leaves no trace besides its failure record.
+// Errors are only returned if the outcome cannot be recorded.
+func (cc *Chaincode) executeScheduledTransfer(state *txState, src model.Source, schedule *model.ScheduledTransfer) (*model.ScheduledRun, error) {
+	due, _ := schedule.NextDue()
+	run := &model.ScheduledRun{ScheduleID: schedule.ID, Due: due.Unix()}
+	t := schedule.Transfer
+	t.Params = copyParams(schedule.Transfer.Params)
+	t.Payees = copyPayees(schedule.Transfer.Payees)
+	t.Escrow = copyEscrow(schedule.Transfer.Escrow)
+	t.Begin(src)
+	t.InitiatedBy = schedule.CreatedBy
+	run.TransferID = t.ID
+
+	child := state.child()
+	err := cc.checkScheduleCreator(child, schedule)
+	if err == nil {
+		err = cc.transfer(child, src, &t)
+	}
+	if err == nil {
+		run.Status = t.Status
+		return run, child.commit()
+	}
+	logger.Infof("Scheduled transfer %s due %s failed. Error: %s", schedule.ID, due, err)
+	run.Status = model.TransferFailed
+	run.Message = err.Error()
+	if _, ok := err.(*TransferError); !ok {
+		run.TransferID = "" // only transfers rejected with a TransferError are recorded
+	}
+	return run, cc.stageFailure(state, src, &t, err)
+}
+
+// checkScheduleCreator checks that the customer who scheduled a transfer still has full
+// permission on the paying account. Transfers scheduled by bank staff are not checked.
+func (cc *Chaincode) checkScheduleCreator(state *txState, schedule *model.ScheduledTransfer) error {
+	if schedule.CreatedBy == "" {
+		return nil
+	}
+	account, err := cc.getAccount(state, schedule.Transfer.FromCustomerID, schedule.Transfer.FromAccountID)
+	if err != nil {
+		return err
+	}
+	if !account.Allows(schedule.CreatedBy, model.FullPermission) {
+		return newTransferError(model.CustomerIneligible, account, nil, "Customer %s who scheduled the transfer is no longer authorised to pay from account %s", schedule.CreatedBy, account.ID)
+	}
+	return nil
+}
+
+// copyParams copies the params of a transfer instruction, so the transfers made for it do not share them
+func copyParams(params map[string]string) map[string]string {
+	if params == nil {
+		return nil
+	}
+	c := make(map[string]string, len(params))
+	for k, v := range params {
+		c[k] = v
+	}
+	return c
+}
+
+// copyPayees copies the payees of a transfer instruction, as their shares are set by the transfers made for it
+func copyPayees(payees []model.Payee) []model.Payee {
+	if payees == nil {
+		return nil
+	}
+	return append([]model.Payee(nil), payees...)
+}
+
+// copyEscrow copies the escrow condition of a transfer instruction, as its escrow account is set by the transfers made for it
+func copyEscrow(escrow *model.Escrow) *model.Escrow {
+	if escrow == nil {
+		return nil
+	}
+	c := *escrow
+	return &c
+}
+
+// getScheduledTransfers reads the scheduled transfers matching the given partial key attributes
+func (cc *Chaincode) getScheduledTransfers(stub shim.ChaincodeStubInterface, attributes []string) ([]*model.ScheduledTransfer, error) {
+	keysIter, err := cc.partialCompositeKeyQuery(stub, model.ScheduledTransferObjectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer keysIter.Close()
+
+	schedules := []*model.ScheduledTransfer{}
+	for keysIter.HasNext() {
+		_, scheduleBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		schedule := new(model.ScheduledTransfer)
+		if err := bytesToStruct(scheduleBytes, schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// putScheduledTransfer stages a write of the scheduled transfer and, while it has transfers
+// to come, of its due time index entry. The entry of the previous due time must have been
+// removed with unindexScheduledTransfer before the scheduled transfer was changed.
+func (cc *Chaincode) putScheduledTransfer(state *txState, schedule *model.ScheduledTransfer) ([]byte, error) {
+	key, err := cc.createCompositeKey(schedule.GetObjectType(), []string{schedule.CustomerID(), schedule.ID})
+	if err != nil {
+		return nil, err
+	}
+	if due, ok := schedule.NextDue(); ok {
+		dueKey, err := cc.scheduleDueKey(schedule, due)
+		if err != nil {
+			return nil, err
+		}
+		index := &model.ScheduledTransferDue{
+			Entity:     model.Entity{ObjectType: model.ScheduledTransferDueObjectType},
+			Due:        due.Unix(),
+			CustomerID: schedule.CustomerID(),
+			ScheduleID: schedule.ID,
+		}
+		if _, err := state.putObject(dueKey, index); err != nil {
+			return nil, err
+		}
+	}
+	return state.putObject(key, schedule)
+}
+
+// unindexScheduledTransfer stages the removal of the due time index entry of a scheduled transfer
+func (cc *Chaincode) unindexScheduledTransfer(state *txState, schedule *model.ScheduledTransfer) error {
+	due, ok := schedule.NextDue()
+	if !ok {
+		return nil
+	}
+	key, err := cc.scheduleDueKey(schedule, due)
+	if err != nil {
+		return err
+	}
+	state.delState(key)
+	return nil
+}
+
+// scheduleDueKey returns the due time index key of a scheduled transfer. Due times are zero
+// padded, so that the keys are ordered by due time.
+func (cc *Chaincode) scheduleDueKey(schedule *model.ScheduledTransfer, due time.Time) (string, error) {
+	return cc.createCompositeKey(model.ScheduledTransferDueObjectType, []string{formatDue(due), schedule.CustomerID(), schedule.ID})
+}
+
+// formatDue formats a due time as zero padded unix time
+func formatDue(due time.Time) string {
+	return fmt.Sprintf("%012d", due.Unix())
+}
+
+// indexScheduledTransfers adds the due time index entries of all active scheduled transfers,
+// for scheduled transfers stored before they were indexed
+func (cc *Chaincode) indexScheduledTransfers(stub shim.ChaincodeStubInterface) error {
+	schedules, err := cc.getScheduledTransfers(stub, nil)
+	if err != nil {
+		return err
+	}
+	state := newTxState(stub)
+	for _, schedule := range schedules {
+		if _, err := cc.putScheduledTransfer(state, schedule); err != nil {
+			return err
+		}
+	}
+	return state.commit()
+}
+
+// getDueScheduledTransfers reads the due time index entries of the scheduled transfers due at the given time
+func (cc *Chaincode) getDueScheduledTransfers(stub shim.ChaincodeStubInterface, now time.Time) ([]*model.ScheduledTransferDue, error) {
+	startKey, err := cc.createCompositeKey(model.ScheduledTransferDueObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := cc.createCompositeKey(model.ScheduledTransferDueObjectType, []string{formatDue(now)})
+	if err != nil {
+		return nil, err
+	}
+	keysIter, err := stub.RangeQueryState(startKey, endKey+string(utf8.MaxRune))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching rows: %s", err)
+	}
+	defer keysIter.Close()
+
+	dues := []*model.ScheduledTransferDue{}
+	for keysIter.HasNext() {
+		_, dueBytes, err := keysIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		due := new(model.ScheduledTransferDue)
+		if err := bytesToStruct(dueBytes, due); err != nil {
+			return nil, err
+		}
+		dues = append(dues, due)
+	}
+	return dues, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+const testInstruction = `"transfer":{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":300}`
+
+// scheduleTransfer stores a scheduled transfer and returns it
+func (suite *ChaincodeSuite) scheduleTransfer(schedule string) (*model.ScheduledTransfer, error) {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "ScheduleTransfer", []string{`{` + testInstruction + `,` + schedule + `}`})
+	if err != nil {
+		return nil, err
+	}
+	s := new(model.ScheduledTransfer)
+	json.Unmarshal(res, s)
+	return s, nil
+}
+
+// executeDueTransfers runs the due scheduled transfers at the given time and returns the outcomes
+func (suite *ChaincodeSuite) executeDueTransfers(now time.Time) []*model.ScheduledRun {
+	suite.now = now
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "ExecuteDueTransfers", []string{})
+	suite.Nil(err)
+	runs := new(model.ScheduledRunList)
+	json.Unmarshal(res, runs)
+	return runs.Runs
+}
+
+// listScheduledTransfers returns the scheduled transfers of a customer
+func (suite *ChaincodeSuite) listScheduledTransfers(customerID string) []*model.ScheduledTransfer {
+	res, err := suite.stub.MockInvoke("t0", "ListScheduledTransfers", []string{customerID})
+	suite.Nil(err)
+	list := new(model.ScheduledTransferList)
+	json.Unmarshal(res, list)
+	return list.ScheduledTransfers
+}
+
+func (suite *ChaincodeSuite) TestScheduleTransferValidation() {
+	_, err := suite.stub.MockInvoke("t1", "ScheduleTransfer", []string{})
+	suite.Equal("Missing required scheduled transfer JSON", err.Error())
+	_, err = suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z"`)
+	suite.Equal("Account with number 1234 not found.", err.Error())
+	suite.openAccount("1", "1234", "AU", "NZD", 1000)
+	_, err = suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z"`)
+	suite.Equal("Transfer currency AUD does not match currency NZD of account 1234", err.Error())
+	_, err = suite.scheduleTransfer(`"start":"2017-08-14T00:00:00Z"`)
+	suite.Equal("Error creating scheduled transfer. Error: Schedule start must not be in the past", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestExecuteDueTransfers() {
+	suite.openAccount("1", "1234", "AU", "AUD", 800)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	s, err := suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z","frequency":"weekly","count":3`)
+	suite.Nil(err)
+
+	suite.Equal(0, len(suite.executeDueTransfers(time.Date(2017, 8, 19, 0, 0, 0, 0, time.UTC))))
+	runs := suite.executeDueTransfers(time.Date(2017, 8, 20, 9, 0, 0, 0, time.UTC))
+	suite.Equal(1, len(runs))
+	suite.Equal(model.TransferCompleted, runs[0].Status)
+	suite.Equal(s.ID, runs[0].ScheduleID)
+	t := suite.getTransfer(runs[0].TransferID)
+	suite.Equal(int64(300), t.Amount)
+	suite.Equal(int64(500), suite.getAccount("1", "1234").Balance)
+
+	// running again the same week does nothing
+	suite.Equal(0, len(suite.executeDueTransfers(time.Date(2017, 8, 21, 0, 0, 0, 0, time.UTC))))
+
+	// missed transfers are caught up, the last one fails
+	runs = suite.executeDueTransfers(time.Date(2017, 9, 10, 0, 0, 0, 0, time.UTC))
+	suite.Equal(2, len(runs))
+	suite.Equal(model.TransferCompleted, runs[0].Status)
+	suite.Equal(model.TransferFailed, runs[1].Status)
+	suite.Equal("Insufficient funds available in account 1234", runs[1].Message)
+	suite.Equal(model.TransferFailed, suite.getTransfer(runs[1].TransferID).Status)
+	suite.Equal(int64(200), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(600), suite.getAccount("2", "5678").Balance)
+
+	schedules := suite.listScheduledTransfers("1")
+	suite.Equal(1, len(schedules))
+	suite.Equal(model.ScheduleCompleted, schedules[0].Status)
+	suite.Equal(3, len(schedules[0].Runs))
+}
+
+func (suite *ChaincodeSuite) TestExecuteDueTransfersCapsCatchUp() {
+	suite.openAccount("1", "1234", "AU", "AUD", 10000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z","frequency":"daily"`)
+
+	// eight daily transfers are due, the ones beyond the cap are made by the next invocation
+	now := time.Date(2017, 8, 27, 12, 0, 0, 0, time.UTC)
+	suite.Equal(model.MaxCatchUpRuns, len(suite.executeDueTransfers(now)))
+	suite.Equal(8-model.MaxCatchUpRuns, len(suite.executeDueTransfers(now)))
+	suite.Equal(0, len(suite.executeDueTransfers(now)))
+	suite.Equal(int64(2400), suite.getAccount("2", "5678").Balance)
+	suite.Equal(8, suite.listScheduledTransfers("1")[0].Occurrences)
+}
+
+func (suite *ChaincodeSuite) TestExecuteDueTransfersIsolatesFailures() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z"`)
+	suite.stub.MockInvoke(suite.nextTxID(), "ScheduleTransfer", []string{`{"transfer":{"from_customer":"1","from_account":"1234","to_customer":"3","to_account":"9012","currency":"AUD","amount":100},"start":"2017-08-20T00:00:00Z"}`})
+
+	runs := suite.executeDueTransfers(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC))
+	suite.Equal(2, len(runs))
+	statuses := map[model.TransferStatus]int{}
+	for _, run := range runs {
+		statuses[run.Status]++
+	}
+	suite.Equal(map[model.TransferStatus]int{model.TransferCompleted: 1, model.TransferFailed: 1}, statuses)
+	suite.Equal(int64(700), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestCancelScheduledTransfer() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	s, _ := suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z","frequency":"daily"`)
+
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "CancelScheduledTransfer", []string{"1", s.ID})
+	suite.Nil(err)
+	suite.Equal(0, len(suite.executeDueTransfers(time.Date(2017, 8, 25, 0, 0, 0, 0, time.UTC))))
+	suite.Equal(model.ScheduleCancelled, suite.listScheduledTransfers("1")[0].Status)
+
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CancelScheduledTransfer", []string{"1", s.ID})
+	suite.Equal("Cannot cancel scheduled transfer "+s.ID+" with status cancelled", err.Error())
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CancelScheduledTransfer", []string{"1", "unknown"})
+	suite.Equal("Scheduled transfer with ID unknown not found", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestCancelScheduledTransferBySignatory() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.registerCustomer("3")
+	suite.asCustomer("1")
+	suite.stub.MockInvoke("t1", "SetSignatory", []string{"1", "1234", `{"customer_id":"2","permission":"full"}`})
+	suite.stub.MockInvoke("t2", "SetSignatory", []string{"1", "1234", `{"customer_id":"3","permission":"pay"}`})
+
+	suite.asCustomer("2")
+	s, err := suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z","frequency":"daily"`)
+	suite.Nil(err)
+	suite.asCustomer("3")
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CancelScheduledTransfer", []string{"1", s.ID})
+	suite.Equal("Caller is not authorised to act for customer 1", err.Error())
+	suite.asCustomer("2")
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CancelScheduledTransfer", []string{"1", s.ID})
+	suite.Nil(err)
+	suite.asRole(BankOperatorRole)
+	suite.Equal(model.ScheduleCancelled, suite.listScheduledTransfers("1")[0].Status)
+}
+
+func (suite *ChaincodeSuite) TestScheduledInstructionIsNotChangedByItsTransfers() {
+	suite.setupEscrow()
+	suite.openAccount("3", "9012", "AU", "AUD", 0)
+	split := `{"transfer":{"from_customer":"1","from_account":"1234","currency":"AUD","amount":100,"payees":[{"customer":"2","account":"5678","percentage":"50"},{"customer":"3","account":"9012","percentage":"50"}]},"start":"2017-08-20T00:00:00Z"}`
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "ScheduleTransfer", []string{split})
+	suite.Nil(err)
+	escrowed := `{"transfer":{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","currency":"AUD","amount":100,"escrow":{"condition":"confirmation"}},"start":"2017-08-20T00:00:00Z"}`
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "ScheduleTransfer", []string{escrowed})
+	suite.Nil(err)
+
+	runs := suite.executeDueTransfers(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC))
+	suite.Equal(2, len(runs))
+	for _, s := range suite.listScheduledTransfers("1") {
+		for _, p := range s.Transfer.Payees {
+			suite.Equal(int64(0), p.Share, "Payee shares are not stored on the instruction")
+		}
+		if s.Transfer.Escrow != nil {
+			suite.Equal(model.Escrow{Condition: model.ConfirmationCondition}, *s.Transfer.Escrow, "The escrow account is not stored on the instruction")
+		}
+	}
+}
+
+// countDueIndexEntries returns the number of due time index entries of scheduled transfers
+func (suite *ChaincodeSuite) countDueIndexEntries() int {
+	prefix, _ := suite.cc.createCompositeKey(model.ScheduledTransferDueObjectType, nil)
+	count := 0
+	for key := range suite.stub.State {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (suite *ChaincodeSuite) TestScheduledTransfersIndexedByDueTime() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	s, err := suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z","frequency":"daily","count":2`)
+	suite.Nil(err)
+	key, _ := suite.cc.createCompositeKey(model.ScheduledTransferDueObjectType, []string{"001503187200", "1", s.ID})
+	suite.checkState(key, `{"docType":"ScheduledTransferDue","due":1503187200,"customer_id":"1","schedule_id":"`+s.ID+`"}`)
+
+	suite.Equal(1, len(suite.executeDueTransfers(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC))))
+	suite.Nil(suite.stub.State[key], "entry of the transfer made is removed")
+	suite.Equal(1, suite.countDueIndexEntries())
+	suite.Equal(1, len(suite.executeDueTransfers(time.Date(2017, 8, 21, 0, 0, 0, 0, time.UTC))))
+	suite.Equal(0, suite.countDueIndexEntries(), "completed scheduled transfers are not indexed")
+
+	s, _ = suite.scheduleTransfer(`"start":"2017-08-25T00:00:00Z","frequency":"daily"`)
+	suite.Equal(1, suite.countDueIndexEntries())
+	suite.stub.MockInvoke(suite.nextTxID(), "CancelScheduledTransfer", []string{"1", s.ID})
+	suite.Equal(0, suite.countDueIndexEntries(), "cancelled scheduled transfers are not indexed")
+}
+
+func (suite *ChaincodeSuite) TestScheduledTransferRechecksCreator() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "SetSignatory", []string{"1", "1234", `{"customer_id":"10","permission":"full"}`})
+	suite.Nil(err)
+
+	suite.asCustomer("10")
+	s, err := suite.scheduleTransfer(`"start":"2017-08-20T00:00:00Z","frequency":"daily"`)
+	suite.Nil(err)
+	suite.Equal("10", s.CreatedBy)
+	suite.asRole(BankOperatorRole)
+	suite.Equal(model.TransferCompleted, suite.executeDueTransfers(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC))[0].Status)
+
+	// the signatory's mandate is revoked, its scheduled transfers are no longer made
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "RemoveAccountParty", []string{"1", "1234", "10"})
+	suite.Nil(err)
+	runs := suite.executeDueTransfers(time.Date(2017, 8, 21, 0, 0, 0, 0, time.UTC))
+	suite.Equal(1, len(runs))
+	suite.Equal(model.TransferFailed, runs[0].Status)
+	suite.Equal("Customer 10 who scheduled the transfer is no longer authorised to pay from account 1234", runs[0].Message)
+	suite.Equal(model.CustomerIneligible, suite.getTransfer(runs[0].TransferID).FailureCode)
+	suite.Equal(int64(700), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestMigrateKeysIndexesScheduledTransfers() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	schedule := `{"docType":"ScheduledTransfer","id":"s1",` + testInstruction + `,"frequency":"once","start":"2017-08-20T00:00:00Z","status":"active","created":"2017-08-15T00:00:00Z"}`
+	key, _ := suite.cc.createCompositeKey(model.ScheduledTransferObjectType, []string{"1", "s1"})
+	suite.stub.MockTransactionStart("t0")
+	suite.stub.PutState(key, []byte(schedule))
+	suite.stub.MockTransactionEnd("t0")
+	suite.Equal(0, len(suite.executeDueTransfers(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC))))
+
+	suite.asRole(AdminRole)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "MigrateKeys", []string{})
+	suite.Nil(err)
+	suite.asRole(BankOperatorRole)
+	runs := suite.executeDueTransfers(time.Date(2017, 8, 20, 0, 0, 0, 0, time.UTC))
+	suite.Equal(1, len(runs))
+	suite.Equal(model.TransferCompleted, runs[0].Status)
+}
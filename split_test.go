@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+const testSplitTransfer = `{"from_customer":"1","from_account":"1234","currency":"AUD","amount":1001,"payees":[` +
+	`{"customer":"2","account":"5678","percentage":70},` +
+	`{"customer":"3","account":"9012","percentage":30}]}`
+
+// splitTransfer makes a split transfer and returns it
+func (suite *ChaincodeSuite) splitTransfer(transfer string) *model.Transfer {
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	suite.Nil(err)
+	t := new(model.Transfer)
+	json.Unmarshal(res, t)
+	return t
+}
+
+func (suite *ChaincodeSuite) setupSplit() {
+	suite.publishTestRates()
+	suite.openAccount("1", "1234", "AU", "AUD", 2000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.openAccount("3", "9012", "NZ", "NZD", 0)
+}
+
+func (suite *ChaincodeSuite) TestSplitTransfer() {
+	suite.setupSplit()
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+
+	t := suite.splitTransfer(testSplitTransfer)
+	suite.Equal(model.TransferCompleted, t.Status)
+	suite.Equal(int64(701), t.Payees[0].Share)
+	suite.Equal(int64(300), t.Payees[1].Share)
+	suite.Equal(4, len(t.Legs)) // debit, two credits and the fee
+
+	// the payer is debited once for the whole amount and the fee
+	debit := suite.getLeg(t.Legs[0])
+	suite.Equal(model.Debited, debit.Status)
+	suite.Equal(int64(1001), debit.Amount)
+	suite.Equal(int64(949), suite.getAccount("1", "1234").Balance)
+
+	// each payee is credited its share, converted into the currency of its account
+	suite.Equal(int64(701), suite.getLeg(t.Legs[1]).Amount)
+	suite.Equal(int64(701), suite.getAccount("2", "5678").Balance)
+	credit := suite.getLeg(t.Legs[2])
+	suite.Equal(model.Credited, credit.Status)
+	suite.Equal(int64(324), credit.Amount)
+	suite.Equal("NZD", credit.CurrencyCode)
+	suite.Equal(int64(324), suite.getAccount("3", "9012").Balance)
+	suite.Equal(int64(50), suite.getAccount("bank", "fees").Balance)
+	suite.Equal(t.Payees, suite.getTransfer(t.ID).Payees)
+}
+
+func (suite *ChaincodeSuite) TestSplitTransferIsAtomic() {
+	suite.setupSplit()
+	suite.stub.MockInvoke("t1", "CloseAccount", []string{"3", "9012"})
+
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{testSplitTransfer})
+	suite.Nil(err)
+	suite.Equal("Cannot transfer money into closed account 9012", suite.failureReason(res))
+	// no payee is credited and the payer is not debited
+	suite.Equal(int64(2000), suite.getAccount("1", "1234").Balance)
+	suite.Equal(int64(0), suite.getAccount("2", "5678").Balance)
+	failed := suite.getFailedTransaction("3", "9012")
+	suite.Equal(model.AccountClosed, failed.FailureCode)
+	t := suite.getTransfer(failed.TransferID)
+	suite.Equal(model.TransferFailed, t.Status)
+	suite.Equal(1, len(t.Legs))
+}
+
+func (suite *ChaincodeSuite) TestSplitTransferValidation() {
+	suite.setupSplit()
+	transfer := `{"from_customer":"1","from_account":"1234","currency":"AUD","amount":1000,"payees":[` +
+		`{"customer":"2","account":"5678","percentage":70},{"customer":"3","account":"9012","percentage":20}]}`
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{transfer})
+	suite.Contains(err.Error(), "Payee percentages add up to 90.00, not 100")
+}
+
+func (suite *ChaincodeSuite) TestReverseSplitTransfer() {
+	suite.setupSplit()
+	t := suite.splitTransfer(testSplitTransfer)
+	_, err := suite.reverseTransfer(t.ID)
+	suite.Equal("Cannot reverse split transfer "+t.ID, err.Error())
+}
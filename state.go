@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// txState stages the state changes of a chaincode invocation. Reads see the
+// staged writes, but nothing is written to the ledger until commit is called,
+// so an operation failing part way through leaves the ledger unchanged.
+// A child state commits into its parent instead of the ledger.
+type txState struct {
+	stub   shim.ChaincodeStubInterface
+	parent *txState
+	writes map[string][]byte // a nil value marks a deleted key
+	keys   []string          // staged keys in the order they were first written
+}
+
+// newTxState creates a new staging area for the given stub
+func newTxState(stub shim.ChaincodeStubInterface) *txState {
+	return &txState{stub: stub, writes: make(map[string][]byte)}
+}
+
+// child creates a nested staging area which commits into this one
+func (s *txState) child() *txState {
+	c := newTxState(s.stub)
+	c.parent = s
+	return c
+}
+
+// getState returns the staged value of a key, falling back to the parent or the ledger
+func (s *txState) getState(key string) ([]byte, error) {
+	if value, ok := s.writes[key]; ok {
+		return value, nil
+	}
+	if s.parent != nil {
+		return s.parent.getState(key)
+	}
+	return s.stub.GetState(key)
+}
+
+// putState stages a write
+func (s *txState) putState(key string, value []byte) {
+	if _, ok := s.writes[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.writes[key] = value
+}
+
+// delState stages a delete
+func (s *txState) delState(key string) {
+	s.putState(key, nil)
+}
+
+// getObject unmarshals the JSON value of a key into v. It returns false if the key does not exist.
+func (s *txState) getObject(key string, v interface{}) (bool, error) {
+	data, err := s.getState(key)
+	if err != nil {
+		return false, fmt.Errorf("Error getting state for key %q. Error: %s", key, err)
+	}
+	if data == nil {
+		return false, nil
+	}
+	if err := bytesToStruct(data, v); err != nil {
+		return false, fmt.Errorf("Error unmarshalling state for key %q. Error: %s", key, err)
+	}
+	return true, nil
+}
+
+// putObject stages a write of the JSON representation of v and returns it
+func (s *txState) putObject(key string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling %T. Error: %s", v, err)
+	}
+	s.putState(key, data)
+	return data, nil
+}
+
+// commit emits all staged writes into the parent state or the ledger
+func (s *txState) commit() error {
+	for _, key := range s.keys {
+		value := s.writes[key]
+		switch {
+		case s.parent != nil:
+			s.parent.putState(key, value)
+		case value == nil:
+			if err := s.stub.DelState(key); err != nil {
+				return fmt.Errorf("Error deleting state for key %q. Error: %s", key, err)
+			}
+		default:
+			if err := s.stub.PutState(key, value); err != nil {
+				return fmt.Errorf("Error putting state for key %q. Error: %s", key, err)
+			}
+		}
+	}
+	s.writes = make(map[string][]byte)
+	s.keys = nil
+	return nil
+}
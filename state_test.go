@@ -0,0 +1,64 @@
+package main
+
+func (suite *ChaincodeSuite) TestTxStateStagesWrites() {
+	state := newTxState(suite.stub)
+	state.putState("a", []byte("1"))
+	value, _ := state.getState("a")
+	suite.Equal("1", string(value))
+	suite.Nil(suite.stub.State["a"])
+
+	suite.stub.MockTransactionStart("t1")
+	suite.Nil(state.commit())
+	suite.stub.MockTransactionEnd("t1")
+	suite.Equal("1", string(suite.stub.State["a"]))
+}
+
+func (suite *ChaincodeSuite) TestTxStateStagesDeletes() {
+	suite.stub.MockTransactionStart("t1")
+	suite.stub.PutState("a", []byte("1"))
+	state := newTxState(suite.stub)
+	state.delState("a")
+	value, _ := state.getState("a")
+	suite.Nil(value)
+	suite.NotNil(suite.stub.State["a"])
+	suite.Nil(state.commit())
+	suite.stub.MockTransactionEnd("t1")
+	suite.Nil(suite.stub.State["a"])
+}
+
+func (suite *ChaincodeSuite) TestTxStateChildCommitsIntoParent() {
+	state := newTxState(suite.stub)
+	state.putState("a", []byte("1"))
+	child := state.child()
+	value, _ := child.getState("a")
+	suite.Equal("1", string(value))
+	child.putState("a", []byte("2"))
+	value, _ = state.getState("a")
+	suite.Equal("1", string(value))
+	suite.Nil(child.commit())
+	value, _ = state.getState("a")
+	suite.Equal("2", string(value))
+	suite.Nil(suite.stub.State["a"])
+}
+
+func (suite *ChaincodeSuite) TestTxStateCommitError() {
+	state := newTxState(suite.stub)
+	state.putState("a", []byte("1"))
+	suite.NotNil(state.commit()) // mock stub rejects writes outside of a transaction
+}
+
+func (suite *ChaincodeSuite) TestTxStateGetObject() {
+	state := newTxState(suite.stub)
+	v := map[string]string{}
+	found, err := state.getObject("a", &v)
+	suite.Nil(err)
+	suite.False(found)
+	state.putObject("a", map[string]string{"b": "c"})
+	found, err = state.getObject("a", &v)
+	suite.Nil(err)
+	suite.True(found)
+	suite.Equal("c", v["b"])
+	state.putState("d", []byte("{"))
+	_, err = state.getObject("d", &v)
+	suite.NotNil(err)
+}
@@ -1,17 +1,18 @@
 package utils
 
-import (
-	"math/rand"
-	"time"
-)
+import "crypto/sha256"
 
-// GenerateID generates a fixed length string of random digits
-func GenerateID(length int) string {
-	rand.Seed(time.Now().UnixNano())
+// GenerateID derives a fixed length string of digits from the given seed.
+// The same seed always generates the same ID.
+func GenerateID(seed string, length int) string {
 	r := []rune("1234567890")
 	b := make([]rune, length)
+	hash := sha256.Sum256([]byte(seed))
 	for i := range b {
-		b[i] = r[rand.Intn(len(r))]
+		if i > 0 && i%len(hash) == 0 {
+			hash = sha256.Sum256(hash[:])
+		}
+		b[i] = r[int(hash[i%len(hash)])%len(r)]
 	}
 	return string(b)
 }
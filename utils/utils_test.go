@@ -7,6 +7,15 @@ import (
 )
 
 func TestGenerateID(t *testing.T) {
-	assert.Len(t, GenerateID(8), 8)
-	assert.NotEqual(t, GenerateID(8), GenerateID(8))
+	assert.Len(t, GenerateID("t1", 8), 8)
+	assert.Regexp(t, "^[0-9]+$", GenerateID("t1", 8))
+	assert.NotEqual(t, GenerateID("t1", 8), GenerateID("t2", 8))
+}
+
+func TestGenerateIDIsDeterministic(t *testing.T) {
+	assert.Equal(t, GenerateID("t1", 8), GenerateID("t1", 8))
+}
+
+func TestGenerateIDLong(t *testing.T) {
+	assert.Len(t, GenerateID("t1", 100), 100)
 }
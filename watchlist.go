@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// SetWatchList stores the watch-list new accounts and transfers are screened against,
+// replacing any previous list. Only compliance officers can maintain the watch-list.
+func (cc *Chaincode) SetWatchList(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering SetWatchList with args %v", args)
+
+	if len(args) == 0 {
+		return nil, errors.New("Missing required watch-list JSON")
+	}
+	list, err := model.CreateWatchList([]byte(args[0]))
+	if err != nil {
+		logger.Errorf("Error when creating watch-list. Error: %s", err)
+		return nil, fmt.Errorf("Error creating watch-list. Error: %s", err)
+	}
+	key, err := cc.createCompositeKey(model.WatchListObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	state := newTxState(stub)
+	listData, err := state.putObject(key, list)
+	if err != nil {
+		return nil, err
+	}
+	if err := state.commit(); err != nil {
+		return nil, err
+	}
+	return listData, nil
+}
+
+// GetWatchList query the current watch-list, restricted to compliance officers
+func (cc *Chaincode) GetWatchList(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	logger.Debugf("Entering GetWatchList with args %v", args)
+
+	key, err := cc.createCompositeKey(model.WatchListObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	listBytes, err := stub.GetState(key)
+	if err != nil {
+		logger.Errorf("Failed to get watch-list. Error: %s", err)
+		return nil, err
+	}
+	if listBytes == nil {
+		return nil, errors.New("No watch-list available")
+	}
+	return listBytes, nil
+}
+
+// screenAccount returns an error if the holder of a new account matches the watch-list
+func (cc *Chaincode) screenAccount(state *txState, account *model.Account) error {
+	list, err := cc.getWatchList(state)
+	if err != nil || list == nil {
+		return err
+	}
+	if hit := list.Screen(accountParty(account)); hit != nil {
+		logger.Warningf("Account %s of customer %s matches watch-list entry %s", account.ID, account.CustomerID, hit.EntryID)
+		return fmt.Errorf("Account holder matches watch-list entry %s on %s", hit.EntryID, hit.Field)
+	}
+	return nil
+}
+
+// screenParty returns an error if a customer becoming a joint holder or signatory of an
+// account matches the watch-list
+func (cc *Chaincode) screenParty(state *txState, account *model.Account, customer *model.User) error {
+	list, err := cc.getWatchList(state)
+	if err != nil || list == nil {
+		return err
+	}
+	if hit := list.Screen(customerParty(customer)); hit != nil {
+		logger.Warningf("Party %s of account %s matches watch-list entry %s", customer.ID, account.ID, hit.EntryID)
+		return fmt.Errorf("Account party %s matches watch-list entry %s on %s", customer.ID, hit.EntryID, hit.Field)
+	}
+	return nil
+}
+
+// screenTransfer screens the payer and payee accounts of a transfer against the watch-list,
+// their holders as well as their joint holders and signatories, which include any signatory
+// initiating the transfer. Transfers matching a blocking watch-list are rejected with the
+// sanctions_hit failure code, for a reviewing watch-list the hit is returned.
+func (cc *Chaincode) screenTransfer(state *txState, t *model.Transfer, from *model.Account, credits []*payeeCredit) (*model.ScreeningHit, error) {
+	list, err := cc.getWatchList(state)
+	if err != nil || list == nil {
+		return nil, err
+	}
+	accounts := []*model.Account{from}
+	for _, c := range credits {
+		accounts = append(accounts, c.account)
+	}
+	for _, account := range accounts {
+		hit, err := cc.screenAccountParties(state, list, account)
+		if err != nil {
+			return nil, err
+		}
+		if hit == nil {
+			continue
+		}
+		hit.AccountID = account.ID
+		logger.Warningf("Transfer %s matches watch-list entry %s on %s of account %s", t.ID, hit.EntryID, hit.Field, account.ID)
+		if list.Action == model.BlockAction {
+			t.Screening = hit
+			if hit.CustomerID != account.CustomerID {
+				return nil, newTransferError(model.SanctionsHit, account, nil, "Transfer blocked, party %s of account %s matches watch-list entry %s", hit.CustomerID, account.ID, hit.EntryID)
+			}
+			return nil, newTransferError(model.SanctionsHit, account, nil, "Transfer blocked, account %s matches watch-list entry %s", account.ID, hit.EntryID)
+		}
+		return hit, nil
+	}
+	return nil, nil
+}
+
+// screenAccountParties screens the holder of an account and its joint holders and
+// signatories against the watch-list and returns the first hit
+func (cc *Chaincode) screenAccountParties(state *txState, list *model.WatchList, account *model.Account) (*model.ScreeningHit, error) {
+	if hit := list.Screen(accountParty(account)); hit != nil {
+		return hit, nil
+	}
+	for _, partyID := range account.Parties() {
+		customer, err := cc.getCustomer(state, partyID)
+		if err != nil {
+			return nil, err
+		}
+		if hit := list.Screen(customerParty(customer)); hit != nil {
+			return hit, nil
+		}
+	}
+	return nil, nil
+}
+
+// getWatchList reads the current watch-list, or nil if no list has been set
+func (cc *Chaincode) getWatchList(state *txState) (*model.WatchList, error) {
+	key, err := cc.createCompositeKey(model.WatchListObjectType, nil)
+	if err != nil {
+		return nil, err
+	}
+	list := new(model.WatchList)
+	found, err := state.getObject(key, list)
+	if err != nil || !found {
+		return nil, err
+	}
+	return list, nil
+}
+
+// accountParty returns the account holder details screened against the watch-list
+func accountParty(a *model.Account) model.Party {
+	return model.Party{Name: a.AccountHolder, CustomerID: a.CustomerID, CountryCode: a.CountryCode}
+}
+
+// customerParty returns the customer details screened against the watch-list
+func customerParty(u *model.User) model.Party {
+	return model.Party{Name: u.Name, CustomerID: u.ID, CountryCode: u.CountryCode}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mschimk1/passport-chaincode/model"
+)
+
+// setWatchList sets the watch-list as a compliance officer
+func (suite *ChaincodeSuite) setWatchList(list string) {
+	suite.asRole(ComplianceRole)
+	defer suite.asRole(BankOperatorRole)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "SetWatchList", []string{list})
+	suite.Nil(err)
+}
+
+// openNamedAccount opens a test account with the given account holder
+func (suite *ChaincodeSuite) openNamedAccount(customerID string, accountID string, holder string, country string, balance int64) error {
+	suite.registerCustomer(customerID)
+	account := fmt.Sprintf(`{"id":"%s","customer_id":"%s","bank_name":"Test Bank","account_holder":"%s","country":"%s","currency":"AUD","balance":%d}`,
+		accountID, customerID, holder, country, balance)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "OpenAccount", []string{account})
+	return err
+}
+
+const testWatchList = `{"entries":[{"id":"w1","name":"Ivan Petrovich Sidorov"},{"id":"w2","country":"KP"}]}`
+
+func (suite *ChaincodeSuite) TestSetWatchListValidation() {
+	_, err := suite.stub.MockInvoke("t1", "SetWatchList", []string{testWatchList})
+	suite.Equal("Caller is not authorised, role compliance_officer is required", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "GetWatchList", []string{})
+	suite.Equal("Caller is not authorised, role compliance_officer is required", err.Error())
+	suite.asRole(ComplianceRole)
+	_, err = suite.stub.MockInvoke("t1", "SetWatchList", []string{})
+	suite.Equal("Missing required watch-list JSON", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "SetWatchList", []string{`{"action":"ignore","entries":[]}`})
+	suite.Equal("Error creating watch-list. Error: Invalid screening action ignore", err.Error())
+	_, err = suite.stub.MockInvoke("t1", "GetWatchList", []string{})
+	suite.Equal("No watch-list available", err.Error())
+}
+
+func (suite *ChaincodeSuite) TestOpenAccountScreening() {
+	suite.setWatchList(testWatchList)
+	err := suite.openNamedAccount("1", "1234", "Sidorov, Ivan Petrovic", "AU", 0)
+	suite.Equal("Account holder matches watch-list entry w1 on name", err.Error())
+	err = suite.openNamedAccount("1", "1234", "Jane Doe", "KP", 0)
+	suite.Equal("Account holder matches watch-list entry w2 on country", err.Error())
+	suite.Nil(suite.openNamedAccount("1", "1234", "Jane Doe", "AU", 0))
+}
+
+func (suite *ChaincodeSuite) TestTransferScreeningBlocks() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.Nil(suite.openNamedAccount("2", "5678", "Ivan P. Sidorov", "AU", 0))
+	suite.setWatchList(`{"entries":[{"id":"w1","name":"Ivan Petrovich Sidorov"}],"threshold":0.6}`)
+
+	err := suite.tryTransfer("1", "1234", "2", "5678", 100)
+	suite.Equal("Transfer blocked, account 5678 matches watch-list entry w1", err.Error())
+	failed := suite.getFailedTransaction("2", "5678")
+	suite.Equal(model.SanctionsHit, failed.FailureCode)
+	t := suite.getTransfer(failed.TransferID)
+	suite.Equal(model.TransferFailed, t.Status)
+	suite.Equal("w1", t.Screening.EntryID)
+	suite.Equal("5678", t.Screening.AccountID)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+}
+
+func (suite *ChaincodeSuite) TestTransferScreeningHoldsForReview() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.openAccount("bank", "fees", "AU", "AUD", 0)
+	suite.stub.MockInvoke("t1", "SetFeeSchedule", []string{testFeeSchedule})
+	suite.setWatchList(`{"action":"review","entries":[{"id":"w1","customer_id":"1"}]}`)
+
+	t := suite.transferMoney("1", "1234", "2", "5678", "AUD", 400)
+	suite.Equal(model.TransferPendingReview, t.Status)
+	suite.Equal("customer_id", t.Screening.Field)
+	suite.Equal(0, len(t.Legs))
+
+	// nothing is transferred, the amount plus fee is reserved until the review
+	balance := suite.getBalance("1", "1234")
+	suite.Equal(int64(1000), balance.Balance)
+	suite.Equal(int64(450), balance.Held)
+	suite.Equal(int64(0), suite.getAccount("2", "5678").Balance)
+	suite.Equal(model.TransferPendingReview, suite.getTransfer(t.ID).Status)
+
+	// the reserved funds cannot be released or captured by the customer
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "ReleaseHold", []string{"1", "1234", t.HoldID})
+	suite.Equal(fmt.Sprintf("Hold %s reserves funds of pending transfer %s", t.HoldID, t.ID), err.Error())
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "CaptureHold", []string{"1", "1234", t.HoldID, `{"to_customer":"2","to_account":"5678"}`})
+	suite.Equal(fmt.Sprintf("Hold %s reserves funds of pending transfer %s", t.HoldID, t.ID), err.Error())
+}
+
+func (suite *ChaincodeSuite) TestTransferScreeningReviewRequiresFunds() {
+	suite.openAccount("1", "1234", "AU", "AUD", 100)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	suite.setWatchList(`{"action":"review","entries":[{"id":"w1","customer_id":"2"}]}`)
+
+	err := suite.tryTransfer("1", "1234", "2", "5678", 400)
+	suite.Equal("Insufficient funds available in account 1234", err.Error())
+	suite.Equal(int64(0), suite.getBalance("1", "1234").Held)
+}
+
+func (suite *ChaincodeSuite) TestPartyScreening() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.setWatchList(`{"entries":[{"id":"w1","customer_id":"2"},{"id":"w2","name":"Customer 10"}]}`)
+
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "AddJointHolder", []string{"1", "1234", "2"})
+	suite.Equal("Account party 2 matches watch-list entry w1 on customer_id", err.Error())
+	_, err = suite.stub.MockInvoke(suite.nextTxID(), "SetSignatory", []string{"1", "1234", `{"customer_id":"10","permission":"full"}`})
+	suite.Equal("Account party 10 matches watch-list entry w2 on name", err.Error())
+	suite.Empty(suite.getAccount("1", "1234").Parties())
+}
+
+func (suite *ChaincodeSuite) TestTransferScreeningAccountParties() {
+	suite.openAccount("1", "1234", "AU", "AUD", 1000)
+	suite.openAccount("2", "5678", "AU", "AUD", 0)
+	_, err := suite.stub.MockInvoke(suite.nextTxID(), "SetSignatory", []string{"1", "1234", `{"customer_id":"10","permission":"pay","pay_limit":500}`})
+	suite.Nil(err)
+	suite.setWatchList(`{"entries":[{"id":"w1","customer_id":"10"}]}`)
+
+	// the signatory initiating the transfer is screened as a party of the payer account
+	suite.asCustomer("10")
+	res, err := suite.stub.MockInvoke(suite.nextTxID(), "TransferMoney", []string{`{"from_customer":"1","from_account":"1234","to_customer":"2","to_account":"5678","amount":100,"currency":"AUD"}`})
+	suite.Nil(err)
+	suite.Equal("Transfer blocked, party 10 of account 1234 matches watch-list entry w1", suite.failureReason(res))
+
+	// as is every other party, whoever makes the transfer
+	suite.asRole(BankOperatorRole)
+	err = suite.tryTransfer("1", "1234", "2", "5678", 100)
+	suite.Equal("Transfer blocked, party 10 of account 1234 matches watch-list entry w1", err.Error())
+	t := suite.getTransfer(suite.getFailedTransaction("1", "1234").TransferID)
+	suite.Equal(model.SanctionsHit, t.FailureCode)
+	suite.Equal("10", t.Screening.CustomerID)
+	suite.Equal("1234", t.Screening.AccountID)
+	suite.Equal(int64(1000), suite.getAccount("1", "1234").Balance)
+}